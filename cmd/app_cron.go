@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appCronCmd = &cobra.Command{
+	Use:   "cron",
+	Short: "Manage scheduled jobs",
+	Long: `Add, list, run and remove scheduled jobs for applications.
+
+Each run starts a one-off container from the application's current image with its
+environment variables and vpc network, like yap app run. Runs of a job never overlap,
+a run that comes due while the previous one is still going is recorded as skipped.
+
+Jobs come from [[cron]] in yap.toml or from yap app cron add. A deploy replaces the
+jobs yap.toml defined and keeps the ones added from the command line.`,
+}
+
+func init() {
+	appCmd.AddCommand(appCronCmd)
+}
+
+// starts a detached `yap app cron scheduler` that outlives this command. it exits right away
+// when a scheduler is already running, and on its own once no app has jobs left
+func ensureCronScheduler() error {
+	return startDetached("cron-scheduler", "app", "cron", "scheduler")
+}
+
+// starts the scheduler when the application has jobs, warning instead of failing the command
+func maybeEnsureCronScheduler(output io.Writer, application *models.Application) {
+	if len(application.CronJobs) == 0 {
+		return
+	}
+	if err := ensureCronScheduler(); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to start the cron scheduler: %v\n", err)
+	}
+}
+
+// a job name from the command, ./scripts/cleanup.sh becomes cleanup, made unique among the app's jobs
+func defaultCronJobName(application *models.Application, command []string) string {
+	base := strings.ToLower(filepath.Base(command[0]))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	var name strings.Builder
+	for _, r := range base {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			name.WriteRune(r)
+		case name.Len() > 0:
+			name.WriteRune('-')
+		}
+	}
+
+	candidate := strings.Trim(name.String(), "-")
+	if len(candidate) > 28 {
+		candidate = strings.Trim(candidate[:28], "-")
+	}
+	if models.ValidateCronJobName(candidate) != nil {
+		candidate = "job"
+	}
+
+	unique := candidate
+	for i := 2; app.FindCronJob(application, unique) != nil; i++ {
+		unique = fmt.Sprintf("%s-%d", candidate, i)
+	}
+	return unique
+}
+
+func describeCronNext(schedule string) string {
+	parsed, err := models.ParseSchedule(schedule)
+	if err != nil {
+		return "invalid schedule"
+	}
+	next := parsed.Next(time.Now())
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format("2006-01-02 15:04")
+}
+
+func describeCronStatus(status string) string {
+	switch status {
+	case app.CronRunSucceeded:
+		return successStyle.Render(status)
+	case app.CronRunFailed:
+		return errorStyle.Render(status)
+	}
+	return dimStyle.Render(status)
+}
+
+// the jobs [[cron]] in yap.toml defines, the loader already validated them
+func configCronJobs(configs []models.CronConfig) []models.CronJob {
+	jobs := make([]models.CronJob, 0, len(configs))
+	for _, config := range configs {
+		command, _ := config.CommandArgs()
+		jobs = append(jobs, models.CronJob{
+			Name:      config.Name,
+			Schedule:  config.Schedule,
+			Command:   command,
+			Timeout:   config.Timeout,
+			Source:    app.CronSourceConfig,
+			CreatedAt: time.Now(),
+		})
+	}
+	return jobs
+}
+
+// job name to when and what it runs
+func cronMap(application *models.Application) map[string]string {
+	jobs := make(map[string]string, len(application.CronJobs))
+	for _, job := range application.CronJobs {
+		jobs[job.Name] = fmt.Sprintf("%s: %s", job.Schedule, strings.Join(job.Command, " "))
+	}
+	return jobs
+}
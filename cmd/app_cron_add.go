@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appCronAddCmd = &cobra.Command{
+	Use:   "add [app-name] [schedule] -- [command...]",
+	Short: "Add a scheduled job",
+	Long: `Add a job that runs a command on a schedule in a one-off container.
+
+The schedule is a five field cron expression (minute hour day month weekday) in the
+server's local time, or one of @hourly, @daily, @weekly, @monthly and @yearly.
+Jobs added here survive deploys, unlike the ones yap.toml defines.
+
+Examples:
+  yap app cron add myapp "0 3 * * *" -- ./cleanup
+  yap app cron add myapp "*/15 * * * *" --name sync -- npm run sync
+  yap app cron add myapp @daily --timeout 600 -- sh -c 'rake reports:send'`,
+	Args: cobra.MinimumNArgs(3),
+	Run:  runAppCronAdd,
+}
+
+var (
+	cronAddName    string
+	cronAddTimeout int
+)
+
+func init() {
+	appCronCmd.AddCommand(appCronAddCmd)
+
+	appCronAddCmd.Flags().StringVar(&cronAddName, "name", "", "Job name (default: derived from the command)")
+	appCronAddCmd.Flags().IntVar(&cronAddTimeout, "timeout", 0, "Seconds before a run is killed (0 for no limit)")
+}
+
+func runAppCronAdd(cmd *cobra.Command, args []string) {
+	appName := args[0]
+	schedule := args[1]
+	command := args[2:]
+
+	if _, err := models.ParseSchedule(schedule); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if cronAddTimeout < 0 {
+		fmt.Fprintf(os.Stderr, "%s timeout cannot be negative\n", errorStyle.Render("[error]"))
+		os.Exit(1)
+	}
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	name := cronAddName
+	if name == "" {
+		name = defaultCronJobName(application, command)
+	}
+	if err := models.ValidateCronJobName(name); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	if app.FindCronJob(application, name) != nil {
+		fmt.Fprintf(os.Stderr, "%s job '%s' already exists, remove it first\n", errorStyle.Render("[error]"), name)
+		os.Exit(1)
+	}
+
+	job := models.CronJob{
+		Name:      name,
+		Schedule:  schedule,
+		Command:   command,
+		Timeout:   cronAddTimeout,
+		Source:    app.CronSourceCLI,
+		CreatedAt: time.Now(),
+	}
+	application.CronJobs = append(application.CronJobs, job)
+
+	if err := registry.Update(*application); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] added job %s to %s", name, appName)))
+	fmt.Printf("    %s %s\n", dimStyle.Render("schedule:"), valueStyle.Render(schedule))
+	fmt.Printf("    %s %s\n", dimStyle.Render("command:"), valueStyle.Render(strings.Join(command, " ")))
+	fmt.Printf("    %s %s\n", dimStyle.Render("next run:"), valueStyle.Render(describeCronNext(schedule)))
+
+	maybeEnsureCronScheduler(os.Stdout, application)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/spf13/cobra"
+)
+
+var appCronHistoryCmd = &cobra.Command{
+	Use:   "history [app-name] [job-name]",
+	Short: "Show a job's past runs",
+	Long: `Show a scheduled job's past runs, newest first, with their status and exit code.
+
+Examples:
+  yap app cron history myapp cleanup
+  yap app cron history myapp cleanup --limit 50`,
+	Args: cobra.ExactArgs(2),
+	Run:  runAppCronHistory,
+}
+
+var cronHistoryLimit int
+
+func init() {
+	appCronCmd.AddCommand(appCronHistoryCmd)
+
+	appCronHistoryCmd.Flags().IntVarP(&cronHistoryLimit, "limit", "n", 10, "Number of runs to show")
+}
+
+func runAppCronHistory(cmd *cobra.Command, args []string) {
+	appName := args[0]
+	jobName := args[1]
+
+	runs, err := app.CronHistory(appName, jobName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("==> run history: %s/%s", appName, jobName)))
+	fmt.Println()
+
+	if len(runs) == 0 {
+		fmt.Println(dimStyle.Render("  no runs yet"))
+		fmt.Println()
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app cron run %s %s' to run it now", appName, jobName)))
+		return
+	}
+
+	shown := 0
+	for i := len(runs) - 1; i >= 0 && shown < cronHistoryLimit; i-- {
+		run := runs[i]
+		shown++
+
+		duration := run.FinishedAt.Sub(run.StartedAt).Round(time.Second)
+		fmt.Printf("  %s  %s  %-9s  %s\n",
+			valueStyle.Render(run.ID),
+			run.StartedAt.Format("2006-01-02 15:04:05"),
+			run.Trigger,
+			describeCronStatus(run.Status))
+
+		details := fmt.Sprintf("exit %d, took %s", run.ExitCode, duration)
+		if run.Error != "" {
+			details += ", " + run.Error
+		}
+		fmt.Printf("    %s\n", dimStyle.Render(details))
+	}
+
+	fmt.Println()
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  showing %d of %d runs", shown, len(runs))))
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app cron logs %s %s [run-id]' to see a run's output", appName, jobName)))
+}
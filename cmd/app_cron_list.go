@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/spf13/cobra"
+)
+
+var appCronListCmd = &cobra.Command{
+	Use:   "list [app-name]",
+	Short: "List scheduled jobs",
+	Long:  "Display an application's scheduled jobs with their next and last runs",
+	Args:  cobra.ExactArgs(1),
+	Run:   runAppCronList,
+}
+
+func init() {
+	appCronCmd.AddCommand(appCronListCmd)
+}
+
+func runAppCronList(cmd *cobra.Command, args []string) {
+	appName := args[0]
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("==> scheduled jobs: %s", appName)))
+	fmt.Println()
+
+	if len(application.CronJobs) == 0 {
+		fmt.Println(dimStyle.Render("  no scheduled jobs"))
+		fmt.Println()
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app cron add %s \"0 3 * * *\" -- ./cleanup' to add one", appName)))
+		return
+	}
+
+	for _, job := range application.CronJobs {
+		fmt.Printf("  %s  %s\n", valueStyle.Render(job.Name), dimStyle.Render("("+job.Source+")"))
+		fmt.Printf("    %s %s\n", dimStyle.Render("schedule:"), job.Schedule)
+		fmt.Printf("    %s %s\n", dimStyle.Render("command: "), strings.Join(job.Command, " "))
+		if job.Timeout > 0 {
+			fmt.Printf("    %s %ds\n", dimStyle.Render("timeout: "), job.Timeout)
+		}
+		fmt.Printf("    %s %s\n", dimStyle.Render("next run:"), describeCronNext(job.Schedule))
+
+		runs, err := app.CronHistory(appName, job.Name)
+		if err == nil && len(runs) > 0 {
+			last := runs[len(runs)-1]
+			fmt.Printf("    %s %s (%s)\n", dimStyle.Render("last run:"), last.StartedAt.Format("2006-01-02 15:04:05"), describeCronStatus(last.Status))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  total: %d jobs", len(application.CronJobs))))
+	fmt.Println()
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app cron history %s <job>' to see past runs", appName)))
+}
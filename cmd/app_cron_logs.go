@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/spf13/cobra"
+)
+
+var appCronLogsCmd = &cobra.Command{
+	Use:   "logs [app-name] [job-name] [run-id]",
+	Short: "Show the output of a job run",
+	Long: `Show the output of a scheduled job's run, the latest one unless a run id from
+yap app cron history is given.
+
+Examples:
+  yap app cron logs myapp cleanup
+  yap app cron logs myapp cleanup 20261017-030000-x1y2z3a`,
+	Args: cobra.RangeArgs(2, 3),
+	Run:  runAppCronLogs,
+}
+
+func init() {
+	appCronCmd.AddCommand(appCronLogsCmd)
+}
+
+func runAppCronLogs(cmd *cobra.Command, args []string) {
+	appName := args[0]
+	jobName := args[1]
+
+	runs, err := app.CronHistory(appName, jobName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	// skipped runs never started, so they have no output
+	var logPath string
+	for i := len(runs) - 1; i >= 0; i-- {
+		if len(args) == 3 && runs[i].ID != args[2] {
+			continue
+		}
+		if len(args) == 3 || runs[i].LogPath != "" {
+			logPath = runs[i].LogPath
+			break
+		}
+	}
+
+	if logPath == "" {
+		if len(args) == 3 {
+			fmt.Fprintf(os.Stderr, "%s no output for run '%s'\n", errorStyle.Render("[error]"), args[2])
+		} else {
+			fmt.Fprintf(os.Stderr, "%s job '%s' has no runs with output\n", errorStyle.Render("[error]"), jobName)
+		}
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to read run log: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	os.Stdout.Write(data)
+}
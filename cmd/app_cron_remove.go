@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/spf13/cobra"
+)
+
+var appCronRemoveCmd = &cobra.Command{
+	Use:   "remove [app-name] [job-name]",
+	Short: "Remove a scheduled job",
+	Long: `Remove a scheduled job along with its run history and logs.
+
+A job yap.toml defines comes back on the next deploy unless it's removed from yap.toml as well.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runAppCronRemove,
+}
+
+func init() {
+	appCronCmd.AddCommand(appCronRemoveCmd)
+}
+
+func runAppCronRemove(cmd *cobra.Command, args []string) {
+	appName := args[0]
+	jobName := args[1]
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	job := app.FindCronJob(application, jobName)
+	if job == nil {
+		fmt.Fprintf(os.Stderr, "%s job '%s' not found\n", errorStyle.Render("[error]"), jobName)
+		os.Exit(1)
+	}
+	source := job.Source
+
+	jobs := application.CronJobs[:0]
+	for _, existing := range application.CronJobs {
+		if existing.Name != jobName {
+			jobs = append(jobs, existing)
+		}
+	}
+	application.CronJobs = jobs
+
+	if err := registry.Update(*application); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := app.RemoveCronHistory(appName, jobName); err != nil {
+		fmt.Printf("  [warn] failed to remove run history: %v\n", err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] removed job %s from %s", jobName, appName)))
+	if source == app.CronSourceConfig {
+		fmt.Println(dimStyle.Render("  the job is defined in yap.toml, remove it there too or the next deploy adds it back"))
+	}
+}
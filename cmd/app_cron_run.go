@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var appCronRunCmd = &cobra.Command{
+	Use:   "run [app-name] [job-name]",
+	Short: "Run a scheduled job now",
+	Long: `Run a scheduled job right away, outside of its schedule, and stream its output.
+
+The run is recorded in the job's history like a scheduled one, and is skipped when
+the job is already running. yap exits with the command's exit code.
+
+Examples:
+  yap app cron run myapp cleanup`,
+	Args: cobra.ExactArgs(2),
+	Run:  runAppCronRun,
+}
+
+func init() {
+	appCronCmd.AddCommand(appCronRunCmd)
+}
+
+func runAppCronRun(cmd *cobra.Command, args []string) {
+	appName := args[0]
+	jobName := args[1]
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application '%s' not found\n", errorStyle.Render("[error]"), appName)
+		os.Exit(1)
+	}
+
+	job := app.FindCronJob(application, jobName)
+	if job == nil {
+		fmt.Fprintf(os.Stderr, "%s job '%s' not found\n", errorStyle.Render("[error]"), jobName)
+		os.Exit(1)
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	// ctrl-c stops the run, RunTask still removes the container
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("==> running %s in %s (%s)", job.Name, appName, application.ImageID)))
+
+	run, err := app.RunCronJob(ctx, dockerClient, application, *job, app.CronTriggerManual, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if run.Error != "" {
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("==> %s: %s", run.Status, run.Error)))
+	}
+
+	stop()
+	switch {
+	case run.ExitCode > 0:
+		os.Exit(run.ExitCode)
+	case run.Status != app.CronRunSucceeded:
+		os.Exit(1)
+	}
+}
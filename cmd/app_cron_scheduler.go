@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appCronSchedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Run the scheduler that fires scheduled jobs",
+	Long: `Run the scheduler that fires every application's scheduled jobs when they come due.
+
+yap app cron add and deploys with [[cron]] jobs start it in the background, so this is
+only needed to run it under a service manager. Only one scheduler runs at a time, and
+it exits once no application has jobs left unless --keep-running is given.
+
+Examples:
+  yap app cron scheduler --keep-running`,
+	Args: cobra.NoArgs,
+	Run:  runAppCronScheduler,
+}
+
+var cronSchedulerKeepRunning bool
+
+func init() {
+	appCronCmd.AddCommand(appCronSchedulerCmd)
+
+	appCronSchedulerCmd.Flags().BoolVar(&cronSchedulerKeepRunning, "keep-running", false, "Keep running when no application has jobs")
+}
+
+var cronSchedulerLock = app.DaemonLockName("cron-scheduler")
+
+func runAppCronScheduler(cmd *cobra.Command, args []string) {
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(cronSchedulerLock, time.Second); err != nil {
+		// another scheduler already fires the jobs
+		return
+	}
+	defer lockManager.Unlock(cronSchedulerLock)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	defer dockerClient.Close()
+
+	// a stop cancels the running jobs, RunTask still removes their containers
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	logTimestampedf("scheduler started (pid %d)", os.Getpid())
+
+	var running sync.WaitGroup
+	lastTick := time.Now().Truncate(time.Minute)
+
+	for {
+		next := lastTick.Add(time.Minute)
+		select {
+		case <-ctx.Done():
+			logTimestampedf("scheduler stopping, waiting for running jobs")
+			running.Wait()
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		// a suspended machine wakes up hours later, fire the current minute and not every missed one
+		tick := time.Now().Truncate(time.Minute)
+		if tick.Before(next) {
+			tick = next
+		}
+		lastTick = tick
+
+		applications, err := registry.List()
+		if err != nil {
+			logTimestampedf("failed to read registry: %v", err)
+			continue
+		}
+
+		jobCount := 0
+		for i := range applications {
+			application := &applications[i]
+			jobCount += len(application.CronJobs)
+
+			for _, job := range application.CronJobs {
+				schedule, err := models.ParseSchedule(job.Schedule)
+				if err != nil {
+					logTimestampedf("%s/%s: %v", application.Name, job.Name, err)
+					continue
+				}
+				if !schedule.Matches(tick) {
+					continue
+				}
+
+				running.Add(1)
+				go func(application *models.Application, job models.CronJob) {
+					defer running.Done()
+					runScheduledJob(ctx, dockerClient, application, job)
+				}(application, job)
+			}
+		}
+
+		if jobCount == 0 && !cronSchedulerKeepRunning {
+			logTimestampedf("no scheduled jobs left, scheduler exiting")
+			running.Wait()
+			return
+		}
+	}
+}
+
+func runScheduledJob(ctx context.Context, dockerClient *docker.Client, application *models.Application, job models.CronJob) {
+	logTimestampedf("%s/%s: starting", application.Name, job.Name)
+
+	run, err := app.RunCronJob(ctx, dockerClient, application, job, app.CronTriggerSchedule, nil)
+	if err != nil {
+		logTimestampedf("%s/%s: %v", application.Name, job.Name, err)
+		return
+	}
+
+	if run.Error != "" {
+		logTimestampedf("%s/%s: %s (%s)", application.Name, job.Name, run.Status, run.Error)
+		return
+	}
+	logTimestampedf("%s/%s: %s in %s", application.Name, job.Name, run.Status, run.FinishedAt.Sub(run.StartedAt).Round(time.Second))
+}
@@ -3,7 +3,11 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aelpxy/yap/internal/app"
@@ -21,7 +25,7 @@ import (
 var appDeployCmd = &cobra.Command{
 	Use:   "deploy [name] [path]",
 	Short: "Deploy an application",
-	Long:  "Build and deploy an application from source code or Dockerfile",
+	Long:  "Build and deploy an application from source code or Dockerfile, from a git repository with --git, or deploy a prebuilt image with --image",
 	Args:  cobra.RangeArgs(1, 2),
 	Run:   runAppDeploy,
 }
@@ -35,12 +39,41 @@ var (
 	deployHealthPath     string
 	deployHealthInterval int
 	deployHealthTimeout  int
+	deployHealthType     string
+	deployHealthCommand  string
 	deployBuildMethod    string
+	deployDockerfile     string
+	deployContext        string
+	deployBuildArgs      []string
+	deployTarget         string
+	deployLabels         []string
+	deployBuilder        string
+	deployBuildpacks     []string
+	deployImage          string
+	deployGit            string
+	deployGitRef         string
+	deployPlanOnly       bool
+	deployOutput         string
 
 	deployStrategy        string
 	deployMaxSurge        int
 	deployRollingInterval int
 	deployAutoConfirm     bool
+
+	deployConfirmationTimeout int
+	deployConfirmationPolicy  string
+
+	deployCanaryInstances int
+	deployCanarySteps     []int
+	deployCanaryInterval  int
+
+	deployObserve int
+
+	deployStopSignal  string
+	deployStopTimeout int
+	deployDrainPeriod int
+
+	deployCrashLoopThreshold int
 )
 
 func init() {
@@ -54,12 +87,37 @@ func init() {
 	appDeployCmd.Flags().StringVar(&deployHealthPath, "health-path", "/health", "Health check endpoint")
 	appDeployCmd.Flags().IntVar(&deployHealthInterval, "health-interval", 10, "Health check interval in seconds")
 	appDeployCmd.Flags().IntVar(&deployHealthTimeout, "health-timeout", 5, "Health check timeout in seconds")
+	appDeployCmd.Flags().StringVar(&deployHealthType, "health-type", "http", "Health probe: http, tcp, exec or none")
+	appDeployCmd.Flags().StringVar(&deployHealthCommand, "health-command", "", "Exec probe: shell command run inside each instance, exit 0 is healthy")
 	appDeployCmd.Flags().StringVar(&deployBuildMethod, "build-method", "auto", "Build method: auto, dockerfile, nixpacks, paketo")
-
-	appDeployCmd.Flags().StringVar(&deployStrategy, "strategy", "recreate", "Deployment strategy: recreate, rolling, blue-green")
+	appDeployCmd.Flags().StringVar(&deployDockerfile, "dockerfile", "", "Dockerfile path relative to the project (default: Dockerfile in the context)")
+	appDeployCmd.Flags().StringVar(&deployContext, "context", "", "Subdirectory of the project to build")
+	appDeployCmd.Flags().StringArrayVar(&deployBuildArgs, "build-arg", nil, "Build arg KEY=VALUE ($VAR taken from the environment) or KEY to pass it through (repeatable)")
+	appDeployCmd.Flags().StringVar(&deployTarget, "target", "", "Dockerfile stage to build")
+	appDeployCmd.Flags().StringArrayVar(&deployLabels, "label", nil, "Image label KEY=VALUE (repeatable)")
+	appDeployCmd.Flags().StringVar(&deployBuilder, "builder", builder.DefaultPaketoBuilder, "Paketo: builder image")
+	appDeployCmd.Flags().StringSliceVar(&deployBuildpacks, "buildpack", nil, "Paketo: buildpack to run instead of the ones the builder detects (repeatable)")
+	appDeployCmd.Flags().StringVar(&deployImage, "image", "", "Deploy a prebuilt image (pulled if not present locally) instead of building")
+	appDeployCmd.Flags().StringVar(&deployGit, "git", "", "Build from a git repository (url or local path), path becomes a subdirectory of the repository")
+	appDeployCmd.Flags().StringVar(&deployGitRef, "ref", "", "Git branch, tag or commit to deploy (default: the repository's default branch)")
+	appDeployCmd.MarkFlagsMutuallyExclusive("git", "image")
+	appDeployCmd.Flags().StringVarP(&deployOutput, "output", "o", "text", "Progress output: text, or json for one event per line on stdout (everything else goes to stderr)")
+	appDeployCmd.Flags().BoolVar(&deployPlanOnly, "plan", false, "Show what the deploy would change without building or touching containers (exits 2 when there are changes)")
+
+	appDeployCmd.Flags().StringVar(&deployStrategy, "strategy", "recreate", "Deployment strategy: recreate, rolling, blue-green, canary")
 	appDeployCmd.Flags().IntVar(&deployMaxSurge, "max-surge", 1, "Rolling: deploy N instances at a time")
 	appDeployCmd.Flags().IntVar(&deployRollingInterval, "rolling-interval", 5, "Rolling: seconds between instance deployments")
 	appDeployCmd.Flags().BoolVar(&deployAutoConfirm, "auto-confirm", false, "Blue-green: auto-destroy old environment")
+	appDeployCmd.Flags().IntVar(&deployConfirmationTimeout, "confirmation-timeout", constants.DefaultConfirmationTimeout, "Blue-green: seconds before an unconfirmed deployment is settled automatically (0 = wait forever)")
+	appDeployCmd.Flags().StringVar(&deployConfirmationPolicy, "confirmation-policy", "confirm", "Blue-green: what to do when the confirmation timeout runs out: confirm (if healthy) or revert")
+	appDeployCmd.Flags().IntVar(&deployCanaryInstances, "canary-instances", 1, "Canary: number of canary instances")
+	appDeployCmd.Flags().IntSliceVar(&deployCanarySteps, "canary-steps", []int{10, 50, 100}, "Canary: traffic percentages to step through")
+	appDeployCmd.Flags().IntVar(&deployCanaryInterval, "canary-interval", 60, "Canary: seconds to observe each step")
+	appDeployCmd.Flags().StringVar(&deployStopSignal, "stop-signal", "SIGTERM", "Signal sent to instances when they stop")
+	appDeployCmd.Flags().IntVar(&deployStopTimeout, "stop-timeout", constants.DefaultStopTimeout, "Seconds an instance gets to exit after the stop signal before it is killed")
+	appDeployCmd.Flags().IntVar(&deployDrainPeriod, "drain-period", constants.DefaultDrainPeriod, "Seconds an instance is taken out of the load balancer before it is stopped (0 = no draining)")
+	appDeployCmd.Flags().IntVar(&deployCrashLoopThreshold, "crash-loop-threshold", constants.DefaultCrashLoopThreshold, "Restarts within 5 minutes before an instance is stopped and started again with a backoff")
+	appDeployCmd.Flags().IntVar(&deployObserve, "observe", 0, "Seconds to watch the new release and roll back automatically if it turns unhealthy (0 = disabled)")
 }
 
 func runAppDeploy(cmd *cobra.Command, args []string) {
@@ -69,61 +127,100 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 		projectPath = args[1]
 	}
 
+	if deployGitRef != "" && deployGit == "" {
+		fmt.Fprintf(os.Stderr, "%s --ref requires --git\n", errorStyle.Render("[error]"))
+		deployExit(1)
+	}
+
+	var events app.EventSink
+	var output io.Writer
+	switch deployOutput {
+	case "text":
+		events = app.NewTerminalEventSink(os.Stdout)
+		output = os.Stdout
+	case "json":
+		// stdout only carries events, so pipelines can read it line by line
+		events = app.NewJSONEventSink(os.Stdout)
+		output = os.Stderr
+	default:
+		fmt.Fprintf(os.Stderr, "%s unknown output format: %s (must be text or json)\n", errorStyle.Render("[error]"), deployOutput)
+		deployExit(1)
+	}
+
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	var gitCheckout *builder.GitCheckout
+	if deployGit != "" {
+		fmt.Fprintln(output, progressStyle.Render("  --> checking out source..."))
+		checkout, err := builder.CheckoutGit(ctx, deployGit, deployGitRef, output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+			deployExit(1)
+		}
+		defer checkout.Cleanup()
+		deployCleanups = append(deployCleanups, checkout.Cleanup)
+		gitCheckout = checkout
+		fmt.Fprintln(output)
+
+		projectPath = filepath.Join(gitCheckout.Dir, projectPath)
+	}
+
 	absPath, err := utils.ValidateProjectPath(projectPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	if appName == "" || len(appName) == 0 {
 		fmt.Fprintf(os.Stderr, "%s invalid application name: name is required\n", errorStyle.Render("[error]"))
-		os.Exit(1)
+		deployExit(1)
 	}
 	if len(appName) > constants.MaxNameLength {
 		fmt.Fprintf(os.Stderr, "%s invalid application name: maximum %d characters\n", errorStyle.Render("[error]"), constants.MaxNameLength)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if !utils.IsValidName(appName) {
 		fmt.Fprintf(os.Stderr, "%s invalid application name: use only lowercase letters, numbers, and dashes\n", errorStyle.Render("[error]"))
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployPort < constants.MinPort || deployPort > constants.MaxPort {
 		fmt.Fprintf(os.Stderr, "%s invalid port: must be between %d and %d\n", errorStyle.Render("[error]"), constants.MinPort, constants.MaxPort)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployMemory < constants.MinMemoryMB {
 		fmt.Fprintf(os.Stderr, "%s invalid memory: must be at least %dMB\n", errorStyle.Render("[error]"), constants.MinMemoryMB)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployMemory > constants.MaxMemoryMB {
 		fmt.Fprintf(os.Stderr, "%s invalid memory: maximum %dMB (%dGB)\n", errorStyle.Render("[error]"), constants.MaxMemoryMB, constants.MaxMemoryMB/1024)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployCPU < constants.MinCPUCores {
 		fmt.Fprintf(os.Stderr, "%s invalid cpu: must be at least %d core\n", errorStyle.Render("[error]"), constants.MinCPUCores)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployCPU > constants.MaxCPUCores {
 		fmt.Fprintf(os.Stderr, "%s invalid cpu: maximum %d cores\n", errorStyle.Render("[error]"), constants.MaxCPUCores)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployInstances < constants.MinInstances {
 		fmt.Fprintf(os.Stderr, "%s invalid instance count: minimum %d required\n", errorStyle.Render("[error]"), constants.MinInstances)
-		os.Exit(1)
+		deployExit(1)
 	}
 	if deployInstances > constants.MaxInstances {
 		fmt.Fprintf(os.Stderr, "%s invalid instance count: maximum %d instances\n", errorStyle.Render("[error]"), constants.MaxInstances)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	project, err := project.LoadConfigIfExists(absPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to load yap.toml: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	if project != nil {
-		fmt.Println(infoStyle.Render("  [info] loaded configuration from yap.toml"))
+		fmt.Fprintln(output, infoStyle.Render("  [info] loaded configuration from yap.toml"))
 
 		if project.App.Name != "" && !cmd.Flags().Changed("name") {
 			appName = project.App.Name
@@ -141,6 +238,12 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 		if !cmd.Flags().Changed("port") && project.Deploy.Port != 0 {
 			deployPort = project.Deploy.Port
 		}
+		if !cmd.Flags().Changed("build-method") && project.Build.Buildpacks {
+			deployBuildMethod = "paketo"
+		}
+		if !cmd.Flags().Changed("image") && project.Deploy.Image != "" {
+			deployImage = project.Deploy.Image
+		}
 		if !cmd.Flags().Changed("health-path") && project.Deploy.HealthCheck.Path != "" {
 			deployHealthPath = project.Deploy.HealthCheck.Path
 		}
@@ -150,6 +253,9 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 		if !cmd.Flags().Changed("health-timeout") {
 			deployHealthTimeout = project.Deploy.HealthCheck.Timeout
 		}
+		if !cmd.Flags().Changed("health-type") {
+			deployHealthType = project.Deploy.HealthCheck.Type
+		}
 		if !cmd.Flags().Changed("strategy") && project.Deployment.Strategy != "" {
 			deployStrategy = project.Deployment.Strategy
 		}
@@ -162,138 +268,762 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 		if !cmd.Flags().Changed("auto-confirm") {
 			deployAutoConfirm = project.Deployment.AutoConfirm
 		}
+		if !cmd.Flags().Changed("confirmation-timeout") {
+			deployConfirmationTimeout = *project.Deployment.ConfirmationTimeout
+		}
+		if !cmd.Flags().Changed("confirmation-policy") {
+			deployConfirmationPolicy = project.Deployment.ConfirmationPolicy
+		}
+		if !cmd.Flags().Changed("canary-instances") {
+			deployCanaryInstances = project.Deployment.CanaryInstances
+		}
+		if !cmd.Flags().Changed("canary-steps") {
+			deployCanarySteps = project.Deployment.CanarySteps
+		}
+		if !cmd.Flags().Changed("canary-interval") {
+			deployCanaryInterval = project.Deployment.CanaryInterval
+		}
+		if !cmd.Flags().Changed("observe") {
+			deployObserve = project.Deployment.ObservationWindow
+		}
+		if !cmd.Flags().Changed("stop-signal") {
+			deployStopSignal = project.Deploy.StopSignal
+		}
+		if !cmd.Flags().Changed("stop-timeout") {
+			deployStopTimeout = project.Deploy.StopTimeout
+		}
+		if !cmd.Flags().Changed("drain-period") {
+			deployDrainPeriod = *project.Deploy.DrainPeriod
+		}
+		if !cmd.Flags().Changed("crash-loop-threshold") {
+			deployCrashLoopThreshold = project.Deploy.CrashLoopThreshold
+		}
+	}
+
+	processes, err := loadDeployProcesses(project, absPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s invalid processes: %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+	if project != nil && project.Deploy.AutoScaling && project.Scaling.MaxInstances > constants.MaxInstances {
+		fmt.Fprintf(os.Stderr, "%s invalid scaling: max_instances can be at most %d\n", errorStyle.Render("[error]"), constants.MaxInstances)
+		deployExit(1)
+	}
+	if web, ok := processes[models.ProcessWeb]; ok {
+		if web.Instances > 0 && !cmd.Flags().Changed("instances") {
+			deployInstances = web.Instances
+		}
+		if web.Memory != "" && !cmd.Flags().Changed("memory") {
+			deployMemory = models.ParseMemory(web.Memory)
+		}
+		if web.CPU > 0 && !cmd.Flags().Changed("cpu") {
+			deployCPU = web.CPU
+		}
+	}
+
+	buildOptions, err := deployBuildOptions(cmd, project)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	if deployConfirmationPolicy != app.ConfirmationPolicyConfirm && deployConfirmationPolicy != app.ConfirmationPolicyRevert {
+		fmt.Fprintf(os.Stderr, "%s unknown confirmation policy: %s\n", errorStyle.Render("[error]"), deployConfirmationPolicy)
+		fmt.Fprintln(output, dimStyle.Render("  valid policies: confirm, revert"))
+		deployExit(1)
+	}
+
+	if err := models.ValidateCanarySteps(deployCanarySteps); err != nil {
+		fmt.Fprintf(os.Stderr, "%s invalid canary steps: %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	if err := models.ValidateStopSignal(deployStopSignal); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+	if deployStopTimeout < 1 {
+		fmt.Fprintf(os.Stderr, "%s invalid stop timeout: must be at least 1 second\n", errorStyle.Render("[error]"))
+		deployExit(1)
+	}
+	if deployDrainPeriod < 0 {
+		fmt.Fprintf(os.Stderr, "%s invalid drain period: cannot be negative\n", errorStyle.Render("[error]"))
+		deployExit(1)
+	}
+	if deployCrashLoopThreshold < 1 {
+		fmt.Fprintf(os.Stderr, "%s invalid crash loop threshold: must be at least 1 restart\n", errorStyle.Render("[error]"))
+		deployExit(1)
+	}
+
+	if _, _, err := deployHealthProbes(cmd, project); err != nil {
+		fmt.Fprintf(os.Stderr, "%s invalid health check: %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	if deployPlanOnly {
+		if runDeployPlan(cmd, output, appName, project, processes, absPath, gitCheckout) {
+			deployExit(planChangesExitCode)
+		}
+		return
 	}
 
 	lockManager := app.GetGlobalLockManager()
 	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
 		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
-		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
-		os.Exit(1)
+		fmt.Fprintln(output, dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		deployExit(1)
 	}
+	releaseLock := sync.OnceFunc(func() { lockManager.Unlock(appName) })
+	deployCleanups = append(deployCleanups, releaseLock)
 
-	fmt.Println(titleStyle.Render(fmt.Sprintf("==> deploying application: %s", appName)))
-	fmt.Println()
+	fmt.Fprintln(output, titleStyle.Render(fmt.Sprintf("==> deploying application: %s", appName)))
+	fmt.Fprintln(output)
 
 	dockerClient, err := docker.NewClient()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	registry, err := app.NewRegistryManager()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	if err := registry.Initialize(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	existingApp, err := registry.Get(appName)
 	isRedeployment := (err == nil && existingApp != nil)
+	if !isRedeployment {
+		existingApp = nil
+	}
+
+	releaseVersion := 1
+	if isRedeployment {
+		releaseVersion = app.NextReleaseVersion(existingApp)
+	}
 
-	fmt.Println(progressStyle.Render("  --> building application..."))
-	fmt.Println()
+	var hooks models.HooksConfig
+	if project != nil {
+		hooks = project.Hooks
+	}
 
-	b := builder.NewBuilder(dockerClient)
-	buildResult, err := b.BuildWithMethod(absPath, appName, deployBuildMethod, os.Stdout)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "\n%s build failed: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+	// filled in as the deploy goes, a redeploy that fails keeps it in the history as a failed release
+	deploymentRecord := app.NewDeploymentRecord(releaseVersion, "", "", "")
+	if gitCheckout != nil {
+		deploymentRecord.Source = gitCheckout.URL
+		deploymentRecord.GitRef = gitCheckout.Ref
+		deploymentRecord.GitCommit = gitCheckout.Commit
+		deploymentRecord.GitBranch = gitCheckout.Branch
+	} else if deployImage == "" {
+		deploymentRecord.GitCommit, deploymentRecord.GitBranch = builder.LocalGitInfo(ctx, absPath)
+	}
+	if isRedeployment {
+		deploymentRecord.Strategy = existingApp.DeploymentStrategy
 	}
+	buildStarted := time.Now()
+
+	b := builder.NewBuilder(dockerClient)
+	var buildResult *builder.BuildResult
+
+	if deployImage != "" {
+		fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> preparing image (release v%d)...", releaseVersion)))
+		fmt.Fprintln(output)
+
+		if hooks.PreBuild != "" || hooks.PostBuild != "" {
+			fmt.Fprintln(output, dimStyle.Render("    skipping build hooks for a prebuilt image"))
+		}
 
-	fmt.Println()
-	fmt.Println(successStyle.Render("  [done] build completed"))
-	fmt.Printf("    image: %s\n", dimStyle.Render(buildResult.ImageName))
-	fmt.Printf("    id: %s\n", dimStyle.Render(utils.TruncateID(buildResult.ImageID, 12)))
-	fmt.Println()
+		buildResult, err = b.UseImage(ctx, deployImage, appName, releaseVersion, output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s failed to prepare image: %v\n", errorStyle.Render("[error]"), err)
+			reportFailedDeploy(events, registry, appName, existingApp, deploymentRecord, fmt.Errorf("failed to prepare image: %w", err))
+			exitDeploy(ctx, appName)
+		}
+
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, successStyle.Render("  [done] image ready"))
+		fmt.Fprintf(output, "    source: %s\n", dimStyle.Render(buildResult.Source))
+	} else {
+		fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> building application (release v%d)...", releaseVersion)))
+		fmt.Fprintln(output)
+
+		if hooks.PreBuild != "" {
+			fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> running prebuild hook: %s", hooks.PreBuild)))
+			if err := app.RunHostHook(ctx, app.HookPreBuild, hooks.PreBuild, absPath, output); err != nil {
+				fmt.Fprintf(os.Stderr, "\n%s %v\n", errorStyle.Render("[error]"), err)
+				reportFailedDeploy(events, registry, appName, existingApp, deploymentRecord, err)
+				exitDeploy(ctx, appName)
+			}
+			fmt.Fprintln(output)
+		}
+
+		buildResult, err = b.BuildWithMethod(ctx, absPath, appName, releaseVersion, deployBuildMethod, buildOptions, output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s build failed: %v\n", errorStyle.Render("[error]"), err)
+			deploymentRecord.BuildDuration = time.Since(buildStarted)
+			reportFailedDeploy(events, registry, appName, existingApp, deploymentRecord, fmt.Errorf("build failed: %w", err))
+			exitDeploy(ctx, appName)
+		}
+
+		if hooks.PostBuild != "" {
+			fmt.Fprintln(output)
+			fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> running postbuild hook: %s", hooks.PostBuild)))
+			if err := app.RunHostHook(ctx, app.HookPostBuild, hooks.PostBuild, absPath, output); err != nil {
+				fmt.Fprintf(os.Stderr, "\n%s %v\n", errorStyle.Render("[error]"), err)
+				reportFailedDeploy(events, registry, appName, existingApp, deploymentRecord, err)
+				exitDeploy(ctx, appName)
+			}
+		}
+
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, successStyle.Render("  [done] build completed"))
+	}
+	fmt.Fprintf(output, "    image: %s\n", dimStyle.Render(buildResult.ImageName))
+	fmt.Fprintf(output, "    id: %s\n", dimStyle.Render(utils.TruncateID(buildResult.ImageID, 12)))
+	fmt.Fprintln(output)
+
+	deploymentRecord.ImageID = buildResult.ImageID
+	deploymentRecord.ImageTag = buildResult.ImageName
+	deploymentRecord.BuildMethod = buildResult.BuildType
+	deploymentRecord.BuildDuration = time.Since(buildStarted)
+	if buildResult.Source != "" {
+		deploymentRecord.Source = buildResult.Source
+	}
+	emitDeployEvent(events, appName, app.DeploymentEvent{
+		Type:    app.EventBuildCompleted,
+		Message: fmt.Sprintf("image %s ready", buildResult.ImageName),
+		Version: releaseVersion,
+	})
 
 	port := deployPort
+	if port == 0 && buildResult.Port > 0 {
+		port = buildResult.Port
+		fmt.Fprintln(output, infoStyle.Render(fmt.Sprintf("  [info] image exposes port: %d", port)))
+	}
 	if port == 0 {
 		port = builder.GetDefaultPort(buildResult.Language)
-		fmt.Println(infoStyle.Render(fmt.Sprintf("  [info] detected port: %d", port)))
+		fmt.Fprintln(output, infoStyle.Render(fmt.Sprintf("  [info] detected port: %d", port)))
 	}
 
-	fmt.Println(progressStyle.Render("  --> preparing load balancer..."))
+	fmt.Fprintln(output, progressStyle.Render("  --> preparing load balancer..."))
 	traefik := router.NewTraefikManager(dockerClient)
 
 	running, err := traefik.IsRunning()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to check load balancer: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	if !running {
-		fmt.Println(progressStyle.Render("  --> starting load balancer..."))
-		if err := traefik.Start(os.Stdout); err != nil {
+		fmt.Fprintln(output, progressStyle.Render("  --> starting load balancer..."))
+		if err := traefik.Start(output); err != nil {
 			fmt.Fprintf(os.Stderr, "%s failed to start load balancer: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
+			deployExit(1)
 		}
 	} else {
-		fmt.Println(dimStyle.Render("    load balancer already running"))
+		fmt.Fprintln(output, dimStyle.Render("    load balancer already running"))
 	}
 
-	fmt.Println(progressStyle.Render(fmt.Sprintf("  --> configuring network (vpc: %s)...", deployVPC)))
+	fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> configuring network (vpc: %s)...", deployVPC)))
 	vpcRegistry, err := database.NewVPCRegistryManager()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to load vpc registry: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	if err := vpcRegistry.Initialize(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to initialize vpc registry: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
 	vpc, err := vpcRegistry.Get(deployVPC)
 	if err != nil {
-		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> creating vpc: %s...", deployVPC)))
+		fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> creating vpc: %s...", deployVPC)))
 		vpc, err = dockerClient.CreateVPC(deployVPC)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s failed to create vpc: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
+			deployExit(1)
 		}
 		if err := vpcRegistry.Add(*vpc); err != nil {
 			fmt.Fprintf(os.Stderr, "%s failed to register vpc: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
+			deployExit(1)
 		}
 	}
 
 	if err := traefik.ConnectToVPC(vpc.NetworkName); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to connect load balancer to vpc: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+		deployExit(1)
 	}
 
-	fmt.Println(successStyle.Render("  [done] network configured"))
+	fmt.Fprintln(output, successStyle.Render("  [done] network configured"))
 
-	var strategy models.DeploymentStrategy
-	var application *models.Application
+	application, err := desiredApplication(cmd, existingApp, project, processes, appName, port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		fmt.Fprintln(output, dimStyle.Render("  valid strategies: recreate, rolling, blue-green, canary"))
+		deployExit(1)
+	}
+	strategy := application.DeploymentStrategy
+	appID := application.ID
+	deploymentRecord.Strategy = strategy
+	deploymentRecord.Config = app.SnapshotConfig(application)
 
+	application.BuildType = buildResult.BuildType
+	application.Buildpack = buildResult.Buildpack
 	if isRedeployment {
-		application = existingApp
+		application.UpdatedAt = time.Now()
+
+		fmt.Fprintln(output, infoStyle.Render(fmt.Sprintf("  [info] re-deploying with strategy: %s", strategy)))
+		fmt.Fprintln(output)
+	} else {
+		application.ImageID = buildResult.ImageID
+	}
+
+	if hooks.PreDeploy != "" {
+		fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> running predeploy hook: %s", hooks.PreDeploy)))
+		if err := app.RunReleaseHook(ctx, dockerClient, application, buildResult.ImageName, app.HookPreDeploy, hooks.PreDeploy, output); err != nil {
+			fmt.Fprintf(os.Stderr, "\n%s %v\n", errorStyle.Render("[error]"), err)
+			fmt.Fprintln(output, dimStyle.Render("  deployment aborted, running instances were not touched"))
+			reportFailedDeploy(events, registry, appName, existingApp, deploymentRecord, err)
+			exitDeploy(ctx, appName)
+		}
+		fmt.Fprintln(output, successStyle.Render("  [done] predeploy hook completed"))
+		fmt.Fprintln(output)
+	}
+
+	traefikLabels := traefik.GenerateLabelsForApp(application)
+
+	deployer, err := app.NewDeployer(strategy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to create deployer: %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	deployOpts := app.DeploymentOptions{
+		App:           application,
+		SourcePath:    absPath,
+		NewImageID:    buildResult.ImageName,
+		Config:        application.DeploymentConfig,
+		VPCName:       deployVPC,
+		TraefikLabels: traefikLabels,
+		MemoryMB:      deployMemory,
+		CPUCores:      deployCPU,
+		Events:        events,
+	}
+
+	imageID, err := deployer.Deploy(ctx, deployOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%s deployment failed: %v\n", errorStyle.Render("[error]"), err)
+		if isRedeployment {
+			app.RecordFailedRelease(existingApp, deploymentRecord, err)
+		}
+		restoreAfterFailedDeploy(output, dockerClient, registry, existingApp, application)
+		exitDeploy(ctx, appName)
+	}
+
+	// an app that moved off blue-green is routed by its instances' labels again
+	if strategy != models.DeploymentStrategyBlueGreen {
+		if err := traefik.ClearColorRouting(appName); err != nil {
+			fmt.Fprintf(output, "  [warn] %v\n", err)
+		}
+	}
+
+	// web is live on the new image, the other processes follow it there
+	processErr := app.DeployProcesses(ctx, dockerClient, deployOpts)
+	if err := app.RemoveProcesses(dockerClient, application, removedProcesses(existingApp, application), events); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to remove dropped processes: %v\n", err)
+	}
+
+	application.ImageID = imageID
+	application.Status = models.AppStatusRunning
+	app.ClearCrashLoop(application)
+
+	deploymentRecord.DeployedAt = time.Now()
+
+	if isRedeployment {
+		fmt.Fprintln(output, progressStyle.Render("  --> updating application..."))
+
+		app.RecordRelease(application, deploymentRecord, app.DeploymentStatusSuperseded)
+		application.LastDeployedAt = time.Now()
+
+		if err := registry.Update(*application); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed to update application: %v\n", errorStyle.Render("[error]"), err)
+			deployExit(1)
+		}
+
+		fmt.Fprintln(output, successStyle.Render("  [done] application updated"))
+	} else {
+		fmt.Fprintln(output, progressStyle.Render("  --> registering application..."))
+
+		application.InternalHostname = fmt.Sprintf("yap-app-%s", appName)
+		application.Published = false
+		application.PublishedURL = fmt.Sprintf("http://%s.yap.local", appName)
+
+		app.RecordRelease(application, deploymentRecord, app.DeploymentStatusSuperseded)
+
+		if err := registry.Add(*application); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed to register application: %v\n", errorStyle.Render("[error]"), err)
+			removeFailedFirstDeploy(output, dockerClient, application)
+			deployExit(1)
+		}
+
+		fmt.Fprintln(output, successStyle.Render("  [done] application registered"))
+	}
+	fmt.Fprintln(output)
+
+	emitDeployEvent(events, appName, app.DeploymentEvent{
+		Type:    app.EventReleaseRecorded,
+		Message: fmt.Sprintf("release v%d is live", releaseVersion),
+		Version: releaseVersion,
+	})
+
+	if processErr != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), processErr)
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  web is live on release v%d, redeploy once the process is fixed", releaseVersion)))
+		exitDeploy(ctx, appName)
+	}
+
+	if deployOutput == "text" && strategy == models.DeploymentStrategyBlueGreen && app.HasStandby(application) {
+		printBlueGreenNextSteps(output, application)
+	}
+
+	if window := application.DeploymentConfig.ObservationWindow; window > 0 {
+		if !observeDeployment(ctx, output, dockerClient, registry, application, releaseVersion, time.Duration(window)*time.Second) {
+			exitDeploy(ctx, appName)
+		}
+	}
+
+	if hooks.PostDeploy != "" {
+		fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> running postdeploy hook: %s", hooks.PostDeploy)))
+		if err := app.RunReleaseHook(ctx, dockerClient, application, buildResult.ImageName, app.HookPostDeploy, hooks.PostDeploy, output); err != nil {
+			fmt.Fprintf(output, "  [warn] %v\n", err)
+			fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  release v%d is live, the hook did not roll it back", releaseVersion)))
+		} else {
+			fmt.Fprintln(output, successStyle.Render("  [done] postdeploy hook completed"))
+		}
+		fmt.Fprintln(output)
+	}
+
+	maybeScheduleConfirmationTimeout(output, application)
+	maybeEnsureCronScheduler(output, application)
+	maybeEnsureAutoscaler(output, application)
+	maybeEnsureWatchdog(output, application)
+
+	// unlock early because volumes like to fight for their own locks (they're rebellious like that)
+	releaseLock()
+
+	if project != nil && len(project.Volumes) > 0 && !isRedeployment {
+		fmt.Fprintln(output, infoStyle.Render("  [info] adding volumes from yap.toml..."))
+
+		volumeManager := app.NewVolumeManager(dockerClient, registry)
+		ctx := context.Background()
+
+		for volumeName, mountPath := range project.Volumes {
+			vol := models.Volume{
+				Name:      volumeName,
+				MountPath: mountPath,
+				Type:      "volume",
+			}
+
+			if err := volumeManager.AddVolume(ctx, appName, vol); err != nil {
+				fmt.Fprintf(os.Stderr, "%s failed to add volume %s: %v\n", errorStyle.Render("[error]"), volumeName, err)
+				continue
+			}
+			fmt.Fprintf(output, "    added volume: %s -> %s\n", dimStyle.Render(volumeName), dimStyle.Render(mountPath))
+		}
+
+		if len(project.Volumes) > 0 {
+			fmt.Fprintln(output)
+			fmt.Fprintln(output, infoStyle.Render("  [info] volumes added - redeploy to mount them"))
+			fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  run 'yap app deploy %s' to mount volumes", appName)))
+			fmt.Fprintln(output)
+		}
+	}
+
+	fmt.Fprintln(output, successStyle.Render(fmt.Sprintf("  [done] %s deployed successfully", appName)))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, titleStyle.Render("  application details:"))
+	fmt.Fprintf(output, "    name: %s\n", valueStyle.Render(appName))
+	fmt.Fprintf(output, "    id: %s\n", dimStyle.Render(appID))
+	fmt.Fprintf(output, "    release: %s\n", valueStyle.Render(fmt.Sprintf("v%d", releaseVersion)))
+	if gitCheckout != nil {
+		fmt.Fprintf(output, "    commit: %s\n", valueStyle.Render(utils.TruncateID(gitCheckout.Commit, 12)))
+	}
+	fmt.Fprintf(output, "    vpc: %s\n", valueStyle.Render(deployVPC))
+	fmt.Fprintf(output, "    instances: %s\n", valueStyle.Render(fmt.Sprintf("%d", deployInstances)))
+	fmt.Fprintf(output, "    memory: %s\n", valueStyle.Render(fmt.Sprintf("%d MB", deployMemory)))
+	fmt.Fprintf(output, "    cpu: %s\n", valueStyle.Render(fmt.Sprintf("%.1f", deployCPU)))
+	fmt.Fprintf(output, "    strategy: %s\n", valueStyle.Render(string(strategy)))
+	for _, process := range application.Processes {
+		fmt.Fprintf(output, "    %s: %s\n", process.Name, valueStyle.Render(describeProcess(application, process)))
+	}
+	if len(application.CronJobs) > 0 {
+		fmt.Fprintf(output, "    cron jobs: %s\n", valueStyle.Render(fmt.Sprintf("%d", len(application.CronJobs))))
+	}
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, titleStyle.Render("  access:"))
+	fmt.Fprintf(output, "    url: %s\n", valueStyle.Render(fmt.Sprintf("http://%s.yap.local", appName)))
+	fmt.Fprintf(output, "    internal: %s\n", dimStyle.Render(fmt.Sprintf("yap-app-%s:%d", appName, port)))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, titleStyle.Render("  next steps:"))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "  "+dimStyle.Render("test your app:"))
+	fmt.Fprintln(output, "  "+infoStyle.Render(fmt.Sprintf("    curl -H \"Host: %s.yap.local\" http://localhost", appName)))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "  "+dimStyle.Render("monitor and debug:"))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app logs %s [-f]", appName)))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app status %s", appName)))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "  "+dimStyle.Render("scale your app:"))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app scale %s --instances 3", appName)))
+	for _, process := range application.Processes {
+		fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app scale %s %s=2", appName, process.Name)))
+	}
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "  "+dimStyle.Render("add environment variables:"))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app env set %s KEY=value", appName)))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app env import %s .env", appName)))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "  "+dimStyle.Render("link a database:"))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap db create postgres mydb --vpc %s", deployVPC)))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app link %s mydb", appName)))
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, "  "+dimStyle.Render("add persistent storage:"))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app volume add %s data /app/data", appName)))
+	fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app deploy %s   # redeploy to mount volume", appName)))
+
+	if !application.Published {
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "  "+dimStyle.Render("publish with https:"))
+		fmt.Fprintln(output, "  "+dimStyle.Render("    yap config setup"))
+		fmt.Fprintln(output, "  "+dimStyle.Render(fmt.Sprintf("    yap app publish %s", appName)))
+	}
+
+	if strategy == models.DeploymentStrategyBlueGreen && !deployAutoConfirm {
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, labelStyle.Render("  blue-green deployment:"))
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("    yap app deployment status %s   # view deployment state", appName)))
+	}
+}
+
+// watches the new release and puts the previous one back if it goes bad, returns false if the release failed
+func observeDeployment(ctx context.Context, output io.Writer, dockerClient *docker.Client, registry *app.RegistryManager, application *models.Application, version int, window time.Duration) bool {
+	fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> observing release v%d for %ds...", version, int(window.Seconds()))))
+
+	observeErr := app.ObserveRelease(ctx, dockerClient, application, window)
+	if observeErr == nil {
+		fmt.Fprintln(output, successStyle.Render(fmt.Sprintf("  [done] release v%d stayed healthy", version)))
+		fmt.Fprintln(output)
+		return true
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  observation interrupted, release v%d stays live", version)))
+		return false
+	}
+
+	// once started the rollback has to finish, an interrupt halfway would leave neither release serving
+	ctx = context.WithoutCancel(ctx)
+
+	fmt.Fprintf(os.Stderr, "%s release v%d became unhealthy: %v\n", errorStyle.Render("[error]"), version, observeErr)
+	fmt.Fprintln(output)
+
+	failed, err := app.FindRelease(application, version)
+	if err == nil {
+		failed.FailureReason = observeErr.Error()
+	}
+
+	previous, previousVersion := app.PreviousRelease(application, version)
+	if previous == nil {
+		if failed != nil {
+			failed.Status = app.DeploymentStatusFailed
+		}
+		application.Status = models.AppStatusFailed
+		registry.Update(*application)
+
+		fmt.Fprintln(output, dimStyle.Render("  no previous release to roll back to"))
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  check 'yap app logs %s' for details", application.Name)))
+		return false
+	}
+
+	fmt.Fprintln(output, progressStyle.Render(fmt.Sprintf("  --> rolling back to release v%d...", previousVersion)))
+
+	imageID, err := app.RedeployRelease(ctx, dockerClient, application, previous)
+	if err != nil {
+		if failed != nil {
+			failed.Status = app.DeploymentStatusFailed
+		}
+		application.Status = models.AppStatusFailed
+		registry.Update(*application)
+
+		fmt.Fprintf(os.Stderr, "%s automatic rollback failed: %v\n", errorStyle.Render("[error]"), err)
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  run 'yap app rollback %s --version %d' once the issue is fixed", application.Name, previousVersion)))
+		return false
+	}
+
+	rollbackRecord := app.NewRedeployRecord(application, previous)
+	app.RecordRelease(application, rollbackRecord, app.DeploymentStatusFailed)
+
+	application.ImageID = imageID
+	application.Status = models.AppStatusRunning
+	application.UpdatedAt = time.Now()
+	application.LastDeployedAt = time.Now()
+
+	if err := registry.Update(*application); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		return false
+	}
+
+	fmt.Fprintln(output, successStyle.Render(fmt.Sprintf("  [done] rolled back to release v%d (new release v%d)", previousVersion, rollbackRecord.Version)))
+	fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  release v%d marked as failed: %s", version, observeErr)))
+	maybeScheduleConfirmationTimeout(output, application)
+	return false
+}
+
+func emitDeployEvent(events app.EventSink, appName string, event app.DeploymentEvent) {
+	event.Time = time.Now()
+	event.App = appName
+	events.Emit(event)
+}
+
+// reports a deploy that failed before touching any instance, and keeps it in the history of a
+// redeploy. a first deploy has nothing registered to keep it in
+func reportFailedDeploy(events app.EventSink, registry *app.RegistryManager, appName string, existingApp *models.Application, record models.DeploymentRecord, reason error) {
+	emitDeployEvent(events, appName, app.DeploymentEvent{
+		Type:    app.EventDeployFailed,
+		Message: "deployment failed",
+		Version: record.Version,
+		Error:   reason.Error(),
+	})
+
+	if existingApp == nil {
+		return
+	}
+
+	app.RecordFailedRelease(existingApp, record, reason)
+	if err := registry.Update(*existingApp); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to record release: %v\n", errorStyle.Render("[error]"), err)
+	}
+}
+
+// the strategies remove what they created on failure, but instances they already replaced are gone,
+// so the stored application is pointed at whatever is actually running now
+func restoreAfterFailedDeploy(output io.Writer, dockerClient *docker.Client, registry *app.RegistryManager, existingApp, application *models.Application) {
+	// a first deploy has nothing to fall back to, whatever it started would hold the fixed
+	// instance names and make the next deploy fail on a name conflict
+	if existingApp == nil {
+		removeFailedFirstDeploy(output, dockerClient, application)
+		return
+	}
+
+	existingApp.ContainerIDs = application.ContainerIDs
+	existingApp.DeploymentState = application.DeploymentState
+	existingApp.Status = models.AppStatusRunning
+	if len(existingApp.ContainerIDs) == 0 {
+		existingApp.Status = models.AppStatusFailed
+	}
+	existingApp.UpdatedAt = time.Now()
+
+	if err := registry.Update(*existingApp); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		return
+	}
+
+	if existingApp.Status == models.AppStatusFailed {
+		fmt.Fprintln(output, dimStyle.Render("  no instances left running, redeploy once the issue is fixed"))
+	} else {
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  %d instance(s) still running", len(existingApp.ContainerIDs))))
+	}
+}
+
+func removeFailedFirstDeploy(output io.Writer, dockerClient *docker.Client, application *models.Application) {
+	containerIDs := app.AllContainerIDs(application)
+	for _, env := range []*models.Environment{application.DeploymentState.Blue, application.DeploymentState.Green} {
+		if env != nil {
+			containerIDs = append(containerIDs, env.ContainerIDs...)
+		}
+	}
+
+	seen := make(map[string]bool, len(containerIDs))
+	removed := 0
+	for _, containerID := range containerIDs {
+		if containerID == "" || seen[containerID] {
+			continue
+		}
+		seen[containerID] = true
+
+		if err := dockerClient.RemoveContainer(containerID); err != nil {
+			fmt.Fprintf(output, "  [warn] failed to remove %s: %v\n", utils.TruncateID(containerID, 12), err)
+			continue
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  removed %d instance(s) of the failed deploy", removed)))
+	}
+}
+
+func printBlueGreenNextSteps(output io.Writer, application *models.Application) {
+	fmt.Fprintln(output, dimStyle.Render("  to complete deployment:"))
+	fmt.Fprintf(output, "    yap app deployment confirm %s\n", application.Name)
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, dimStyle.Render("  to rollback:"))
+	fmt.Fprintf(output, "    yap app deployment rollback %s\n", application.Name)
+	fmt.Fprintln(output)
+	fmt.Fprintln(output, dimStyle.Render("  to switch back instantly (keeps both environments):"))
+	fmt.Fprintf(output, "    yap app deployment switch %s\n", application.Name)
+
+	if timeout := application.DeploymentConfig.ConfirmationTimeout; timeout > 0 {
+		policy := application.DeploymentConfig.ConfirmationPolicy
+		if policy == "" {
+			policy = app.ConfirmationPolicyConfirm
+		}
+		fmt.Fprintln(output)
+		fmt.Fprintf(output, "  unconfirmed after %ds: %s\n", timeout, policy)
+	}
+	fmt.Fprintln(output)
+}
+
+// run before a deploy exits, os.Exit skips deferred calls
+var deployCleanups []func()
+
+func deployExit(code int) {
+	for i := len(deployCleanups) - 1; i >= 0; i-- {
+		deployCleanups[i]()
+	}
+	os.Exit(code)
+}
+
+// releases the deploy lock and exits, with 130 when the deploy was interrupted
+func exitDeploy(ctx context.Context, appName string) {
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "%s deployment of %s cancelled\n", errorStyle.Render("[error]"), appName)
+		deployExit(130)
+	}
+	deployExit(1)
+}
+
+// the application as a deploy with the current flags and yap.toml would register it. a redeploy
+// starts from a copy of the stored application, so the registry entry stays untouched until saved
+func desiredApplication(cmd *cobra.Command, existingApp *models.Application, project *models.ProjectConfig, processes map[string]models.ProcessConfig, appName string, port int) (*models.Application, error) {
+	var application *models.Application
+
+	if existingApp != nil {
+		application = copyApplication(existingApp)
 
 		if cmd.Flags().Changed("strategy") {
-			switch deployStrategy {
-			case "recreate":
-				strategy = models.DeploymentStrategyRecreate
-			case "rolling":
-				strategy = models.DeploymentStrategyRolling
-			case "blue-green":
-				strategy = models.DeploymentStrategyBlueGreen
-			default:
-				fmt.Fprintf(os.Stderr, "%s unknown deployment strategy: %s\n", errorStyle.Render("[error]"), deployStrategy)
-				fmt.Println(dimStyle.Render("  valid strategies: recreate, rolling, blue-green"))
-				os.Exit(1)
+			strategy, err := parseDeploymentStrategy(deployStrategy)
+			if err != nil {
+				return nil, err
 			}
 			application.DeploymentStrategy = strategy
-		} else {
-			strategy = application.DeploymentStrategy
-			if strategy == "" {
-				strategy = models.DeploymentStrategyRecreate
-				application.DeploymentStrategy = strategy
-			}
+		} else if application.DeploymentStrategy == "" {
+			application.DeploymentStrategy = models.DeploymentStrategyRecreate
 		}
 
 		if cmd.Flags().Changed("instances") {
@@ -315,62 +1045,91 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 		if cmd.Flags().Changed("rolling-interval") {
 			application.DeploymentConfig.RollingInterval = deployRollingInterval
 		}
-		if cmd.Flags().Changed("health-timeout") {
-			application.DeploymentConfig.HealthTimeout = deployHealthTimeout
+		if project != nil {
+			application.DeploymentConfig.HealthTimeout = project.Deployment.HealthTimeout
 		}
 		if cmd.Flags().Changed("auto-confirm") {
 			application.DeploymentConfig.AutoConfirm = deployAutoConfirm
 		}
+		if cmd.Flags().Changed("confirmation-timeout") {
+			application.DeploymentConfig.ConfirmationTimeout = deployConfirmationTimeout
+		}
+		if cmd.Flags().Changed("confirmation-policy") {
+			application.DeploymentConfig.ConfirmationPolicy = deployConfirmationPolicy
+		}
+		if cmd.Flags().Changed("canary-instances") || application.DeploymentConfig.CanaryInstances == 0 {
+			application.DeploymentConfig.CanaryInstances = deployCanaryInstances
+		}
+		if cmd.Flags().Changed("canary-steps") || len(application.DeploymentConfig.CanarySteps) == 0 {
+			application.DeploymentConfig.CanarySteps = deployCanarySteps
+		}
+		if cmd.Flags().Changed("canary-interval") || application.DeploymentConfig.CanaryInterval == 0 {
+			application.DeploymentConfig.CanaryInterval = deployCanaryInterval
+		}
+		if cmd.Flags().Changed("observe") {
+			application.DeploymentConfig.ObservationWindow = deployObserve
+		}
 
-		fmt.Println(infoStyle.Render(fmt.Sprintf("  [info] re-deploying with strategy: %s", strategy)))
-		fmt.Println()
-	} else {
-		switch deployStrategy {
-		case "recreate":
-			strategy = models.DeploymentStrategyRecreate
-		case "rolling":
-			strategy = models.DeploymentStrategyRolling
-		case "blue-green":
-			strategy = models.DeploymentStrategyBlueGreen
-		default:
-			fmt.Fprintf(os.Stderr, "%s unknown deployment strategy: %s\n", errorStyle.Render("[error]"), deployStrategy)
-			fmt.Println(dimStyle.Render("  valid strategies: recreate, rolling, blue-green"))
-			os.Exit(1)
+		if project != nil || healthFlagsChanged(cmd) {
+			readiness, liveness, err := deployHealthProbes(cmd, project)
+			if err != nil {
+				return nil, err
+			}
+			app.SetHealthProbes(application, readiness, liveness, healthCheckConfigured(cmd, project))
 		}
-	}
 
-	var appID string
+		if project != nil || cmd.Flags().Changed("stop-signal") {
+			application.StopSignal = deployStopSignal
+		}
+		if project != nil || cmd.Flags().Changed("stop-timeout") {
+			application.StopTimeout = deployStopTimeout
+		}
+		if project != nil || cmd.Flags().Changed("drain-period") {
+			application.DrainPeriod = deployDrainPeriod
+		}
+		if project != nil || cmd.Flags().Changed("crash-loop-threshold") {
+			application.CrashLoopThreshold = deployCrashLoopThreshold
+		}
 
-	if !isRedeployment {
-		appID = app.GenerateID()
+		application.Status = models.AppStatusDeploying
+	} else {
+		strategy, err := parseDeploymentStrategy(deployStrategy)
+		if err != nil {
+			return nil, err
+		}
 
 		application = &models.Application{
-			ID:   appID,
+			ID:   app.GenerateID(),
 			Name: appName,
 			VPC:  deployVPC,
 
 			Status:    models.AppStatusDeploying,
 			Instances: deployInstances,
-			BuildType: buildResult.BuildType,
-			ImageID:   buildResult.ImageID,
 
 			Memory: deployMemory,
 			CPU:    deployCPU,
 			Port:   port,
 
-			HealthCheckPath:     deployHealthPath,
-			HealthCheckInterval: deployHealthInterval,
-			HealthCheckTimeout:  deployHealthTimeout,
-
 			EnvVars: make(map[string]string),
 
+			StopSignal:  deployStopSignal,
+			StopTimeout: deployStopTimeout,
+			DrainPeriod: deployDrainPeriod,
+
+			CrashLoopThreshold: deployCrashLoopThreshold,
+
 			DeploymentStrategy: strategy,
 			DeploymentConfig: models.DeploymentConfig{
 				MaxSurge:            deployMaxSurge,
 				RollingInterval:     deployRollingInterval,
-				HealthTimeout:       deployHealthTimeout,
+				HealthTimeout:       deploymentHealthTimeout(project),
 				AutoConfirm:         deployAutoConfirm,
-				ConfirmationTimeout: 300,
+				ConfirmationTimeout: deployConfirmationTimeout,
+				ConfirmationPolicy:  deployConfirmationPolicy,
+				CanaryInstances:     deployCanaryInstances,
+				CanarySteps:         deployCanarySteps,
+				CanaryInterval:      deployCanaryInterval,
+				ObservationWindow:   deployObserve,
 			},
 			DeploymentState: models.DeploymentState{
 				Active: models.DeploymentColorDefault,
@@ -380,12 +1139,12 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 			UpdatedAt:      time.Now(),
 			LastDeployedAt: time.Now(),
 		}
-	} else {
-		appID = application.ID
 
-		application.BuildType = buildResult.BuildType
-		application.Status = models.AppStatusDeploying
-		application.UpdatedAt = time.Now()
+		readiness, liveness, err := deployHealthProbes(cmd, project)
+		if err != nil {
+			return nil, err
+		}
+		app.SetHealthProbes(application, readiness, liveness, healthCheckConfigured(cmd, project))
 	}
 
 	if project != nil && len(project.Env) > 0 {
@@ -399,165 +1158,180 @@ func runAppDeploy(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	traefikLabels := traefik.GenerateLabelsForApp(application)
+	applyDeployProcesses(application, existingApp, processes)
 
-	deployer, err := app.NewDeployer(strategy)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s failed to create deployer: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
+	// without a yap.toml a deploy keeps every job, yap.toml jobs included
+	if project != nil {
+		app.SyncCronJobs(application, configCronJobs(project.Cron))
 	}
 
-	deployOpts := app.DeploymentOptions{
-		App:           application,
-		SourcePath:    absPath,
-		NewImageID:    buildResult.ImageName,
-		Config:        application.DeploymentConfig,
-		VPCName:       deployVPC,
-		TraefikLabels: traefikLabels,
-		MemoryMB:      deployMemory,
-		CPUCores:      deployCPU,
+	// autoscaling only comes from yap.toml, without one a redeploy keeps what the app had
+	if project != nil {
+		application.AutoScaleEnabled = project.Deploy.AutoScaling
+		application.MinInstances = project.Scaling.MinInstances
+		application.MaxInstances = project.Scaling.MaxInstances
+		application.TargetCPU = project.Scaling.CPUThreshold
+		application.TargetMemory = project.Scaling.MemoryThreshold
+		application.ScaleUpDelay = project.Scaling.ScaleUpDelay
+		application.ScaleDownDelay = project.Scaling.ScaleDownDelay
 	}
 
-	imageID, err := deployer.Deploy(deployOpts)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "\n%s deployment failed: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
-	}
+	return application, nil
+}
 
-	application.ImageID = imageID
-	application.Status = models.AppStatusRunning
+func healthFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range []string{"health-type", "health-path", "health-interval", "health-timeout", "health-command"} {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
 
-	if isRedeployment {
-		fmt.Println(progressStyle.Render("  --> updating application..."))
+// readiness set by a health flag or [deploy.health_check], recreate only waits on that
+func healthCheckConfigured(cmd *cobra.Command, project *models.ProjectConfig) bool {
+	return healthFlagsChanged(cmd) || (project != nil && project.Deploy.HealthCheck.Configured)
+}
 
-		deploymentRecord := models.DeploymentRecord{
-			ID:         fmt.Sprintf("dep-%s", time.Now().Format("20060102-150405")),
-			ImageID:    imageID,
-			Strategy:   strategy,
-			DeployedAt: time.Now(),
-			Status:     "active",
-		}
+// the readiness deadline of a deploy, --health-timeout only bounds a single probe
+func deploymentHealthTimeout(project *models.ProjectConfig) int {
+	if project != nil {
+		return project.Deployment.HealthTimeout
+	}
+	return constants.DefaultHealthTimeout
+}
 
-		for i := range application.DeploymentHistory {
-			if application.DeploymentHistory[i].Status == "active" {
-				application.DeploymentHistory[i].Status = "superseded"
-			}
+// readiness from the health flags on top of [deploy.health_check], liveness only when yap.toml
+// sets one apart
+func deployHealthProbes(cmd *cobra.Command, project *models.ProjectConfig) (models.HealthProbe, *models.HealthProbe, error) {
+	readiness := models.HealthProbe{Retries: 3}
+	var liveness *models.HealthProbe
+	if project != nil {
+		readiness = project.Deploy.HealthCheck.Probe()
+		if project.Deploy.HealthCheck.Liveness != nil {
+			probe := project.Deploy.HealthCheck.LivenessProbe()
+			liveness = &probe
 		}
+	}
 
-		application.DeploymentHistory = append(application.DeploymentHistory, deploymentRecord)
-		application.LastDeployedAt = time.Now()
+	readiness.Type = models.HealthProbeType(deployHealthType)
+	readiness.Interval = deployHealthInterval
+	readiness.Timeout = deployHealthTimeout
+	readiness.Path = ""
+	if readiness.Type == models.HealthProbeHTTP {
+		readiness.Path = deployHealthPath
+	}
+	if cmd.Flags().Changed("health-command") {
+		readiness.Command = []string{"sh", "-c", deployHealthCommand}
+	}
 
-		if err := registry.Update(*application); err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed to update application: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
-		}
+	if err := models.ValidateHealthProbe(readiness); err != nil {
+		return readiness, nil, err
+	}
 
-		fmt.Println(successStyle.Render("  [done] application updated"))
-	} else {
-		fmt.Println(progressStyle.Render("  --> registering application..."))
+	return readiness, liveness, nil
+}
 
-		application.InternalHostname = fmt.Sprintf("yap-app-%s", appName)
-		application.Published = false
-		application.PublishedURL = fmt.Sprintf("http://%s.yap.local", appName)
+// [build] from yap.toml with the flags on top: given build args come after the file's so they win,
+// given labels replace the file's with the same key
+func deployBuildOptions(cmd *cobra.Command, project *models.ProjectConfig) (builder.BuildOptions, error) {
+	opts := builder.BuildOptions{
+		Dockerfile: deployDockerfile,
+		Context:    deployContext,
+		Target:     deployTarget,
+		Builder:    deployBuilder,
+		Buildpacks: deployBuildpacks,
+		Labels:     make(map[string]string),
+	}
 
-		deploymentRecord := models.DeploymentRecord{
-			ID:         fmt.Sprintf("dep-%s", time.Now().Format("20060102-150405")),
-			ImageID:    imageID,
-			Strategy:   strategy,
-			DeployedAt: time.Now(),
-			Status:     "active",
+	if project != nil {
+		if !cmd.Flags().Changed("dockerfile") {
+			opts.Dockerfile = project.Build.Dockerfile
 		}
-		application.DeploymentHistory = []models.DeploymentRecord{deploymentRecord}
-
-		if err := registry.Add(*application); err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed to register application: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
+		if !cmd.Flags().Changed("context") {
+			opts.Context = project.Build.Context
+		}
+		if !cmd.Flags().Changed("target") {
+			opts.Target = project.Build.Target
+		}
+		if !cmd.Flags().Changed("builder") && project.Build.Paketo.Builder != "" {
+			opts.Builder = project.Build.Paketo.Builder
+		}
+		if !cmd.Flags().Changed("buildpack") {
+			opts.Buildpacks = project.Build.Paketo.Buildpacks
+		}
+		opts.CacheVolume = project.Build.Paketo.CacheVolume
+		opts.Exclude = project.Build.Exclude
+		opts.BuildArgs = append(opts.BuildArgs, project.Build.BuildArgs...)
+		for key, value := range project.Build.Labels {
+			opts.Labels[key] = value
 		}
-
-		fmt.Println(successStyle.Render("  [done] application registered"))
 	}
-	fmt.Println()
-
-	// unlock early because volumes like to fight for their own locks (they're rebellious like that)
-	lockManager.Unlock(appName)
-
-	if project != nil && len(project.Volumes) > 0 && !isRedeployment {
-		fmt.Println(infoStyle.Render("  [info] adding volumes from yap.toml..."))
 
-		volumeManager := app.NewVolumeManager(dockerClient, registry)
-		ctx := context.Background()
+	if opts.Dockerfile != "" {
+		if err := models.ValidateBuildPath("dockerfile", opts.Dockerfile); err != nil {
+			return opts, err
+		}
+	}
+	if opts.Context != "" {
+		if err := models.ValidateBuildPath("context", opts.Context); err != nil {
+			return opts, err
+		}
+	}
 
-		for volumeName, mountPath := range project.Volumes {
-			vol := models.Volume{
-				Name:      volumeName,
-				MountPath: mountPath,
-				Type:      "volume",
-			}
+	for _, arg := range deployBuildArgs {
+		if err := models.ValidateBuildArg(arg); err != nil {
+			return opts, err
+		}
+	}
+	opts.BuildArgs = append(opts.BuildArgs, deployBuildArgs...)
 
-			if err := volumeManager.AddVolume(ctx, appName, vol); err != nil {
-				fmt.Fprintf(os.Stderr, "%s failed to add volume %s: %v\n", errorStyle.Render("[error]"), volumeName, err)
-				continue
-			}
-			fmt.Printf("    added volume: %s -> %s\n", dimStyle.Render(volumeName), dimStyle.Render(mountPath))
+	for _, label := range deployLabels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok {
+			return opts, fmt.Errorf("invalid label: %q (KEY=VALUE)", label)
+		}
+		if err := models.ValidateLabel(key); err != nil {
+			return opts, err
 		}
+		opts.Labels[key] = value
+	}
 
-		if len(project.Volumes) > 0 {
-			fmt.Println()
-			fmt.Println(infoStyle.Render("  [info] volumes added - redeploy to mount them"))
-			fmt.Println(dimStyle.Render(fmt.Sprintf("  run 'yap app deploy %s' to mount volumes", appName)))
-			fmt.Println()
-		}
-	}
-
-	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s deployed successfully", appName)))
-	fmt.Println()
-	fmt.Println(titleStyle.Render("  application details:"))
-	fmt.Printf("    name: %s\n", valueStyle.Render(appName))
-	fmt.Printf("    id: %s\n", dimStyle.Render(appID))
-	fmt.Printf("    vpc: %s\n", valueStyle.Render(deployVPC))
-	fmt.Printf("    instances: %s\n", valueStyle.Render(fmt.Sprintf("%d", deployInstances)))
-	fmt.Printf("    memory: %s\n", valueStyle.Render(fmt.Sprintf("%d MB", deployMemory)))
-	fmt.Printf("    cpu: %s\n", valueStyle.Render(fmt.Sprintf("%.1f", deployCPU)))
-	fmt.Printf("    strategy: %s\n", valueStyle.Render(string(strategy)))
-	fmt.Println()
-	fmt.Println(titleStyle.Render("  access:"))
-	fmt.Printf("    url: %s\n", valueStyle.Render(fmt.Sprintf("http://%s.yap.local", appName)))
-	fmt.Printf("    internal: %s\n", dimStyle.Render(fmt.Sprintf("yap-app-%s:%d", appName, port)))
-	fmt.Println()
-	fmt.Println(titleStyle.Render("  next steps:"))
-	fmt.Println()
-	fmt.Println("  " + dimStyle.Render("test your app:"))
-	fmt.Println("  " + infoStyle.Render(fmt.Sprintf("    curl -H \"Host: %s.yap.local\" http://localhost", appName)))
-	fmt.Println()
-	fmt.Println("  " + dimStyle.Render("monitor and debug:"))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app logs %s [-f]", appName)))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app status %s", appName)))
-	fmt.Println()
-	fmt.Println("  " + dimStyle.Render("scale your app:"))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app scale %s --instances 3", appName)))
-	fmt.Println()
-	fmt.Println("  " + dimStyle.Render("add environment variables:"))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app env set %s KEY=value", appName)))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app env import %s .env", appName)))
-	fmt.Println()
-	fmt.Println("  " + dimStyle.Render("link a database:"))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap db create postgres mydb --vpc %s", deployVPC)))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app link %s mydb", appName)))
-	fmt.Println()
-	fmt.Println("  " + dimStyle.Render("add persistent storage:"))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app volume add %s data /app/data", appName)))
-	fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app deploy %s   # redeploy to mount volume", appName)))
+	return opts, nil
+}
 
-	if !application.Published {
-		fmt.Println()
-		fmt.Println("  " + dimStyle.Render("publish with https:"))
-		fmt.Println("  " + dimStyle.Render("    yap config setup"))
-		fmt.Println("  " + dimStyle.Render(fmt.Sprintf("    yap app publish %s", appName)))
+func parseDeploymentStrategy(name string) (models.DeploymentStrategy, error) {
+	switch name {
+	case "recreate":
+		return models.DeploymentStrategyRecreate, nil
+	case "rolling":
+		return models.DeploymentStrategyRolling, nil
+	case "blue-green":
+		return models.DeploymentStrategyBlueGreen, nil
+	case "canary":
+		return models.DeploymentStrategyCanary, nil
 	}
+	return "", fmt.Errorf("unknown deployment strategy: %s", name)
+}
 
-	if strategy == models.DeploymentStrategyBlueGreen && !deployAutoConfirm {
-		fmt.Println()
-		fmt.Println(labelStyle.Render("  blue-green deployment:"))
-		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app deployment status %s   # view deployment state", appName)))
+// copies the parts of an application a deploy modifies, so the original can still be compared against
+func copyApplication(src *models.Application) *models.Application {
+	dst := *src
+
+	dst.EnvVars = make(map[string]string, len(src.EnvVars))
+	for k, v := range src.EnvVars {
+		dst.EnvVars[k] = v
 	}
+	dst.DeploymentConfig.CanarySteps = append([]int(nil), src.DeploymentConfig.CanarySteps...)
+	dst.Volumes = append([]models.Volume(nil), src.Volumes...)
+	dst.DeploymentHistory = append([]models.DeploymentRecord(nil), src.DeploymentHistory...)
+	dst.Processes = make([]models.Process, len(src.Processes))
+	for i, process := range src.Processes {
+		process.ContainerIDs = append([]string(nil), process.ContainerIDs...)
+		dst.Processes[i] = process
+	}
+	dst.CronJobs = append([]models.CronJob(nil), src.CronJobs...)
+	dst.CrashedInstances = append([]models.CrashedInstance(nil), src.CrashedInstances...)
+
+	return &dst
 }
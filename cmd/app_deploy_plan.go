@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/builder"
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/router"
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// exit code of `yap app deploy --plan` when the deploy would change something
+const planChangesExitCode = 2
+
+type planChange struct {
+	field string
+	from  string
+	to    string
+}
+
+func (c planChange) String() string {
+	switch {
+	case c.from == "":
+		return fmt.Sprintf("  %s %s: %s", successStyle.Render("+"), c.field, c.to)
+	case c.to == "":
+		return fmt.Sprintf("  %s %s: %s", errorStyle.Render("-"), c.field, c.from)
+	default:
+		return fmt.Sprintf("  %s %s: %s -> %s", infoStyle.Render("~"), c.field, c.from, c.to)
+	}
+}
+
+type deployPlan struct {
+	changes []planChange
+}
+
+func (p *deployPlan) compare(field, from, to string) {
+	if from != to {
+		p.changes = append(p.changes, planChange{field: field, from: from, to: to})
+	}
+}
+
+func (p *deployPlan) compareMap(prefix string, from, to map[string]string, showValues bool) {
+	keys := make(map[string]bool)
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		oldValue, hadOld := from[k]
+		newValue, hasNew := to[k]
+
+		if hadOld && hasNew && oldValue == newValue {
+			continue
+		}
+
+		if hadOld && hasNew && !showValues {
+			p.changes = append(p.changes, planChange{field: prefix + k, from: "(set)", to: "(changed)"})
+			continue
+		}
+		p.changes = append(p.changes, planChange{
+			field: prefix + k,
+			from:  planValue(oldValue, hadOld, showValues),
+			to:    planValue(newValue, hasNew, showValues),
+		})
+	}
+}
+
+// env values are secrets more often than not, those only show whether they're set
+func planValue(v string, present, showValues bool) string {
+	if !present {
+		return ""
+	}
+	if !showValues {
+		return "(set)"
+	}
+	if v == "" {
+		return `""`
+	}
+	return v
+}
+
+// prints what the deploy would change against the registry, without building or touching containers.
+// returns true when anything would change
+func runDeployPlan(cmd *cobra.Command, output io.Writer, appName string, project *models.ProjectConfig, processes map[string]models.ProcessConfig, absPath string, gitCheckout *builder.GitCheckout) bool {
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	existingApp, err := registry.Get(appName)
+	if err != nil {
+		existingApp = nil
+	}
+
+	desired, err := desiredApplication(cmd, existingApp, project, processes, appName, deployPort)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		deployExit(1)
+	}
+
+	fmt.Fprintln(output, titleStyle.Render(fmt.Sprintf("==> deployment plan: %s", appName)))
+	fmt.Fprintln(output)
+
+	switch {
+	case deployImage != "":
+		fmt.Fprintf(output, "  source: %s\n", valueStyle.Render(deployImage))
+	case gitCheckout != nil:
+		fmt.Fprintf(output, "  source: %s %s\n", valueStyle.Render(gitCheckout.URL), dimStyle.Render(fmt.Sprintf("@ %s", utils.TruncateID(gitCheckout.Commit, 12))))
+	default:
+		fmt.Fprintf(output, "  source: %s\n", valueStyle.Render(absPath))
+	}
+	fmt.Fprintf(output, "  release: %s\n", valueStyle.Render(fmt.Sprintf("v%d", app.NextReleaseVersion(existingApp))))
+	fmt.Fprintln(output)
+
+	plan := &deployPlan{}
+	labels := router.NewTraefikManager(nil)
+
+	if existingApp == nil {
+		port := "detected at build"
+		if desired.Port > 0 {
+			port = fmt.Sprintf("%d", desired.Port)
+		}
+
+		plan.compare("application", "", "create")
+		plan.compare("vpc", "", deployVPC)
+		plan.compare("strategy", "", string(desired.DeploymentStrategy))
+		plan.compare("instances", "", fmt.Sprintf("%d", desired.Instances))
+		plan.compare("memory", "", fmt.Sprintf("%d MB", deployMemory))
+		plan.compare("cpu", "", fmt.Sprintf("%.2f", deployCPU))
+		plan.compare("port", "", port)
+		plan.compare("readiness probe", "", app.DescribeProbe(app.ReadinessProbe(desired)))
+		plan.compare("liveness probe", "", app.DescribeProbe(app.LivenessProbe(desired)))
+		plan.compare("stop", "", describeStop(desired))
+		plan.compare("crash loop", "", describeCrashLoop(desired))
+		plan.compare("autoscaling", "", describeAutoscaling(desired))
+		plan.compareMap("process.", nil, processMap(desired), true)
+		plan.compareMap("cron.", nil, cronMap(desired), true)
+		plan.compareMap("env.", nil, desired.EnvVars, false)
+		if project != nil {
+			plan.compareMap("volume.", nil, project.Volumes, true)
+		}
+		if desired.Port > 0 {
+			plan.compareMap("label.", nil, labels.GenerateLabelsForApp(desired), true)
+		}
+	} else {
+		// instances are started with the resolved flag/yap.toml limits and vpc, even where the
+		// registry keeps its stored value
+		plan.compare("vpc", existingApp.VPC, deployVPC)
+		plan.compare("strategy", string(existingApp.DeploymentStrategy), string(desired.DeploymentStrategy))
+		plan.compare("instances", fmt.Sprintf("%d", existingApp.Instances), fmt.Sprintf("%d", desired.Instances))
+		plan.compare("memory", fmt.Sprintf("%d MB", existingApp.Memory), fmt.Sprintf("%d MB", deployMemory))
+		plan.compare("cpu", fmt.Sprintf("%.2f", existingApp.CPU), fmt.Sprintf("%.2f", deployCPU))
+		plan.compare("port", fmt.Sprintf("%d", existingApp.Port), fmt.Sprintf("%d", desired.Port))
+		plan.compare("readiness probe", app.DescribeProbe(app.ReadinessProbe(existingApp)), app.DescribeProbe(app.ReadinessProbe(desired)))
+		plan.compare("liveness probe", app.DescribeProbe(app.LivenessProbe(existingApp)), app.DescribeProbe(app.LivenessProbe(desired)))
+		plan.compare("stop", describeStop(existingApp), describeStop(desired))
+		plan.compare("crash loop", describeCrashLoop(existingApp), describeCrashLoop(desired))
+		plan.compare("autoscaling", describeAutoscaling(existingApp), describeAutoscaling(desired))
+
+		from, to := existingApp.DeploymentConfig, desired.DeploymentConfig
+		plan.compare("deployment.health_timeout", fmt.Sprintf("%ds", from.HealthTimeout), fmt.Sprintf("%ds", to.HealthTimeout))
+		plan.compare("deployment.max_surge", fmt.Sprintf("%d", from.MaxSurge), fmt.Sprintf("%d", to.MaxSurge))
+		plan.compare("deployment.rolling_interval", fmt.Sprintf("%ds", from.RollingInterval), fmt.Sprintf("%ds", to.RollingInterval))
+		plan.compare("deployment.auto_confirm", fmt.Sprintf("%t", from.AutoConfirm), fmt.Sprintf("%t", to.AutoConfirm))
+		plan.compare("deployment.confirmation_timeout", fmt.Sprintf("%ds", from.ConfirmationTimeout), fmt.Sprintf("%ds", to.ConfirmationTimeout))
+		plan.compare("deployment.confirmation_policy", from.ConfirmationPolicy, to.ConfirmationPolicy)
+		plan.compare("deployment.canary_instances", fmt.Sprintf("%d", from.CanaryInstances), fmt.Sprintf("%d", to.CanaryInstances))
+		plan.compare("deployment.canary_steps", fmt.Sprintf("%v", from.CanarySteps), fmt.Sprintf("%v", to.CanarySteps))
+		plan.compare("deployment.canary_interval", fmt.Sprintf("%ds", from.CanaryInterval), fmt.Sprintf("%ds", to.CanaryInterval))
+		plan.compare("deployment.observation_window", fmt.Sprintf("%ds", from.ObservationWindow), fmt.Sprintf("%ds", to.ObservationWindow))
+
+		plan.compareMap("process.", processMap(existingApp), processMap(desired), true)
+		plan.compareMap("cron.", cronMap(existingApp), cronMap(desired), true)
+		plan.compareMap("env.", existingApp.EnvVars, desired.EnvVars, false)
+		plan.compareMap("volume.", volumeMap(existingApp.Volumes), volumeMap(desired.Volumes), true)
+		plan.compareMap("label.", labels.GenerateLabelsForApp(existingApp), labels.GenerateLabelsForApp(desired), true)
+	}
+
+	if len(plan.changes) == 0 {
+		fmt.Fprintln(output, successStyle.Render("  no changes, the deploy would only roll out a new release"))
+	} else {
+		fmt.Fprintln(output, labelStyle.Render("  changes:"))
+		for _, change := range plan.changes {
+			fmt.Fprintln(output, change)
+		}
+	}
+	fmt.Fprintln(output)
+
+	if project != nil {
+		var hooks []string
+		for _, hook := range []struct{ name, command string }{
+			{app.HookPreBuild, project.Hooks.PreBuild},
+			{app.HookPostBuild, project.Hooks.PostBuild},
+			{app.HookPreDeploy, project.Hooks.PreDeploy},
+			{app.HookPostDeploy, project.Hooks.PostDeploy},
+		} {
+			if hook.command != "" {
+				hooks = append(hooks, fmt.Sprintf("%s: %s", hook.name, hook.command))
+			}
+		}
+		if len(hooks) > 0 {
+			fmt.Fprintln(output, labelStyle.Render("  hooks that would run:"))
+			for _, hook := range hooks {
+				fmt.Fprintf(output, "    %s\n", dimStyle.Render(hook))
+			}
+			fmt.Fprintln(output)
+		}
+	}
+
+	if len(plan.changes) > 0 {
+		fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  %d change(s), run without --plan to apply", len(plan.changes))))
+	}
+
+	return len(plan.changes) > 0
+}
+
+func describeStop(application *models.Application) string {
+	signal := application.StopSignal
+	if signal == "" {
+		signal = "image default signal"
+	}
+
+	timeout := application.StopTimeout
+	if timeout == 0 {
+		timeout = constants.DefaultStopTimeout
+	}
+
+	if application.DrainPeriod == 0 {
+		return fmt.Sprintf("%s, %ds grace, no draining", signal, timeout)
+	}
+	return fmt.Sprintf("%s, %ds grace, %ds drain", signal, timeout, application.DrainPeriod)
+}
+
+func volumeMap(volumes []models.Volume) map[string]string {
+	m := make(map[string]string, len(volumes))
+	for _, vol := range volumes {
+		target := vol.MountPath
+		if vol.ReadOnly {
+			target += " (read-only)"
+		}
+		m[vol.Name] = target
+	}
+	return m
+}
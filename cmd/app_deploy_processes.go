@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/project"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// [processes] from yap.toml, or the Procfile next to it when yap.toml doesn't define any. nil when
+// the project defines neither
+func loadDeployProcesses(config *models.ProjectConfig, sourcePath string) (map[string]models.ProcessConfig, error) {
+	processes := map[string]models.ProcessConfig(nil)
+	if config != nil && len(config.Processes) > 0 {
+		processes = config.Processes
+	} else {
+		procfile, err := project.LoadProcfile(sourcePath)
+		if err != nil {
+			return nil, err
+		}
+		processes = procfile
+	}
+
+	for name, process := range processes {
+		if process.Memory != "" {
+			memory := models.ParseMemory(process.Memory)
+			if memory < constants.MinMemoryMB || memory > constants.MaxMemoryMB {
+				return nil, fmt.Errorf("process %s: memory must be between %dMB and %dMB", name, constants.MinMemoryMB, constants.MaxMemoryMB)
+			}
+		}
+		if process.CPU != 0 && (process.CPU < constants.MinCPUCores || process.CPU > constants.MaxCPUCores) {
+			return nil, fmt.Errorf("process %s: cpu must be between %d and %d cores", name, constants.MinCPUCores, constants.MaxCPUCores)
+		}
+		if process.Instances > constants.MaxInstances {
+			return nil, fmt.Errorf("process %s: maximum %d instances", name, constants.MaxInstances)
+		}
+	}
+
+	return processes, nil
+}
+
+// sets the web command and the other processes on the application. a redeploy keeps each process's
+// instance count, scaling it is yap app scale's job like it is for web. without any process
+// definitions a redeploy keeps the processes it had
+func applyDeployProcesses(application, existingApp *models.Application, processes map[string]models.ProcessConfig) {
+	if processes == nil {
+		return
+	}
+
+	application.Command = processes[models.ProcessWeb].Command
+
+	names := make([]string, 0, len(processes))
+	for name := range processes {
+		if name != models.ProcessWeb {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	application.Processes = make([]models.Process, 0, len(names))
+	for _, name := range names {
+		config := processes[name]
+
+		process := models.Process{
+			Name:      name,
+			Command:   config.Command,
+			Instances: config.Instances,
+			CPU:       config.CPU,
+		}
+		if config.Memory != "" {
+			process.Memory = models.ParseMemory(config.Memory)
+		}
+
+		if existing := findProcess(existingApp, name); existing != nil {
+			process.Instances = existing.Instances
+			process.ContainerIDs = append([]string(nil), existing.ContainerIDs...)
+		}
+
+		application.Processes = append(application.Processes, process)
+	}
+}
+
+// processes the stored application runs that the new definitions dropped
+func removedProcesses(existingApp, application *models.Application) []models.Process {
+	if existingApp == nil {
+		return nil
+	}
+
+	var removed []models.Process
+	for _, process := range existingApp.Processes {
+		if findProcess(application, process.Name) == nil {
+			removed = append(removed, process)
+		}
+	}
+	return removed
+}
+
+func findProcess(application *models.Application, name string) *models.Process {
+	if application == nil {
+		return nil
+	}
+	for i := range application.Processes {
+		if application.Processes[i].Name == name {
+			return &application.Processes[i]
+		}
+	}
+	return nil
+}
+
+func describeProcess(application *models.Application, process models.Process) string {
+	memory, cpu := process.Memory, process.CPU
+	if memory == 0 {
+		memory = application.Memory
+	}
+	if cpu == 0 {
+		cpu = application.CPU
+	}
+	return fmt.Sprintf("%d x %d MB / %.2f cpu: %s", process.Instances, memory, cpu, process.Command)
+}
+
+// process name to what it runs, web only shows up with a command of its own
+func processMap(application *models.Application) map[string]string {
+	processes := make(map[string]string, len(application.Processes)+1)
+	if application.Command != "" {
+		processes[models.ProcessWeb] = application.Command
+	}
+	for _, process := range application.Processes {
+		processes[process.Name] = describeProcess(application, process)
+	}
+	return processes
+}
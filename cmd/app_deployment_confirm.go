@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
@@ -27,6 +28,14 @@ func init() {
 func runAppDeploymentConfirm(cmd *cobra.Command, args []string) {
 	appName := args[0]
 
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
 	registry, err := app.NewRegistryManager()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
@@ -86,10 +95,7 @@ func runAppDeploymentConfirm(cmd *cobra.Command, args []string) {
 		instanceNum := i + 1
 		fmt.Printf("    [%d/%d] removing %s-%d...\n", instanceNum, len(standbyEnv.ContainerIDs), standbyColor, instanceNum)
 
-		timeout := 10
-		if err := dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
-			Timeout: &timeout,
-		}); err != nil {
+		if err := app.StopInstance(ctx, dockerClient, application, containerID); err != nil {
 			fmt.Printf("    [warn] failed to stop container: %v\n", err)
 		}
 
@@ -4,11 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/pkg/models"
-	dockerTypes "github.com/docker/docker/api/types/container"
 	"github.com/spf13/cobra"
 )
 
@@ -27,6 +27,14 @@ func init() {
 func runAppDeploymentRollback(cmd *cobra.Command, args []string) {
 	appName := args[0]
 
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
 	registry, err := app.NewRegistryManager()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
@@ -56,29 +64,12 @@ func runAppDeploymentRollback(cmd *cobra.Command, args []string) {
 	activeColor := application.DeploymentState.Active
 	standbyColor := application.DeploymentState.Standby
 
-	if standbyColor == "" || standbyColor == models.DeploymentColorDefault {
+	if !app.HasStandby(application) {
 		fmt.Fprintf(os.Stderr, "%s no standby environment to rollback to\n", errorStyle.Render("[error]"))
 		fmt.Println(dimStyle.Render("  deployment already confirmed or using different strategy"))
 		os.Exit(1)
 	}
 
-	var activeEnv, standbyEnv *models.Environment
-	if activeColor == models.DeploymentColorBlue {
-		activeEnv = application.DeploymentState.Blue
-		standbyEnv = application.DeploymentState.Green
-	} else {
-		activeEnv = application.DeploymentState.Green
-		standbyEnv = application.DeploymentState.Blue
-	}
-
-	if standbyEnv == nil || len(standbyEnv.ContainerIDs) == 0 {
-		fmt.Fprintf(os.Stderr, "%s standby environment is empty\n", errorStyle.Render("[error]"))
-		os.Exit(1)
-	}
-
-	fmt.Printf("  --> switching traffic from %s to %s...\n", activeColor, standbyColor)
-	fmt.Println()
-
 	dockerClient, err := docker.NewClient()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
@@ -87,12 +78,18 @@ func runAppDeploymentRollback(cmd *cobra.Command, args []string) {
 
 	ctx := context.Background()
 
-	fmt.Printf("  --> updating %s environment (adding traffic routing)...\n", standbyColor)
+	fmt.Printf("  --> switching traffic from %s to %s...\n", activeColor, standbyColor)
 
-	application.DeploymentState.Active = standbyColor
-	application.DeploymentState.Standby = activeColor
+	record, standbyVersion, err := app.SwitchToStandby(ctx, dockerClient, application, app.DeploymentStatusRolledBack, nil)
+	if err != nil {
+		// containers may have been recreated before the failure, keep their new IDs
+		registry.Update(*application)
+		fmt.Fprintf(os.Stderr, "%s failed to switch traffic: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
 
-	application.ContainerIDs = standbyEnv.ContainerIDs
+	application.UpdatedAt = time.Now()
+	application.LastDeployedAt = time.Now()
 
 	if err := registry.Update(*application); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
@@ -102,32 +99,12 @@ func runAppDeploymentRollback(cmd *cobra.Command, args []string) {
 	fmt.Printf("  [done] traffic switched to %s\n", standbyColor)
 	fmt.Println()
 
+	activeEnv := app.GetEnvironment(application, activeColor)
 	fmt.Printf("  --> destroying %s environment (%d instances)...\n", activeColor, len(activeEnv.ContainerIDs))
 
-	for i, containerID := range activeEnv.ContainerIDs {
-		instanceNum := i + 1
-		fmt.Printf("    [%d/%d] removing %s-%d...\n", instanceNum, len(activeEnv.ContainerIDs), activeColor, instanceNum)
-
-		timeout := 10
-		if err := dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
-			Timeout: &timeout,
-		}); err != nil {
-			fmt.Printf("    [warn] failed to stop container: %v\n", err)
-		}
-
-		if err := dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{
-			Force: true,
-		}); err != nil {
-			fmt.Printf("    [warn] failed to remove container: %v\n", err)
-		}
-	}
-
-	if activeColor == models.DeploymentColorBlue {
-		application.DeploymentState.Blue = nil
-	} else {
-		application.DeploymentState.Green = nil
+	if err := app.DestroyStandby(ctx, dockerClient, application); err != nil {
+		fmt.Printf("    [warn] %v\n", err)
 	}
-	application.DeploymentState.Standby = ""
 
 	if err := registry.Update(*application); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
@@ -138,5 +115,8 @@ func runAppDeploymentRollback(cmd *cobra.Command, args []string) {
 	fmt.Println()
 	fmt.Println(successStyle.Render("  [done] rollback completed"))
 	fmt.Printf("    active environment: %s\n", valueStyle.Render(string(standbyColor)))
+	if standbyVersion > 0 {
+		fmt.Printf("    release: %s\n", valueStyle.Render(fmt.Sprintf("v%d (new release v%d)", standbyVersion, record.Version)))
+	}
 	fmt.Println()
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/utils"
@@ -84,8 +85,23 @@ func runAppDeploymentStatus(cmd *cobra.Command, args []string) {
 		if application.DeploymentState.Standby != "" && application.DeploymentState.Standby != models.DeploymentColorDefault {
 			fmt.Println(labelStyle.Render("  available actions:"))
 			fmt.Printf("    %s\n", dimStyle.Render(fmt.Sprintf("yap app deployment confirm %s   # destroy %s environment", appName, application.DeploymentState.Standby)))
+			fmt.Printf("    %s\n", dimStyle.Render(fmt.Sprintf("yap app deployment switch %s    # route traffic to %s, keep both", appName, application.DeploymentState.Standby)))
 			fmt.Printf("    %s\n", dimStyle.Render(fmt.Sprintf("yap app deployment rollback %s  # switch back to %s", appName, application.DeploymentState.Standby)))
 			fmt.Println()
+
+			activeEnv := app.GetEnvironment(application, active)
+			timeout := application.DeploymentConfig.ConfirmationTimeout
+			if activeEnv != nil && timeout > 0 && !application.DeploymentConfig.AutoConfirm {
+				policy := application.DeploymentConfig.ConfirmationPolicy
+				if policy == "" {
+					policy = app.ConfirmationPolicyConfirm
+				}
+				deadline := activeEnv.DeployedAt.Add(time.Duration(timeout) * time.Second)
+				fmt.Println(labelStyle.Render("  confirmation timeout:"))
+				fmt.Printf("    policy: %s\n", valueStyle.Render(policy))
+				fmt.Printf("    settles at: %s\n", dimStyle.Render(deadline.Format("2006-01-02 15:04:05")))
+				fmt.Println()
+			}
 		}
 	} else {
 		fmt.Println(dimStyle.Render(fmt.Sprintf("  using %s strategy - no blue-green state available", strategy)))
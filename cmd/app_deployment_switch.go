@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appDeploymentSwitchCmd = &cobra.Command{
+	Use:   "switch [name]",
+	Short: "Switch traffic between blue and green",
+	Long:  "Route traffic to the standby environment and keep the current active environment as the new standby",
+	Args:  cobra.ExactArgs(1),
+	Run:   runAppDeploymentSwitch,
+}
+
+func init() {
+	appDeploymentCmd.AddCommand(appDeploymentSwitchCmd)
+}
+
+func runAppDeploymentSwitch(cmd *cobra.Command, args []string) {
+	appName := args[0]
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application '%s' not found\n", errorStyle.Render("[error]"), appName)
+		os.Exit(1)
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("==> switching traffic: %s", appName)))
+	fmt.Println()
+
+	if application.DeploymentStrategy != models.DeploymentStrategyBlueGreen {
+		fmt.Fprintf(os.Stderr, "%s application is not using blue-green deployment\n", errorStyle.Render("[error]"))
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  current strategy: %s", application.DeploymentStrategy)))
+		os.Exit(1)
+	}
+
+	if !app.HasStandby(application) {
+		fmt.Fprintf(os.Stderr, "%s no standby environment to switch to\n", errorStyle.Render("[error]"))
+		fmt.Println(dimStyle.Render("  deployment already confirmed, use 'yap app rollback' to redeploy an earlier release"))
+		os.Exit(1)
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	fromColor := application.DeploymentState.Active
+	toColor := application.DeploymentState.Standby
+	started := time.Now()
+
+	fmt.Printf("  --> switching traffic from %s to %s...\n", fromColor, toColor)
+
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	record, standbyVersion, err := app.SwitchToStandby(ctx, dockerClient, application, app.DeploymentStatusSuperseded, nil)
+	if err != nil {
+		// containers may have been recreated before the failure, keep their new IDs
+		registry.Update(*application)
+		fmt.Fprintf(os.Stderr, "%s failed to switch traffic: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application.UpdatedAt = time.Now()
+	application.LastDeployedAt = time.Now()
+
+	if err := registry.Update(*application); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("  [done] traffic switched to %s in %s\n", toColor, time.Since(started).Round(100*time.Millisecond))
+	fmt.Println()
+	fmt.Printf("    active environment: %s\n", valueStyle.Render(string(toColor)))
+	fmt.Printf("    standby environment: %s\n", dimStyle.Render(string(fromColor)))
+	if standbyVersion > 0 {
+		fmt.Printf("    release: %s\n", valueStyle.Render(fmt.Sprintf("v%d (new release v%d)", standbyVersion, record.Version)))
+	}
+	fmt.Println()
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  run 'yap app deployment switch %s' again to switch back", appName)))
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  run 'yap app deployment confirm %s' to destroy %s", appName, fromColor)))
+	fmt.Println()
+}
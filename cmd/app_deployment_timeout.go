@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appDeploymentTimeoutDeployedAt int64
+
+// spawned in the background by deploys that leave a standby environment behind
+var appDeploymentTimeoutCmd = &cobra.Command{
+	Use:    "timeout [name]",
+	Short:  "Enforce the confirmation timeout of a blue-green deployment",
+	Args:   cobra.ExactArgs(1),
+	Hidden: true,
+	Run:    runAppDeploymentTimeout,
+}
+
+func init() {
+	appDeploymentCmd.AddCommand(appDeploymentTimeoutCmd)
+	appDeploymentTimeoutCmd.Flags().Int64Var(&appDeploymentTimeoutDeployedAt, "deployed-at", 0, "Deployment time of the active environment (unix nanoseconds)")
+	appDeploymentTimeoutCmd.MarkFlagRequired("deployed-at")
+}
+
+func runAppDeploymentTimeout(cmd *cobra.Command, args []string) {
+	appName := args[0]
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application '%s' not found\n", errorStyle.Render("[error]"), appName)
+		os.Exit(1)
+	}
+
+	deployedAt := time.Unix(0, appDeploymentTimeoutDeployedAt)
+	timeout := time.Duration(application.DeploymentConfig.ConfirmationTimeout) * time.Second
+	time.Sleep(time.Until(deployedAt.Add(timeout)))
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 10*time.Minute); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
+	// the deployment may have been confirmed, rolled back or replaced while we slept
+	application, err = registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application '%s' not found\n", errorStyle.Render("[error]"), appName)
+		return
+	}
+
+	activeEnv := app.GetEnvironment(application, application.DeploymentState.Active)
+	if application.DeploymentStrategy != models.DeploymentStrategyBlueGreen ||
+		activeEnv == nil || activeEnv.DeployedAt.UnixNano() != appDeploymentTimeoutDeployedAt ||
+		!app.HasStandby(application) {
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  deployment of %s already settled, nothing to do", appName)))
+		return
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	reverted, decision, err := app.EnforceConfirmationTimeout(context.Background(), dockerClient, application)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s confirmation timeout for %s failed: %v\n", errorStyle.Render("[error]"), appName, err)
+		os.Exit(1)
+	}
+
+	application.UpdatedAt = time.Now()
+	if reverted {
+		application.LastDeployedAt = time.Now()
+	}
+
+	if err := registry.Update(*application); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("  [done] %s: %s\n", appName, decision)
+}
+
+// starts a detached `yap app deployment timeout` that outlives this command
+func scheduleConfirmationTimeout(application *models.Application) error {
+	activeEnv := app.GetEnvironment(application, application.DeploymentState.Active)
+	if activeEnv == nil {
+		return fmt.Errorf("no active environment")
+	}
+
+	return startDetached(fmt.Sprintf("%s-confirmation", application.Name), "app", "deployment", "timeout", application.Name,
+		"--deployed-at", strconv.FormatInt(activeEnv.DeployedAt.UnixNano(), 10))
+}
+
+// schedules the timeout when a blue-green deployment is waiting on a confirmation
+func maybeScheduleConfirmationTimeout(output io.Writer, application *models.Application) {
+	if application.DeploymentStrategy != models.DeploymentStrategyBlueGreen ||
+		application.DeploymentConfig.AutoConfirm ||
+		application.DeploymentConfig.ConfirmationTimeout <= 0 ||
+		!app.HasStandby(application) {
+		return
+	}
+
+	policy := application.DeploymentConfig.ConfirmationPolicy
+	if policy == "" {
+		policy = app.ConfirmationPolicyConfirm
+	}
+
+	if err := scheduleConfirmationTimeout(application); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to schedule confirmation timeout: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(output, dimStyle.Render(fmt.Sprintf("  unconfirmed deployments are settled automatically in %ds (policy: %s)",
+		application.DeploymentConfig.ConfirmationTimeout, policy)))
+}
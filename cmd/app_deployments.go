@@ -3,8 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
 	"github.com/spf13/cobra"
 )
 
@@ -60,24 +63,78 @@ func runAppDeployments(cmd *cobra.Command, args []string) {
 			statusStr = dimStyle.Render("superseded")
 		case "rolled-back":
 			statusStr = errorStyle.Render("rolled-back")
+		case "failed":
+			statusStr = errorStyle.Render("failed")
 		default:
 			statusStr = deployment.Status
 		}
 
-		fmt.Println(labelStyle.Render(fmt.Sprintf("  deployment #%d:", len(application.DeploymentHistory)-i)))
+		fmt.Println(labelStyle.Render(fmt.Sprintf("  release v%d:", app.ReleaseVersion(application, i))))
 		fmt.Printf("    id: %s\n", valueStyle.Render(deployment.ID))
-		fmt.Printf("    image: %s\n", dimStyle.Render(deployment.ImageID[:min(len(deployment.ImageID), 20)]))
+		if deployment.ImageTag != "" {
+			fmt.Printf("    image: %s\n", valueStyle.Render(deployment.ImageTag))
+			fmt.Printf("    digest: %s\n", dimStyle.Render(utils.TruncateID(deployment.ImageID, 19)))
+			if deployment.Source != "" {
+				fmt.Printf("    source: %s\n", dimStyle.Render(deployment.Source))
+			}
+		} else if deployment.ImageID != "" {
+			fmt.Printf("    image: %s %s\n", dimStyle.Render(deployment.ImageID[:min(len(deployment.ImageID), 20)]), dimStyle.Render("(unversioned)"))
+		}
+		if deployment.GitCommit != "" {
+			fmt.Printf("    commit: %s %s\n", valueStyle.Render(utils.TruncateID(deployment.GitCommit, 12)), dimStyle.Render(fmt.Sprintf("(%s)", describeGitRef(deployment))))
+		}
+		if deployment.BuildMethod != "" {
+			fmt.Printf("    build: %s\n", dimStyle.Render(describeBuild(deployment)))
+		}
+		if deployment.Config != nil {
+			fmt.Printf("    config: %s\n", dimStyle.Render(describeReleaseConfig(deployment.Config)))
+		}
+		if deployment.Operator != "" {
+			fmt.Printf("    operator: %s\n", dimStyle.Render(deployment.Operator))
+		}
 		fmt.Printf("    strategy: %s\n", valueStyle.Render(string(deployment.Strategy)))
 		fmt.Printf("    status: %s\n", statusStr)
+		if deployment.FailureReason != "" {
+			fmt.Printf("    reason: %s\n", errorStyle.Render(deployment.FailureReason))
+		}
+		if deployment.Decision != "" {
+			fmt.Printf("    decision: %s\n", dimStyle.Render(deployment.Decision))
+		}
 		fmt.Printf("    deployed: %s\n", dimStyle.Render(deployment.DeployedAt.Format("2006-01-02 15:04:05")))
 		fmt.Println()
 	}
 
 	fmt.Println(labelStyle.Render("  rollback:"))
 	fmt.Printf("    %s\n", dimStyle.Render(fmt.Sprintf("yap app rollback %s --version N", appName)))
+	fmt.Println(labelStyle.Render("  compare releases:"))
+	fmt.Printf("    %s\n", dimStyle.Render(fmt.Sprintf("yap app deployments diff %s A B", appName)))
 	fmt.Println()
 }
 
+func describeGitRef(record models.DeploymentRecord) string {
+	switch {
+	case record.GitBranch != "":
+		return "branch " + record.GitBranch
+	case record.GitRef != "":
+		return record.GitRef
+	case record.Source != "":
+		return "default branch"
+	}
+	return "detached"
+}
+
+func describeBuild(record models.DeploymentRecord) string {
+	if record.BuildDuration == 0 {
+		return string(record.BuildMethod)
+	}
+	return fmt.Sprintf("%s in %s", record.BuildMethod, record.BuildDuration.Round(time.Second))
+}
+
+func describeReleaseConfig(config *models.ReleaseConfig) string {
+	return fmt.Sprintf("%d instance(s), %d MB, %.2f cpu, port %d, %d env var(s)",
+		config.Instances, config.Memory, config.CPU, config.Port, len(config.EnvKeys))
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appDeploymentsDiffCmd = &cobra.Command{
+	Use:   "diff [name] [release-a] [release-b]",
+	Short: "Compare two releases",
+	Long:  "Show what changed between two releases of an application: image, source commit, build, strategy, resources and environment variable names",
+	Args:  cobra.ExactArgs(3),
+	Run:   runAppDeploymentsDiff,
+}
+
+func init() {
+	appDeploymentsCmd.AddCommand(appDeploymentsDiffCmd)
+}
+
+func runAppDeploymentsDiff(cmd *cobra.Command, args []string) {
+	appName := args[0]
+
+	versionA, err := parseReleaseVersion(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	versionB, err := parseReleaseVersion(args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application '%s' not found\n", errorStyle.Render("[error]"), appName)
+		os.Exit(1)
+	}
+
+	releaseA, err := app.FindRelease(application, versionA)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app deployments %s' to list releases", appName)))
+		os.Exit(1)
+	}
+	releaseB, err := app.FindRelease(application, versionB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app deployments %s' to list releases", appName)))
+		os.Exit(1)
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("==> release diff: %s v%d -> v%d", appName, versionA, versionB)))
+	fmt.Println()
+	printReleaseSummary(versionA, releaseA)
+	printReleaseSummary(versionB, releaseB)
+	fmt.Println()
+
+	diff := &deployPlan{}
+	diff.compare("image", releaseImage(releaseA), releaseImage(releaseB))
+	diff.compare("digest", releaseA.ImageID, releaseB.ImageID)
+	diff.compare("source", releaseA.Source, releaseB.Source)
+	diff.compare("git.commit", releaseA.GitCommit, releaseB.GitCommit)
+	diff.compare("git.branch", releaseA.GitBranch, releaseB.GitBranch)
+	diff.compare("git.ref", releaseA.GitRef, releaseB.GitRef)
+	diff.compare("build", string(releaseA.BuildMethod), string(releaseB.BuildMethod))
+	diff.compare("strategy", string(releaseA.Strategy), string(releaseB.Strategy))
+
+	configA, configB := releaseA.Config, releaseB.Config
+	if configA == nil || configB == nil {
+		if configA != configB {
+			fmt.Println(dimStyle.Render("  one of the releases predates config snapshots, resources and env can't be compared"))
+			fmt.Println()
+		}
+	} else {
+		diff.compare("instances", fmt.Sprintf("%d", configA.Instances), fmt.Sprintf("%d", configB.Instances))
+		diff.compare("memory", fmt.Sprintf("%d MB", configA.Memory), fmt.Sprintf("%d MB", configB.Memory))
+		diff.compare("cpu", fmt.Sprintf("%.2f", configA.CPU), fmt.Sprintf("%.2f", configB.CPU))
+		diff.compare("port", fmt.Sprintf("%d", configA.Port), fmt.Sprintf("%d", configB.Port))
+		diff.compareMap("env.", envKeySet(configA.EnvKeys), envKeySet(configB.EnvKeys), false)
+	}
+
+	if len(diff.changes) == 0 {
+		fmt.Println(successStyle.Render("  no differences"))
+		fmt.Println()
+		return
+	}
+
+	envChanged := false
+	for _, change := range diff.changes {
+		fmt.Println(change)
+		envChanged = envChanged || strings.HasPrefix(change.field, "env.")
+	}
+	fmt.Println()
+	if envChanged {
+		fmt.Println(dimStyle.Render("  env values aren't stored with releases, only which variables were set"))
+		fmt.Println()
+	}
+}
+
+// accepts 3 as well as v3
+func parseReleaseVersion(arg string) (int, error) {
+	version, err := strconv.Atoi(strings.TrimPrefix(arg, "v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid release: %s (use a version like 3 or v3)", arg)
+	}
+	return version, nil
+}
+
+func printReleaseSummary(version int, record *models.DeploymentRecord) {
+	summary := fmt.Sprintf("deployed %s", record.DeployedAt.Format("2006-01-02 15:04:05"))
+	if record.Operator != "" {
+		summary += " by " + record.Operator
+	}
+	summary += fmt.Sprintf(" (%s)", record.Status)
+
+	fmt.Printf("  %s %s\n", labelStyle.Render(fmt.Sprintf("v%d:", version)), dimStyle.Render(summary))
+	if record.FailureReason != "" {
+		fmt.Printf("      %s\n", errorStyle.Render(record.FailureReason))
+	}
+}
+
+func releaseImage(record *models.DeploymentRecord) string {
+	if record.ImageTag != "" {
+		return record.ImageTag
+	}
+	return record.ImageID
+}
+
+func envKeySet(keys []string) map[string]string {
+	set := make(map[string]string, len(keys))
+	for _, key := range keys {
+		set[key] = ""
+	}
+	return set
+}
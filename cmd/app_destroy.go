@@ -8,6 +8,7 @@ import (
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/router"
 	dockerTypes "github.com/docker/docker/api/types/container"
 	"github.com/spf13/cobra"
 )
@@ -72,10 +73,12 @@ func runAppDestroy(cmd *cobra.Command, args []string) {
 
 	ctx := context.Background()
 
-	for i, containerID := range application.ContainerIDs {
-		fmt.Println(labelStyle.Render(fmt.Sprintf("  --> removing instance %d/%d...", i+1, len(application.ContainerIDs))))
+	// web and every other process
+	containerIDs := app.AllContainerIDs(application)
+	for i, containerID := range containerIDs {
+		fmt.Println(labelStyle.Render(fmt.Sprintf("  --> removing instance %d/%d...", i+1, len(containerIDs))))
 
-		_ = dockerClient.StopContainer(containerID)
+		_ = app.StopInstance(ctx, dockerClient, application, containerID)
 
 		if err := dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{
 			Force: true,
@@ -93,6 +96,16 @@ func runAppDestroy(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// left behind, blue-green routing would send a later app of the same name to services that are gone
+	if err := router.NewTraefikManager(dockerClient).ClearColorRouting(appName); err != nil {
+		fmt.Printf("  [warn] %v\n", err)
+	}
+
+	// the scheduler drops the app's jobs on its next tick, their history goes with the app
+	if err := os.RemoveAll(app.CronDir(appName)); err != nil {
+		fmt.Printf("  [warn] failed to remove cron history: %v\n", err)
+	}
+
 	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s destroyed successfully", appName)))
 	fmt.Println()
 }
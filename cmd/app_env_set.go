@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aelpxy/yap/internal/app"
-	"github.com/aelpxy/yap/internal/database"
 	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/internal/utils"
 	"github.com/spf13/cobra"
@@ -55,6 +55,14 @@ func runAppEnvSet(cmd *cobra.Command, args []string) {
 	fmt.Println(titleStyle.Render(fmt.Sprintf("==> setting environment variables: %s", appName)))
 	fmt.Println()
 
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
 	registry, err := app.NewRegistryManager()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
@@ -120,49 +128,19 @@ func runAppEnvSet(cmd *cobra.Command, args []string) {
 		}
 		defer dockerClient.Close()
 
-		vpcRegistry, err := database.NewVPCRegistryManager()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed to load vpc registry: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
-		}
-		if err := vpcRegistry.Initialize(); err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed to initialize vpc registry: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
-		}
-
-		vpc, err := vpcRegistry.Get(application.VPC)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed to get vpc: %v\n", errorStyle.Render("[error]"), err)
-			os.Exit(1)
-		}
-
-		newContainerIDs := make([]string, 0, len(application.ContainerIDs))
-		for i, containerID := range application.ContainerIDs {
-			instanceNum := i + 1
-
-			newID, err := app.RecreateContainer(
-				dockerClient,
-				containerID,
-				application,
-				vpc.NetworkName,
-				instanceNum,
-			)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "%s failed to recreate instance: %v\n", errorStyle.Render("[error]"), err)
-				os.Exit(1)
-			}
-			newContainerIDs = append(newContainerIDs, newID)
-		}
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
 
-		application.ContainerIDs = newContainerIDs
-		if err := registry.Update(*application); err != nil {
-			fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		if err := rolloutConfiguration(ctx, dockerClient, registry, application); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed to restart: %v\n", errorStyle.Render("[error]"), err)
+			fmt.Println(dimStyle.Render(fmt.Sprintf("  the variables are saved, run 'yap app restart %s --rolling' to try again", appName)))
 			os.Exit(1)
 		}
 
 		fmt.Println(successStyle.Render("  [done] application restarted"))
+		printRolloutNextSteps(application)
 	} else {
 		fmt.Println()
-		fmt.Println(dimStyle.Render(fmt.Sprintf("  run 'yap app restart %s' to apply changes", appName)))
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  run 'yap app restart %s --rolling' to apply changes", appName)))
 	}
 }
@@ -8,12 +8,14 @@ import (
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/spf13/cobra"
 )
 
 var (
-	followAppLogs bool
+	followAppLogs  bool
+	appLogsProcess string
 )
 
 var appLogsCmd = &cobra.Command{
@@ -54,12 +56,18 @@ func runAppLogs(cmd *cobra.Command, args []string) {
 	fmt.Println(titleStyle.Render(fmt.Sprintf("==> logs: %s", appName)))
 	fmt.Println()
 
-	if len(application.ContainerIDs) == 0 {
+	view, _, err := app.ProcessApplication(application, appLogsProcess)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] %v", err)))
+		os.Exit(1)
+	}
+
+	if len(view.ContainerIDs) == 0 {
 		fmt.Fprintln(os.Stderr, errorStyle.Render("[error] no instances found"))
 		os.Exit(1)
 	}
 
-	containerID := application.ContainerIDs[0]
+	containerID := view.ContainerIDs[0]
 
 	logs, err := dockerClient.GetContainerLogs(containerID, followAppLogs)
 	if err != nil {
@@ -100,5 +108,6 @@ func runAppLogs(cmd *cobra.Command, args []string) {
 
 func init() {
 	appLogsCmd.Flags().BoolVarP(&followAppLogs, "follow", "f", false, "Follow log output")
+	appLogsCmd.Flags().StringVarP(&appLogsProcess, "process", "p", models.ProcessWeb, "Process to show logs for (e.g. worker)")
 	appCmd.AddCommand(appLogsCmd)
 }
@@ -105,6 +105,7 @@ var appPublishCmd = &cobra.Command{
 		fmt.Println()
 		fmt.Println("  " + dimStyle.Render("or visit in browser:"))
 		fmt.Println("  " + infoStyle.Render(fmt.Sprintf("  %s", application.PublishedURL)))
+		printRolloutNextSteps(application)
 	},
 }
 
@@ -152,6 +153,7 @@ var appUnpublishCmd = &cobra.Command{
 		fmt.Println()
 		fmt.Println("  app is now accessible only within vpc")
 		fmt.Println("  " + dimStyle.Render(fmt.Sprintf("internal: http://%s.yap.local", appName)))
+		printPublishingNextSteps(registry, appName)
 	},
 }
 
@@ -224,6 +226,7 @@ var appDomainAddCmd = &cobra.Command{
 		fmt.Println()
 		fmt.Println("  " + successStyle.Render("[info]") + " ssl certificate will be generated on first https access")
 		fmt.Println("  " + dimStyle.Render(fmt.Sprintf("test with: curl -I https://%s", domain)))
+		printPublishingNextSteps(registry, appName)
 	},
 }
 
@@ -269,9 +272,19 @@ var appDomainRemoveCmd = &cobra.Command{
 
 		fmt.Println()
 		fmt.Println(successStyle.Render("  [done]") + " domain removed")
+		printPublishingNextSteps(registry, appName)
 	},
 }
 
+// publishing changes roll web out like a deploy, blue-green apps are left with a standby to settle
+func printPublishingNextSteps(registry *app.RegistryManager, appName string) {
+	application, err := registry.Get(appName)
+	if err != nil {
+		return
+	}
+	printRolloutNextSteps(application)
+}
+
 func init() {
 	appCmd.AddCommand(appPublishCmd)
 	appCmd.AddCommand(appUnpublishCmd)
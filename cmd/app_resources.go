@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var appResourcesCmd = &cobra.Command{
+	Use:   "resources",
+	Short: "Manage application memory and cpu limits",
+	Long:  "Change the memory and cpu limits of running applications without rebuilding them",
+}
+
+func init() {
+	appCmd.AddCommand(appResourcesCmd)
+}
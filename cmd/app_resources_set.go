@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var appResourcesSetCmd = &cobra.Command{
+	Use:   "set [app-name]",
+	Short: "Change the memory and cpu limits of an application",
+	Long: `Change the memory and cpu limits of an application's instances without rebuilding it.
+
+The new limits are applied to the running instances in place. When the runtime can't
+update them, or with --rollout, the instances are replaced with the app's deployment
+strategy on its current image instead. Either way the limits are saved, so later
+deploys and scale ups keep them.
+
+Processes other than web inherit web's limits unless they set their own, use --process
+to change a single process.
+
+Examples:
+  yap app resources set myapp --memory 1024 --cpu 2
+  yap app resources set myapp --process worker --memory 2048
+  yap app resources set myapp --cpu 4 --rollout`,
+	Args: cobra.ExactArgs(1),
+	Run:  runAppResourcesSet,
+}
+
+var (
+	resourcesMemory  int
+	resourcesCPU     float64
+	resourcesProcess string
+	resourcesRollout bool
+)
+
+func init() {
+	appResourcesCmd.AddCommand(appResourcesSetCmd)
+
+	appResourcesSetCmd.Flags().IntVar(&resourcesMemory, "memory", 0, "Memory limit in MB")
+	appResourcesSetCmd.Flags().Float64Var(&resourcesCPU, "cpu", 0, "CPU cores")
+	appResourcesSetCmd.Flags().StringVar(&resourcesProcess, "process", models.ProcessWeb, "Process to change")
+	appResourcesSetCmd.Flags().BoolVar(&resourcesRollout, "rollout", false, "Replace the instances instead of updating them in place")
+}
+
+func runAppResourcesSet(cmd *cobra.Command, args []string) {
+	appName := args[0]
+
+	memorySet := cmd.Flags().Changed("memory")
+	cpuSet := cmd.Flags().Changed("cpu")
+
+	if !memorySet && !cpuSet {
+		fmt.Fprintf(os.Stderr, "%s must specify --memory, --cpu or both\n", errorStyle.Render("[error]"))
+		fmt.Println()
+		fmt.Println(dimStyle.Render("  usage examples:"))
+		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app resources set %s --memory 1024 --cpu 2", appName)))
+		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app resources set %s --process worker --memory 2048", appName)))
+		os.Exit(1)
+	}
+	if memorySet && resourcesMemory < constants.MinMemoryMB {
+		fmt.Fprintf(os.Stderr, "%s invalid memory: must be at least %dMB\n", errorStyle.Render("[error]"), constants.MinMemoryMB)
+		os.Exit(1)
+	}
+	if memorySet && resourcesMemory > constants.MaxMemoryMB {
+		fmt.Fprintf(os.Stderr, "%s invalid memory: maximum %dMB (%dGB)\n", errorStyle.Render("[error]"), constants.MaxMemoryMB, constants.MaxMemoryMB/1024)
+		os.Exit(1)
+	}
+	if cpuSet && resourcesCPU < constants.MinCPUCores {
+		fmt.Fprintf(os.Stderr, "%s invalid cpu: must be at least %d core\n", errorStyle.Render("[error]"), constants.MinCPUCores)
+		os.Exit(1)
+	}
+	if cpuSet && resourcesCPU > constants.MaxCPUCores {
+		fmt.Fprintf(os.Stderr, "%s invalid cpu: maximum %d cores\n", errorStyle.Render("[error]"), constants.MaxCPUCores)
+		os.Exit(1)
+	}
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		os.Exit(1)
+	}
+	defer lockManager.Unlock(appName)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application not found: %s\n", errorStyle.Render("[error]"), appName)
+		fmt.Println()
+		fmt.Println(dimStyle.Render("  check available apps:"))
+		fmt.Println(dimStyle.Render("    yap app list"))
+		os.Exit(1)
+	}
+
+	view, process, err := app.ProcessApplication(application, resourcesProcess)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		fmt.Println(dimStyle.Render(fmt.Sprintf("  processes: %s", strings.Join(processNames(application), ", "))))
+		os.Exit(1)
+	}
+
+	oldMemory, oldCPU := view.Memory, view.CPU
+	memory, cpu := oldMemory, oldCPU
+	if memorySet {
+		memory = resourcesMemory
+	}
+	if cpuSet {
+		cpu = resourcesCPU
+	}
+
+	if memory == oldMemory && cpu == oldCPU && !resourcesRollout {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("  [info] %s already runs with %dMB and %.1f cpu", resourcesProcess, memory, cpu)))
+		return
+	}
+
+	// kept to put back when the instances can't be changed
+	previous := *application
+	previous.Processes = append([]models.Process(nil), application.Processes...)
+
+	// a process only pins the limits given, the other one keeps following web
+	if process == nil {
+		application.Memory = memory
+		application.CPU = cpu
+	} else {
+		if memorySet {
+			process.Memory = memory
+		}
+		if cpuSet {
+			process.CPU = cpu
+		}
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("==> updating resources: %s", appName)))
+	fmt.Println()
+	fmt.Printf("  %s: %s\n", labelStyle.Render(resourcesProcess), valueStyle.Render(fmt.Sprintf("%dMB, %.1f cpu -> %dMB, %.1f cpu", oldMemory, oldCPU, memory, cpu)))
+
+	if application.ImageID == "" || application.Status == models.AppStatusStopped {
+		// nothing running, the next start or deploy creates the instances with the new limits
+		saveResources(registry, application)
+		fmt.Println(successStyle.Render("  [done] limits saved, they apply when the application starts"))
+		return
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	defer dockerClient.Close()
+
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	rollout := resourcesRollout
+	if !rollout {
+		fmt.Println(progressStyle.Render("  --> updating running instances..."))
+		if err := updateResourcesInPlace(ctx, dockerClient, application, process); err != nil {
+			fmt.Printf("  [warn] %v\n", err)
+			fmt.Println(dimStyle.Render("  the runtime can't update the instances in place, replacing them instead"))
+			rollout = true
+		}
+	}
+
+	if !rollout {
+		saveResources(registry, application)
+		fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s now runs with %dMB and %.1f cpu", resourcesProcess, memory, cpu)))
+		return
+	}
+
+	strategy := application.DeploymentStrategy
+	if strategy == "" {
+		strategy = models.DeploymentStrategyRecreate
+	}
+	fmt.Println(progressStyle.Render(fmt.Sprintf("  --> replacing instances (strategy: %s)...", strategy)))
+
+	if process == nil {
+		err = app.RolloutCurrentImage(ctx, dockerClient, application, nil)
+	} else {
+		err = app.DeployProcess(ctx, dockerClient, app.DeploymentOptions{
+			App:        application,
+			NewImageID: application.ImageID,
+			Config:     application.DeploymentConfig,
+			VPCName:    application.VPC,
+		}, process.Name)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n%s failed to replace instances: %v\n", errorStyle.Render("[error]"), err)
+
+		// the instances the strategy left behind are kept, the limits go back to what they were
+		application.Memory, application.CPU = previous.Memory, previous.CPU
+		for i := range application.Processes {
+			application.Processes[i].Memory = previous.Processes[i].Memory
+			application.Processes[i].CPU = previous.Processes[i].CPU
+		}
+		if len(application.ContainerIDs) == 0 {
+			application.Status = models.AppStatusFailed
+		}
+		saveResources(registry, application)
+		os.Exit(1)
+	}
+
+	saveResources(registry, application)
+	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s now runs with %dMB and %.1f cpu", resourcesProcess, memory, cpu)))
+
+	// processes are always rolled, only web goes through blue-green
+	if process == nil {
+		printRolloutNextSteps(application)
+	}
+}
+
+// updates the instances whose limits changed: the process, or web and the processes that
+// inherit web's limits
+func updateResourcesInPlace(ctx context.Context, dockerClient *docker.Client, application *models.Application, process *models.Process) error {
+	names := []string{models.ProcessWeb}
+	if process != nil {
+		names = []string{process.Name}
+	} else {
+		for _, p := range application.Processes {
+			if p.Memory == 0 || p.CPU == 0 {
+				names = append(names, p.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		view, _, err := app.ProcessApplication(application, name)
+		if err != nil {
+			return err
+		}
+		if err := app.UpdateResources(ctx, dockerClient, view); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func saveResources(registry *app.RegistryManager, application *models.Application) {
+	application.UpdatedAt = time.Now()
+	if err := registry.Update(*application); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+}
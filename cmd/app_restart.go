@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -8,17 +9,29 @@ import (
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/database"
 	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
 	"github.com/spf13/cobra"
 )
 
 var appRestartCmd = &cobra.Command{
 	Use:   "restart [name]",
 	Short: "Restart an application",
-	Long:  "Restart all instances of an application",
-	Args:  cobra.ExactArgs(1),
-	Run:   runAppRestart,
+	Long: `Restart all instances of an application.
+
+By default every instance is recreated in place, one after the other. With --rolling the
+instances are replaced with the app's deployment strategy on its current image instead,
+health checked the way a deploy rolls out, so rolling, blue-green and canary apps keep
+serving throughout.
+
+Examples:
+  yap app restart myapp
+  yap app restart myapp --rolling`,
+	Args: cobra.ExactArgs(1),
+	Run:  runAppRestart,
 }
 
+var restartRolling bool
+
 func runAppRestart(cmd *cobra.Command, args []string) {
 	appName := args[0]
 
@@ -57,6 +70,21 @@ func runAppRestart(cmd *cobra.Command, args []string) {
 	fmt.Println(titleStyle.Render(fmt.Sprintf("==> restarting application: %s", appName)))
 	fmt.Println()
 
+	if restartRolling {
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
+
+		if err := rolloutConfiguration(ctx, dockerClient, registry, application); err != nil {
+			fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to restart: %v", err)))
+			os.Exit(1)
+		}
+
+		fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s restarted successfully", appName)))
+		printRolloutNextSteps(application)
+		maybeEnsureWatchdog(os.Stdout, application)
+		return
+	}
+
 	vpcRegistry, err := database.NewVPCRegistryManager()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to load vpc registry: %v", err)))
@@ -93,16 +121,66 @@ func runAppRestart(cmd *cobra.Command, args []string) {
 	}
 
 	application.ContainerIDs = newContainerIDs
+	processErr := app.RecreateProcesses(dockerClient, application, vpc.NetworkName)
+
+	// the recreated instances start over, a crash loop failure goes with the old ones
+	if application.Status == models.AppStatusFailed && application.StatusReason != "" {
+		application.Status = models.AppStatusRunning
+	}
+	app.ClearCrashLoop(application)
 	if err := registry.Update(*application); err != nil {
 		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to update registry: %v", err)))
 		os.Exit(1)
 	}
+	if processErr != nil {
+		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to recreate process instances: %v", processErr)))
+		os.Exit(1)
+	}
 
 	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s restarted successfully", appName)))
 	fmt.Println()
 	fmt.Println(dimStyle.Render("  containers recreated with updated configuration"))
+	maybeEnsureWatchdog(os.Stdout, application)
+}
+
+// replaces every instance with the app's deployment strategy on its current image, so changed
+// configuration goes out health checked the way a deploy rolls it out. the instances the strategy
+// left running are saved either way
+func rolloutConfiguration(ctx context.Context, dockerClient *docker.Client, registry *app.RegistryManager, application *models.Application) error {
+	// nothing runs yet, the first deploy picks the configuration up
+	if application.ImageID == "" {
+		return nil
+	}
+
+	err := app.RolloutCurrentImage(ctx, dockerClient, application, nil)
+	if err == nil {
+		if application.Status == models.AppStatusFailed && application.StatusReason != "" {
+			application.Status = models.AppStatusRunning
+		}
+		app.ClearCrashLoop(application)
+	} else if len(application.ContainerIDs) == 0 {
+		application.Status = models.AppStatusFailed
+	}
+
+	application.UpdatedAt = time.Now()
+	if updateErr := registry.Update(*application); updateErr != nil {
+		return fmt.Errorf("failed to update registry: %w", updateErr)
+	}
+	return err
+}
+
+// a rollout through blue-green leaves the old environment as standby, the same as a deploy
+func printRolloutNextSteps(application *models.Application) {
+	if application.DeploymentStrategy != models.DeploymentStrategyBlueGreen || !app.HasStandby(application) {
+		return
+	}
+	fmt.Println()
+	printBlueGreenNextSteps(os.Stdout, application)
+	maybeScheduleConfirmationTimeout(os.Stdout, application)
 }
 
 func init() {
 	appCmd.AddCommand(appRestartCmd)
+
+	appRestartCmd.Flags().BoolVar(&restartRolling, "rolling", false, "Replace instances with the app's deployment strategy instead of recreating them in place")
 }
@@ -7,7 +7,7 @@ import (
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
-	"github.com/aelpxy/yap/internal/router"
+	"github.com/aelpxy/yap/internal/utils"
 	"github.com/aelpxy/yap/pkg/models"
 	"github.com/spf13/cobra"
 )
@@ -26,7 +26,7 @@ var appRollbackCmd = &cobra.Command{
 
 func init() {
 	appCmd.AddCommand(appRollbackCmd)
-	appRollbackCmd.Flags().IntVar(&rollbackVersion, "version", 0, "Deployment version to rollback to (0 = previous)")
+	appRollbackCmd.Flags().IntVar(&rollbackVersion, "version", 0, "Release version to rollback to (0 = release before the active one)")
 }
 
 func runAppRollback(cmd *cobra.Command, args []string) {
@@ -70,90 +70,93 @@ func runAppRollback(cmd *cobra.Command, args []string) {
 	var targetVersion int
 
 	if rollbackVersion == 0 {
-		targetVersion = len(application.DeploymentHistory) - 1
-		targetDeployment = &application.DeploymentHistory[targetVersion-1]
+		activeIndex := len(application.DeploymentHistory) - 1
+		for i := range application.DeploymentHistory {
+			if application.DeploymentHistory[i].Status == app.DeploymentStatusActive {
+				activeIndex = i
+			}
+		}
+		// releases that failed never ran, they're no place to go back to
+		targetDeployment, targetVersion = app.PreviousRelease(application, app.ReleaseVersion(application, activeIndex))
+		if targetDeployment == nil {
+			fmt.Fprintf(os.Stderr, "%s no release before the active one\n", errorStyle.Render("[error]"))
+			fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app deployments %s' and pass --version", appName)))
+			os.Exit(1)
+		}
 	} else {
-		if rollbackVersion < 1 || rollbackVersion > len(application.DeploymentHistory) {
+		targetDeployment, err = app.FindRelease(application, rollbackVersion)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s invalid version: %d\n", errorStyle.Render("[error]"), rollbackVersion)
-			fmt.Fprintf(os.Stderr, "  valid versions: 1-%d\n", len(application.DeploymentHistory))
+			fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app deployments %s' to list releases", appName)))
 			os.Exit(1)
 		}
 		targetVersion = rollbackVersion
-		targetDeployment = &application.DeploymentHistory[rollbackVersion-1]
 	}
 
-	fmt.Printf("  --> rolling back to deployment #%d\n", targetVersion)
-	fmt.Printf("    image: %s\n", dimStyle.Render(targetDeployment.ImageID[:min(len(targetDeployment.ImageID), 20)]))
+	fmt.Printf("  --> rolling back to release v%d\n", targetVersion)
+	if targetDeployment.ImageTag != "" {
+		fmt.Printf("    image: %s\n", dimStyle.Render(targetDeployment.ImageTag))
+	}
+	fmt.Printf("    digest: %s\n", dimStyle.Render(utils.TruncateID(targetDeployment.ImageID, 19)))
 	fmt.Printf("    deployed: %s\n", dimStyle.Render(targetDeployment.DeployedAt.Format("2006-01-02 15:04:05")))
 	fmt.Println()
 
+	if app.IsLegacyRelease(targetDeployment) {
+		fmt.Println(infoStyle.Render("  [info] this release predates versioned images"))
+		fmt.Println(dimStyle.Render(fmt.Sprintf("    %s may no longer contain the code of that release", targetDeployment.ImageID)))
+		fmt.Println()
+	}
+
 	dockerClient, err := docker.NewClient()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
 		os.Exit(1)
 	}
 
-	traefik := router.NewTraefikManager(dockerClient)
-
-	traefikLabels := traefik.GenerateLabelsForApp(application)
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
 
-	fmt.Println()
-	fmt.Println(progressStyle.Render("  --> validating image availability..."))
-	_, _, err = dockerClient.GetClient().ImageInspectWithRaw(cmd.Context(), targetDeployment.ImageID)
-	if err != nil {
-		fmt.Println()
-		fmt.Fprintf(os.Stderr, "%s target image not found\n", errorStyle.Render("[error]"))
-		fmt.Fprintf(os.Stderr, "  image: %s\n", dimStyle.Render(targetDeployment.ImageID))
-		fmt.Println()
-		fmt.Println(dimStyle.Render("  the image for this deployment has been deleted"))
-		fmt.Println(dimStyle.Render("  you may need to rebuild from source or use a different version"))
-		fmt.Println()
-		os.Exit(1)
+	if app.StandbyRunsRelease(application, targetDeployment) {
+		fmt.Printf("  --> %s environment still runs release v%d, switching traffic back...\n", application.DeploymentState.Standby, targetVersion)
+	} else {
+		fmt.Println(progressStyle.Render("  --> validating image availability..."))
+		targetImage, err := app.ResolveReleaseImage(ctx, dockerClient, targetDeployment)
+		if err != nil {
+			fmt.Println()
+			fmt.Fprintf(os.Stderr, "%s target image not found\n", errorStyle.Render("[error]"))
+			fmt.Fprintf(os.Stderr, "  image: %s\n", dimStyle.Render(targetDeployment.ImageID))
+			fmt.Println()
+			fmt.Println(dimStyle.Render("  the image for this deployment has been deleted"))
+			fmt.Println(dimStyle.Render("  you may need to rebuild from source or use a different version"))
+			fmt.Println()
+			os.Exit(1)
+		}
+		fmt.Println(dimStyle.Render(fmt.Sprintf("    image found (%s), proceeding with rollback", targetImage)))
 	}
-	fmt.Println(dimStyle.Render("    image found, proceeding with rollback"))
 
-	deployer, err := app.NewDeployer(application.DeploymentStrategy)
+	imageID, err := app.RedeployRelease(ctx, dockerClient, application, targetDeployment)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s failed to create deployer: %v\n", errorStyle.Render("[error]"), err)
-		os.Exit(1)
-	}
+		fmt.Fprintf(os.Stderr, "\n%s rollback failed: %v\n", errorStyle.Render("[error]"), err)
 
-	deployOpts := app.DeploymentOptions{
-		App:           application,
-		NewImageID:    targetDeployment.ImageID,
-		Config:        application.DeploymentConfig,
-		VPCName:       application.VPC,
-		TraefikLabels: traefikLabels,
-		MemoryMB:      application.Memory,
-		CPUCores:      application.CPU,
-	}
+		// keep the registry in line with the instances the strategy left running
+		if len(application.ContainerIDs) == 0 {
+			application.Status = models.AppStatusFailed
+		}
+		application.UpdatedAt = time.Now()
+		registry.Update(*application)
 
-	imageID, err := deployer.Deploy(deployOpts)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "\n%s rollback failed: %v\n", errorStyle.Render("[error]"), err)
+		lockManager.Unlock(appName)
 		os.Exit(1)
 	}
 
 	application.ImageID = imageID
 	application.Status = models.AppStatusRunning
+	app.ClearCrashLoop(application)
 	application.UpdatedAt = time.Now()
 	application.LastDeployedAt = time.Now()
 
-	rollbackRecord := models.DeploymentRecord{
-		ID:         fmt.Sprintf("dep-%s", time.Now().Format("20060102-150405")),
-		ImageID:    imageID,
-		Strategy:   application.DeploymentStrategy,
-		DeployedAt: time.Now(),
-		Status:     "active",
-	}
-
-	for i := range application.DeploymentHistory {
-		if application.DeploymentHistory[i].Status == "active" {
-			application.DeploymentHistory[i].Status = "rolled-back"
-		}
-	}
-
-	application.DeploymentHistory = append(application.DeploymentHistory, rollbackRecord)
+	rollbackRecord := app.NewRedeployRecord(application, targetDeployment)
+	app.RecordRelease(application, rollbackRecord, app.DeploymentStatusRolledBack)
 
 	if err := registry.Update(*application); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
@@ -161,9 +164,11 @@ func runAppRollback(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Println()
-	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] rolled back to deployment #%d", targetVersion)))
-	fmt.Printf("    current image: %s\n", dimStyle.Render(imageID[:min(len(imageID), 20)]))
+	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] rolled back to release v%d (new release v%d)", targetVersion, rollbackRecord.Version)))
+	fmt.Printf("    current image: %s\n", dimStyle.Render(imageID))
 	fmt.Println()
+	maybeScheduleConfirmationTimeout(os.Stdout, application)
+	maybeEnsureWatchdog(os.Stdout, application)
 	fmt.Println(dimStyle.Render(fmt.Sprintf("  use 'yap app deployments %s' to view history", appName)))
 	fmt.Println()
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+var appRunCmd = &cobra.Command{
+	Use:   "run [app-name] -- [command...]",
+	Short: "Run a one-off command in a new container",
+	Long: `Run a one-off command in an ephemeral container started from the application's
+current image, with its environment variables, volumes and vpc network attached.
+
+The container is removed when the command finishes and yap exits with the command's exit code.
+Use it for migrations and admin scripts that shouldn't run inside a serving instance.
+
+Examples:
+  yap app run myapp -- npm run migrate
+  yap app run myapp -- bundle exec rake db:seed
+  yap app run myapp -- sh -c 'echo $DATABASE_URL'`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runAppRun,
+}
+
+func init() {
+	appCmd.AddCommand(appRunCmd)
+}
+
+func runAppRun(cmd *cobra.Command, args []string) {
+	appName := args[0]
+	command := args[1:]
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s application '%s' not found\n", errorStyle.Render("[error]"), appName)
+		os.Exit(1)
+	}
+
+	if application.ImageID == "" {
+		fmt.Fprintf(os.Stderr, "%s application has no image, deploy it first\n", errorStyle.Render("[error]"))
+		os.Exit(1)
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	// ctrl-c stops the task, RunTask still removes the container
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("==> running in %s (%s)", appName, application.ImageID)))
+
+	exitCode, err := app.RunTask(ctx, dockerClient, application, application.ImageID, "run", command, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if exitCode != 0 {
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("==> exited with code %d", exitCode)))
+	}
+
+	stop()
+	os.Exit(exitCode)
+}
@@ -3,11 +3,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/constants"
 	"github.com/aelpxy/yap/internal/database"
 	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
 	"github.com/spf13/cobra"
 )
 
@@ -18,10 +22,10 @@ var (
 )
 
 var appScaleCmd = &cobra.Command{
-	Use:   "scale [app-name]",
+	Use:   "scale [app-name] [process=count...]",
 	Short: "Scale an application",
-	Long:  "Scale an application to a specific number of instances or add/remove instances",
-	Args:  cobra.ExactArgs(1),
+	Long:  "Scale an application to a specific number of instances or add/remove instances. Processes like worker scale independently with process=count",
+	Args:  cobra.MinimumNArgs(1),
 	Run:   runAppScale,
 }
 
@@ -35,6 +39,12 @@ func init() {
 func runAppScale(cmd *cobra.Command, args []string) {
 	appName := args[0]
 
+	targets, err := parseScaleTargets(args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
 	flagsSet := 0
 	instancesSet := cmd.Flags().Changed("instances")
 	addSet := cmd.Flags().Changed("add")
@@ -50,19 +60,20 @@ func runAppScale(cmd *cobra.Command, args []string) {
 		flagsSet++
 	}
 
-	if flagsSet == 0 {
-		fmt.Fprintf(os.Stderr, "%s must specify --instances, --add, or --remove\n", errorStyle.Render("[error]"))
-		fmt.Println()
-		fmt.Println(dimStyle.Render("  usage examples:"))
-		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app scale %s --instances 3   # scale to exactly 3 instances", appName)))
-		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app scale %s --add 2         # add 2 more instances", appName)))
-		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app scale %s --remove 1      # remove 1 instance", appName)))
+	if flagsSet == 0 && len(targets) == 0 {
+		fmt.Fprintf(os.Stderr, "%s must specify --instances, --add, --remove or process=count\n", errorStyle.Render("[error]"))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, dimStyle.Render("  usage examples:"))
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("    yap app scale %s --instances 3   # scale to exactly 3 instances", appName)))
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("    yap app scale %s --add 2         # add 2 more instances", appName)))
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("    yap app scale %s --remove 1      # remove 1 instance", appName)))
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("    yap app scale %s web=2 worker=3  # scale processes independently", appName)))
 		os.Exit(1)
 	}
-	if flagsSet > 1 {
-		fmt.Fprintf(os.Stderr, "%s can only use one of --instances, --add, or --remove\n", errorStyle.Render("[error]"))
-		fmt.Println()
-		fmt.Println(dimStyle.Render("  use only one scaling option at a time"))
+	if flagsSet > 1 || (flagsSet > 0 && len(targets) > 0) {
+		fmt.Fprintf(os.Stderr, "%s can only use one of --instances, --add, --remove or process=count\n", errorStyle.Render("[error]"))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, dimStyle.Render("  use only one scaling option at a time"))
 		os.Exit(1)
 	}
 
@@ -82,7 +93,7 @@ func runAppScale(cmd *cobra.Command, args []string) {
 	lockManager := app.GetGlobalLockManager()
 	if err := lockManager.TryLock(appName, 5*time.Second); err != nil {
 		fmt.Fprintf(os.Stderr, "%s another operation in progress for %s\n", errorStyle.Render("[error]"), appName)
-		fmt.Println(dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
+		fmt.Fprintln(os.Stderr, dimStyle.Render("  wait for the current operation to complete or try again in a few seconds"))
 		os.Exit(1)
 	}
 	defer lockManager.Unlock(appName)
@@ -100,44 +111,37 @@ func runAppScale(cmd *cobra.Command, args []string) {
 	application, err := registry.Get(appName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s application not found: %s\n", errorStyle.Render("[error]"), appName)
-		fmt.Println()
-		fmt.Println(dimStyle.Render("  check available apps:"))
-		fmt.Println(dimStyle.Render("    yap app list"))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, dimStyle.Render("  check available apps:"))
+		fmt.Fprintln(os.Stderr, dimStyle.Render("    yap app list"))
 		os.Exit(1)
 	}
 
-	currentInstances := len(application.ContainerIDs)
-	targetInstances := currentInstances
+	if len(targets) == 0 {
+		currentInstances := len(application.ContainerIDs)
+		targetInstances := currentInstances
 
-	if scaleInstances > 0 {
-		targetInstances = scaleInstances
-	} else if scaleAdd > 0 {
-		targetInstances = currentInstances + scaleAdd
-	} else if scaleRemove > 0 {
-		targetInstances = currentInstances - scaleRemove
-	}
+		if scaleInstances > 0 {
+			targetInstances = scaleInstances
+		} else if scaleAdd > 0 {
+			targetInstances = currentInstances + scaleAdd
+		} else if scaleRemove > 0 {
+			targetInstances = currentInstances - scaleRemove
+		}
 
-	if targetInstances < 1 {
-		fmt.Fprintf(os.Stderr, "%s cannot scale to less than 1 instance\n", errorStyle.Render("[error]"))
-		fmt.Println()
-		fmt.Printf("  current instances: %s\n", valueStyle.Render(fmt.Sprintf("%d", currentInstances)))
-		fmt.Printf("  requested change would result in: %s\n", errorStyle.Render(fmt.Sprintf("%d instances", targetInstances)))
-		fmt.Println()
-		fmt.Println(dimStyle.Render("  minimum is 1 instance. to remove the app entirely, use:"))
-		fmt.Println(dimStyle.Render(fmt.Sprintf("    yap app destroy %s", appName)))
-		os.Exit(1)
+		targets = []scaleTarget{{process: models.ProcessWeb, instances: targetInstances}}
 	}
 
-	if targetInstances == currentInstances {
-		fmt.Println(infoStyle.Render(fmt.Sprintf("  [info] already at %d instances", currentInstances)))
-		return
+	for _, target := range targets {
+		if _, _, err := app.ProcessApplication(application, target.process); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+			fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("  processes: %s", strings.Join(processNames(application), ", "))))
+			os.Exit(1)
+		}
 	}
 
 	fmt.Println(titleStyle.Render(fmt.Sprintf("==> scaling application: %s", appName)))
 	fmt.Println()
-	fmt.Printf("  current: %s\n", valueStyle.Render(fmt.Sprintf("%d instances", currentInstances)))
-	fmt.Printf("  target: %s\n", valueStyle.Render(fmt.Sprintf("%d instances", targetInstances)))
-	fmt.Println()
 
 	dockerClient, err := docker.NewClient()
 	if err != nil {
@@ -161,44 +165,125 @@ func runAppScale(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	for _, target := range targets {
+		scaleProcess(dockerClient, registry, application, vpc.NetworkName, target)
+	}
+
+	if len(targets) == 1 && targets[0].process == models.ProcessWeb {
+		fmt.Println()
+		fmt.Println(dimStyle.Render("  traffic is load balanced across all instances"))
+	}
+}
+
+type scaleTarget struct {
+	process   string
+	instances int
+}
+
+// worker=3 style arguments, in the order given
+func parseScaleTargets(args []string) ([]scaleTarget, error) {
+	targets := make([]scaleTarget, 0, len(args))
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+		name, count, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid scale target: %s (use process=count, e.g. worker=3)", arg)
+		}
+		instances, err := strconv.Atoi(count)
+		if err != nil || instances < 0 {
+			return nil, fmt.Errorf("invalid instance count for %s: %s", name, count)
+		}
+		if instances > constants.MaxInstances {
+			return nil, fmt.Errorf("invalid instance count for %s: maximum %d instances", name, constants.MaxInstances)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("process %s given twice", name)
+		}
+		seen[name] = true
+
+		targets = append(targets, scaleTarget{process: name, instances: instances})
+	}
+
+	return targets, nil
+}
+
+func processNames(application *models.Application) []string {
+	names := []string{models.ProcessWeb}
+	for _, process := range application.Processes {
+		names = append(names, process.Name)
+	}
+	return names
+}
+
+// scales one process to its target and saves the app, so processes scaled before a failure stay recorded
+func scaleProcess(dockerClient *docker.Client, registry *app.RegistryManager, application *models.Application, vpcNetworkName string, target scaleTarget) {
+	view, process, _ := app.ProcessApplication(application, target.process)
+
+	currentInstances := len(view.ContainerIDs)
+	targetInstances := target.instances
+
+	if process == nil && targetInstances < 1 {
+		fmt.Fprintf(os.Stderr, "%s cannot scale to less than 1 instance\n", errorStyle.Render("[error]"))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "  current instances: %s\n", valueStyle.Render(fmt.Sprintf("%d", currentInstances)))
+		fmt.Fprintf(os.Stderr, "  requested change would result in: %s\n", errorStyle.Render(fmt.Sprintf("%d instances", targetInstances)))
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, dimStyle.Render("  minimum is 1 instance. to remove the app entirely, use:"))
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("    yap app destroy %s", application.Name)))
+		os.Exit(1)
+	}
+
+	if targetInstances == currentInstances {
+		fmt.Println(infoStyle.Render(fmt.Sprintf("  [info] %s already at %d instances", target.process, currentInstances)))
+		return
+	}
+
+	fmt.Printf("  %s: %s\n", labelStyle.Render(target.process), valueStyle.Render(fmt.Sprintf("%d -> %d instances", currentInstances, targetInstances)))
+
 	if targetInstances > currentInstances {
 		count := targetInstances - currentInstances
-		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> adding %d instance(s)...", count)))
+		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> adding %d instance(s), waiting for them to pass health checks...", count)))
 
 		newIDs, err := app.ScaleUp(
 			dockerClient,
-			application,
+			view,
 			count,
-			vpc.NetworkName,
+			vpcNetworkName,
 		)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s failed to scale up: %v\n", errorStyle.Render("[error]"), err)
 			os.Exit(1)
 		}
 
-		application.ContainerIDs = append(application.ContainerIDs, newIDs...)
+		view.ContainerIDs = append(view.ContainerIDs, newIDs...)
 	} else {
 		count := currentInstances - targetInstances
 		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> removing %d instance(s)...", count)))
 
-		if err := app.ScaleDown(dockerClient, application, count); err != nil {
+		remaining, err := app.ScaleDown(dockerClient, view, count)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s failed to scale down: %v\n", errorStyle.Render("[error]"), err)
 			os.Exit(1)
 		}
 
-		application.ContainerIDs = application.ContainerIDs[:targetInstances]
+		view.ContainerIDs = remaining
 	}
 
-	application.Instances = targetInstances
+	if process == nil {
+		application.Instances = targetInstances
+	} else {
+		process.ContainerIDs = view.ContainerIDs
+		process.Instances = targetInstances
+	}
 
 	if err := registry.Update(*application); err != nil {
 		fmt.Fprintf(os.Stderr, "%s failed to update registry: %v\n", errorStyle.Render("[error]"), err)
 		os.Exit(1)
 	}
 
-	fmt.Println(progressStyle.Render("  --> updating load balancer..."))
-	fmt.Println()
-	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] scaled to %d instances", targetInstances)))
-	fmt.Println()
-	fmt.Println(dimStyle.Render("  traffic is load balanced across all instances"))
+	if process == nil {
+		fmt.Println(progressStyle.Render("  --> updating load balancer..."))
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] scaled %s to %d instances", target.process, targetInstances)))
 }
@@ -58,8 +58,10 @@ func runAppStart(cmd *cobra.Command, args []string) {
 
 	ctx := context.Background()
 
-	for i, containerID := range application.ContainerIDs {
-		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> starting instance %d/%d...", i+1, len(application.ContainerIDs))))
+	// web and every other process
+	containerIDs := app.AllContainerIDs(application)
+	for i, containerID := range containerIDs {
+		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> starting instance %d/%d...", i+1, len(containerIDs))))
 
 		if err := dockerClient.GetClient().ContainerStart(ctx, containerID, dockerTypes.StartOptions{}); err != nil {
 			fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to start instance: %v", err)))
@@ -68,6 +70,7 @@ func runAppStart(cmd *cobra.Command, args []string) {
 	}
 
 	application.Status = models.AppStatusRunning
+	app.ClearCrashLoop(application)
 	application.UpdatedAt = time.Now()
 	if err := registry.Update(*application); err != nil {
 		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to update registry: %v", err)))
@@ -76,6 +79,7 @@ func runAppStart(cmd *cobra.Command, args []string) {
 
 	fmt.Println(successStyle.Render(fmt.Sprintf("  [done] %s started successfully", appName)))
 	fmt.Println()
+	maybeEnsureWatchdog(os.Stdout, application)
 }
 
 func init() {
@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/containerd/errdefs"
 	"github.com/spf13/cobra"
 )
 
@@ -46,14 +50,16 @@ func runAppStatus(cmd *cobra.Command, args []string) {
 	}
 	defer dockerClient.Close()
 
-	var containerStatuses []string
-	for _, containerID := range application.ContainerIDs {
-		status, err := dockerClient.GetContainerStatus(containerID)
+	instanceStates := make([]app.InstanceState, len(application.ContainerIDs))
+	for i, containerID := range application.ContainerIDs {
+		state, err := app.InspectInstance(context.Background(), dockerClient, containerID)
 		if err != nil {
-			containerStatuses = append(containerStatuses, "unknown")
-		} else {
-			containerStatuses = append(containerStatuses, status)
+			state.Status = "unknown"
+			if errdefs.IsNotFound(err) {
+				state.Status = "not found"
+			}
 		}
+		instanceStates[i] = state
 	}
 
 	fmt.Println(titleStyle.Render(fmt.Sprintf("==> status: %s", appName)))
@@ -79,12 +85,19 @@ func runAppStatus(cmd *cobra.Command, args []string) {
 	}
 	statusStyled := lipgloss.NewStyle().Foreground(lipgloss.Color(statusColor)).Render(string(application.Status))
 	fmt.Printf("    %s %s\n", dimStyle.Render("status:"), statusStyled)
+	if application.StatusReason != "" {
+		fmt.Printf("    %s %s\n", dimStyle.Render("reason:"), errorStyle.Render(application.StatusReason))
+	}
 	fmt.Println()
 
 	fmt.Println(labelStyle.Render("  deployment:"))
 	fmt.Printf("    %s %s\n", dimStyle.Render("build type:"), valueStyle.Render(string(application.BuildType)))
+	if application.Buildpack != "" {
+		fmt.Printf("    %s %s\n", dimStyle.Render("builder:"), valueStyle.Render(application.Buildpack))
+	}
 	fmt.Printf("    %s %s\n", dimStyle.Render("image:"), dimStyle.Render(utils.TruncateID(application.ImageID, 12)))
 	fmt.Printf("    %s %s\n", dimStyle.Render("instances:"), valueStyle.Render(fmt.Sprintf("%d", application.Instances)))
+	fmt.Printf("    %s %s\n", dimStyle.Render("autoscaling:"), valueStyle.Render(describeAutoscaling(application)))
 	fmt.Printf("    %s %s\n", dimStyle.Render("strategy:"), valueStyle.Render(string(application.DeploymentStrategy)))
 	fmt.Printf("    %s %s\n", dimStyle.Render("deployed:"), valueStyle.Render(application.LastDeployedAt.Format("2006-01-02 15:04:05")))
 	fmt.Println()
@@ -112,23 +125,56 @@ func runAppStatus(cmd *cobra.Command, args []string) {
 	fmt.Println()
 
 	fmt.Println(labelStyle.Render("  health checks:"))
-	fmt.Printf("    %s %s\n", dimStyle.Render("endpoint:"), valueStyle.Render(application.HealthCheckPath))
-	fmt.Printf("    %s %s\n", dimStyle.Render("interval:"), valueStyle.Render(fmt.Sprintf("%ds", application.HealthCheckInterval)))
-	fmt.Printf("    %s %s\n", dimStyle.Render("timeout:"), valueStyle.Render(fmt.Sprintf("%ds", application.HealthCheckTimeout)))
+	fmt.Printf("    %s %s\n", dimStyle.Render("readiness:"), valueStyle.Render(app.DescribeProbe(app.ReadinessProbe(application))))
+	fmt.Printf("    %s %s\n", dimStyle.Render("liveness:"), valueStyle.Render(app.DescribeProbe(app.LivenessProbe(application))))
+	fmt.Printf("    %s %s\n", dimStyle.Render("stop:"), valueStyle.Render(describeStop(application)))
+	fmt.Printf("    %s %s\n", dimStyle.Render("crash loop:"), valueStyle.Render(describeCrashLoop(application)))
 	fmt.Println()
 
 	fmt.Println(labelStyle.Render("  instances:"))
 	for i, containerID := range application.ContainerIDs {
-		status := containerStatuses[i]
+		state := instanceStates[i]
+		status := state.Status
 		statusColor := "10"
-		if status != "running" {
+		if crashed := app.FindCrashedInstance(application, containerID); crashed != nil {
+			status = describeCrashedInstance(crashed)
+			statusColor = "9"
+		} else if status != "running" {
 			statusColor = "240"
 		}
 		statusStyled := lipgloss.NewStyle().Foreground(lipgloss.Color(statusColor)).Render(status)
-		fmt.Printf("    %s %s - %s\n", dimStyle.Render(fmt.Sprintf("[%d]", i+1)), dimStyle.Render(utils.TruncateID(containerID, 12)), statusStyled)
+		fmt.Printf("    %s %s - %s", dimStyle.Render(fmt.Sprintf("[%d]", i+1)), dimStyle.Render(utils.TruncateID(containerID, 12)), statusStyled)
+		if state.Restarts > 0 || state.OOMKilled {
+			fmt.Printf(" %s", dimStyle.Render(fmt.Sprintf("(%d restarts, last %s)", state.Restarts, app.DescribeExit(state.ExitCode, state.OOMKilled))))
+		}
+		fmt.Println()
+	}
+	for _, crashed := range application.CrashedInstances {
+		if crashed.Process != models.ProcessWeb {
+			fmt.Printf("    %s %s - %s\n", dimStyle.Render(crashed.Process), dimStyle.Render(utils.TruncateID(crashed.ContainerID, 12)),
+				errorStyle.Render(describeCrashedInstance(&crashed)))
+		}
 	}
 	fmt.Println()
 
+	if application.Command != "" || len(application.Processes) > 0 {
+		fmt.Println(labelStyle.Render("  processes:"))
+		if application.Command != "" {
+			fmt.Printf("    %s %s\n", dimStyle.Render("web:"), valueStyle.Render(application.Command))
+		}
+		for _, process := range application.Processes {
+			running := 0
+			for _, containerID := range process.ContainerIDs {
+				if status, err := dockerClient.GetContainerStatus(containerID); err == nil && status == "running" {
+					running++
+				}
+			}
+			fmt.Printf("    %s %s %s\n", dimStyle.Render(process.Name+":"), valueStyle.Render(describeProcess(application, process)),
+				dimStyle.Render(fmt.Sprintf("(%d/%d running)", running, len(process.ContainerIDs))))
+		}
+		fmt.Println()
+	}
+
 	if len(application.LinkedDatabases) > 0 {
 		fmt.Println(labelStyle.Render("  linked databases:"))
 		for _, dbName := range application.LinkedDatabases {
@@ -166,6 +212,18 @@ func runAppStatus(cmd *cobra.Command, args []string) {
 	fmt.Println()
 }
 
+func describeCrashedInstance(crashed *models.CrashedInstance) string {
+	if !crashed.Stopped {
+		return fmt.Sprintf("started again after crash looping, %s (stopped %d time(s))", crashed.Reason, crashed.Attempts)
+	}
+
+	retry := "on the watchdog's next look"
+	if wait := time.Until(crashed.RetryAt); wait > 0 {
+		retry = "in " + wait.Round(time.Second).String()
+	}
+	return fmt.Sprintf("stopped for crash looping, %s, starting again %s (stopped %d time(s))", crashed.Reason, retry, crashed.Attempts)
+}
+
 func init() {
 	appCmd.AddCommand(appStatusCmd)
 }
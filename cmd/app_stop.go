@@ -9,7 +9,6 @@ import (
 	"github.com/aelpxy/yap/internal/app"
 	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/pkg/models"
-	dockerTypes "github.com/docker/docker/api/types/container"
 	"github.com/spf13/cobra"
 )
 
@@ -57,14 +56,13 @@ func runAppStop(cmd *cobra.Command, args []string) {
 	fmt.Println()
 
 	ctx := context.Background()
-	timeout := 10
 
-	for i, containerID := range application.ContainerIDs {
-		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> stopping instance %d/%d...", i+1, len(application.ContainerIDs))))
+	// web and every other process
+	containerIDs := app.AllContainerIDs(application)
+	for i, containerID := range containerIDs {
+		fmt.Println(progressStyle.Render(fmt.Sprintf("  --> stopping instance %d/%d...", i+1, len(containerIDs))))
 
-		if err := dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
-			Timeout: &timeout,
-		}); err != nil {
+		if err := app.StopInstance(ctx, dockerClient, application, containerID); err != nil {
 			fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] failed to stop instance: %v", err)))
 			os.Exit(1)
 		}
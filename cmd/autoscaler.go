@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/database"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var autoscalerCmd = &cobra.Command{
+	Use:   "autoscaler",
+	Short: "Scale applications on their cpu and memory usage",
+	Long: `Run the autoscaler, which samples the cpu and memory usage of every application
+with auto_scaling = true and scales its web instances between min_instances and
+max_instances.
+
+Usage is a percentage of each instance's limits, averaged across instances. It has to
+stay over cpu_threshold (or memory_threshold) for scale_up_delay seconds before
+instances are added, and low enough that one instance fewer stays under the thresholds
+for scale_down_delay seconds before one is removed. Every decision is logged.
+
+Deploys of apps with auto_scaling start it in the background, so this is only needed
+to run it under a service manager. Only one autoscaler runs at a time, and it exits
+once no application has autoscaling enabled unless --keep-running is given.
+
+Examples:
+  yap autoscaler
+  yap autoscaler --interval 15 --dry-run`,
+	Args: cobra.NoArgs,
+	Run:  runAutoscaler,
+}
+
+var (
+	autoscalerInterval    int
+	autoscalerDryRun      bool
+	autoscalerKeepRunning bool
+)
+
+func init() {
+	rootCmd.AddCommand(autoscalerCmd)
+
+	autoscalerCmd.Flags().IntVar(&autoscalerInterval, "interval", 30, "Seconds between usage samples")
+	autoscalerCmd.Flags().BoolVar(&autoscalerDryRun, "dry-run", false, "Log decisions without scaling anything")
+	autoscalerCmd.Flags().BoolVar(&autoscalerKeepRunning, "keep-running", false, "Keep running when no application has autoscaling enabled")
+}
+
+var autoscalerLock = app.DaemonLockName("autoscaler")
+
+func runAutoscaler(cmd *cobra.Command, args []string) {
+	if autoscalerInterval < 1 {
+		fmt.Fprintf(os.Stderr, "%s interval must be at least 1 second\n", errorStyle.Render("[error]"))
+		os.Exit(1)
+	}
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(autoscalerLock, time.Second); err != nil {
+		// another autoscaler already watches the apps
+		return
+	}
+	defer lockManager.Unlock(autoscalerLock)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	defer dockerClient.Close()
+
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	logTimestampedf("autoscaler started (pid %d, sampling every %ds)", os.Getpid(), autoscalerInterval)
+
+	autoscaler := app.NewAutoscaler()
+	ticker := time.NewTicker(time.Duration(autoscalerInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		applications, err := registry.List()
+		if err != nil {
+			logTimestampedf("failed to read registry: %v", err)
+		}
+
+		enabled := 0
+		for i := range applications {
+			application := &applications[i]
+			if !application.AutoScaleEnabled {
+				continue
+			}
+			enabled++
+
+			if app.Autoscalable(application) {
+				autoscaleApp(ctx, dockerClient, registry, autoscaler, application)
+			} else {
+				autoscaler.Reset(application.Name)
+			}
+		}
+
+		if err == nil && enabled == 0 && !autoscalerKeepRunning {
+			logTimestampedf("no application has autoscaling enabled, autoscaler exiting")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			logTimestampedf("autoscaler stopping")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func autoscaleApp(ctx context.Context, dockerClient *docker.Client, registry *app.RegistryManager, autoscaler *app.Autoscaler, application *models.Application) {
+	usage, err := app.SampleUsage(ctx, dockerClient, application)
+	if err != nil {
+		logTimestampedf("%s: skipped, %v", application.Name, err)
+		return
+	}
+
+	decision := autoscaler.Evaluate(application, usage, time.Now())
+	if decision.Action == app.ScalingActionHold {
+		logTimestampedf("%s: hold at %d instances (%s)", application.Name, decision.From, decision.Reason)
+		return
+	}
+
+	logTimestampedf("%s: scale %s %d -> %d instances (%s)", application.Name, decision.Action, decision.From, decision.To, decision.Reason)
+	if autoscalerDryRun {
+		return
+	}
+
+	if err := applyScalingDecision(dockerClient, registry, decision); err != nil {
+		logTimestampedf("%s: scaling failed, %v", application.Name, err)
+		return
+	}
+
+	autoscaler.Reset(application.Name)
+	logTimestampedf("%s: scaled to %d instances", application.Name, decision.To)
+}
+
+// carries out a decision under the app lock, the same way yap app scale does. a deploy or manual
+// scale holding the lock wins, the next sample looks at the app again
+func applyScalingDecision(dockerClient *docker.Client, registry *app.RegistryManager, decision app.ScalingDecision) error {
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(decision.App, time.Second); err != nil {
+		return fmt.Errorf("another operation in progress, retrying on the next sample")
+	}
+	defer lockManager.Unlock(decision.App)
+
+	application, err := registry.Get(decision.App)
+	if err != nil {
+		return err
+	}
+	if !app.Autoscalable(application) || len(application.ContainerIDs) != decision.From {
+		return fmt.Errorf("application changed since it was sampled, retrying on the next sample")
+	}
+
+	vpcRegistry, err := database.NewVPCRegistryManager()
+	if err != nil {
+		return fmt.Errorf("failed to load vpc registry: %w", err)
+	}
+	if err := vpcRegistry.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize vpc registry: %w", err)
+	}
+	vpc, err := vpcRegistry.Get(application.VPC)
+	if err != nil {
+		return fmt.Errorf("failed to get vpc: %w", err)
+	}
+
+	if decision.Action == app.ScalingActionUp {
+		// a failed scale up removes what it started, the app is left as it was
+		newIDs, err := app.ScaleUp(dockerClient, application, decision.To-decision.From, vpc.NetworkName)
+		if err != nil {
+			return err
+		}
+		application.ContainerIDs = append(application.ContainerIDs, newIDs...)
+	} else {
+		remaining, err := app.ScaleDown(dockerClient, application, decision.From-decision.To)
+		if err != nil {
+			return err
+		}
+		application.ContainerIDs = remaining
+	}
+
+	application.Instances = len(application.ContainerIDs)
+	if err := registry.Update(*application); err != nil {
+		return fmt.Errorf("failed to update registry: %w", err)
+	}
+	return nil
+}
+
+// starts the autoscaler when the application has autoscaling on, warning instead of failing the command
+func maybeEnsureAutoscaler(output io.Writer, application *models.Application) {
+	if !application.AutoScaleEnabled {
+		return
+	}
+	if err := startDetached("autoscaler", "autoscaler"); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to start the autoscaler: %v\n", err)
+	}
+}
+
+func describeAutoscaling(application *models.Application) string {
+	if !application.AutoScaleEnabled {
+		return "off"
+	}
+
+	description := fmt.Sprintf("%d-%d instances, cpu %d%%", application.MinInstances, application.MaxInstances, application.TargetCPU)
+	if application.TargetMemory > 0 {
+		description += fmt.Sprintf(", memory %d%%", application.TargetMemory)
+	}
+	return description + fmt.Sprintf(", up after %ds, down after %ds", application.ScaleUpDelay, application.ScaleDownDelay)
+}
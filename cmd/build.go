@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Inspect how projects are built",
+	Long:  "Inspect what yap sends to the builder when it builds a project",
+}
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+}
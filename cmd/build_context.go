@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aelpxy/yap/internal/builder"
+	"github.com/aelpxy/yap/internal/project"
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var buildContextCmd = &cobra.Command{
+	Use:   "context [path]",
+	Short: "Show what a dockerfile build sends to the daemon",
+	Long: `Show the build context a dockerfile build of the project sends to the daemon.
+
+Files are left out with the patterns of <Dockerfile>.dockerignore next to the Dockerfile,
+or the context's .dockerignore, with the same rules as docker build: later patterns win
+and ! brings files back. Without a .dockerignore, [build] exclude from yap.toml is used,
+and without that the default exclusions (.git, node_modules, .env, dist, build, ...).
+
+The Dockerfile and .dockerignore are always sent. nixpacks and paketo builds read the
+project directory themselves.
+
+Examples:
+  yap build context
+  yap build context ./api --list
+  yap build context . --dockerfile docker/api.Dockerfile --context services/api`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runBuildContext,
+}
+
+var (
+	buildContextList       bool
+	buildContextDockerfile string
+	buildContextDir        string
+)
+
+func init() {
+	buildCmd.AddCommand(buildContextCmd)
+
+	buildContextCmd.Flags().BoolVar(&buildContextList, "list", false, "List every file that is sent")
+	buildContextCmd.Flags().StringVar(&buildContextDockerfile, "dockerfile", "", "Dockerfile path relative to the project (default: [build] dockerfile)")
+	buildContextCmd.Flags().StringVar(&buildContextDir, "context", "", "Subdirectory of the project to build (default: [build] context)")
+}
+
+func runBuildContext(cmd *cobra.Command, args []string) {
+	projectPath := "."
+	if len(args) > 0 {
+		projectPath = args[0]
+	}
+
+	absPath, err := utils.ValidateProjectPath(projectPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	config, err := project.LoadConfigIfExists(absPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load yap.toml: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	opts := builder.BuildOptions{
+		Dockerfile: buildContextDockerfile,
+		Context:    buildContextDir,
+	}
+	if config != nil {
+		if !cmd.Flags().Changed("dockerfile") {
+			opts.Dockerfile = config.Build.Dockerfile
+		}
+		if !cmd.Flags().Changed("context") {
+			opts.Context = config.Build.Context
+		}
+		opts.Exclude = config.Build.Exclude
+	}
+
+	contextPath, dockerfilePath, err := builder.ResolveBuildPaths(absPath, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	excludes, err := builder.ResolveContextExcludes(contextPath, dockerfilePath, opts.Exclude)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	files, err := builder.ListBuildContext(contextPath, excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("==> build context: %s", contextPath)))
+	fmt.Println()
+	fmt.Printf("  %s %s\n", labelStyle.Render("dockerfile:"), valueStyle.Render(dockerfilePath))
+	if _, err := os.Stat(dockerfilePath); err != nil {
+		fmt.Println(dimStyle.Render("    not found, the build method is detected when deploying"))
+	}
+	source := excludes.Source
+	if rel, err := filepath.Rel(absPath, source); err == nil && filepath.IsAbs(source) {
+		source = rel
+	}
+	fmt.Printf("  %s %s\n", labelStyle.Render("excludes:"), valueStyle.Render(source))
+	fmt.Printf("  %s %s\n", labelStyle.Render("files:"), valueStyle.Render(fmt.Sprintf("%d (%s)", len(files), utils.FormatBytes(total))))
+	fmt.Println()
+
+	if buildContextList {
+		for _, file := range files {
+			fmt.Printf("    %s %s\n", file.Path, dimStyle.Render(utils.FormatBytes(file.Size)))
+		}
+		return
+	}
+
+	if len(files) == 0 {
+		return
+	}
+
+	largest := append([]builder.ContextFile(nil), files...)
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Size > largest[j].Size })
+	if len(largest) > 5 {
+		largest = largest[:5]
+	}
+
+	fmt.Println(labelStyle.Render("  largest files:"))
+	for _, file := range largest {
+		fmt.Printf("    %s %s\n", file.Path, dimStyle.Render(utils.FormatBytes(file.Size)))
+	}
+	fmt.Println()
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  list every file with: yap build context %s --list", projectPath)))
+}
@@ -121,7 +121,7 @@ runtime = "%s"
 env = "production"
 
 [deployment]
-strategy = "recreate"          # recreate (default), rolling, blue-green
+strategy = "recreate"          # recreate (default), rolling, blue-green, canary
 
 [deploy]
 instances = 1
@@ -172,19 +172,36 @@ runtime = "%s"  # nodejs, python, go, rust, ruby, php, java, docker
 env = "production"  # production, staging, development
 
 [build]
-# Build configuration
-dockerfile = "Dockerfile"  # Optional: custom Dockerfile path
+# Build configuration, paths are relative to this file
+# dockerfile = "docker/api.Dockerfile"  # Optional: custom Dockerfile path, the build fails if it's missing
+# context = "services/api" # Optional: subdirectory to build
 buildpacks = false         # Use buildpacks instead of Dockerfile
-build_args = []
+build_args = []            # "KEY=VALUE" ($VAR and ${VAR} come from the environment) or "KEY" to pass it through
+# target = "production"    # Optional: Dockerfile stage to build
+# exclude = [".git", "node_modules"]  # Optional: left out of the context when there's no .dockerignore, replaces the defaults
+
+[build.labels]
+# "org.opencontainers.image.source" = "https://github.com/org/api"
+
+[build.paketo]
+# Cloud Native Buildpacks, built with the pack cli
+# builder = "paketobuildpacks/builder-jammy-base"
+# buildpacks = []           # Optional: run these instead of the ones the builder detects
+# cache_volume = ""         # Optional: defaults to yap-<app>-buildpacks-cache
 
 [deployment]
 # Deployment strategy configuration
-strategy = "recreate"          # recreate (default), rolling, blue-green
+strategy = "recreate"          # recreate (default), rolling, blue-green, canary
 max_surge = 1                  # Rolling: deploy N instances at a time
 rolling_interval = 5           # Rolling: seconds to wait between instance deployments
-health_timeout = 30            # Rolling/Blue-Green: seconds to wait for health check
+health_timeout = 30            # Seconds each new instance has to pass its readiness check
 auto_confirm = false           # Blue-Green: auto-destroy old version after switch
-confirmation_timeout = 300     # Blue-Green: seconds to wait for manual confirmation
+confirmation_timeout = 300     # Blue-Green: seconds to wait for manual confirmation (0 = forever)
+confirmation_policy = "confirm" # Blue-Green: on timeout, "confirm" if healthy (else revert) or always "revert"
+canary_instances = 1           # Canary: instances receiving the canary share of traffic
+canary_steps = [10, 50, 100]   # Canary: percentage of traffic routed to the canary per step
+canary_interval = 60           # Canary: seconds to observe each step before moving on
+observation_window = 0         # seconds to watch a new release, rolls back automatically if it turns unhealthy
 
 [deploy]
 # Deployment settings
@@ -192,20 +209,52 @@ instances = 1              # Number of instances to run
 memory = "512M"            # Memory allocation per instance
 cpu = 0.5                  # CPU allocation (vCPU)
 port = %d                  # Internal application port
+# image = "ghcr.io/org/api:1.4.2"  # Deploy a prebuilt image instead of building this directory
 auto_scaling = false       # Enable auto-scaling
+stop_signal = "SIGTERM"    # Signal sent to instances when they stop
+stop_timeout = 10          # Seconds to exit after the stop signal before being killed
+drain_period = 5           # Seconds out of the load balancer before stopping (0 = no draining)
+crash_loop_threshold = 5   # Restarts within 5 minutes before an instance is stopped and retried with a backoff
 
 [deploy.health_check]
-# Health check configuration
-path = "/health"
+# Readiness probe, gates new instances during deploys and scaling
+type = "http"              # http, tcp, exec or none
+path = "/health"           # http only
+# port = 9090              # defaults to the app port
+# expected_status = 200    # http: default accepts any 2xx/3xx
+# expected_body = "ok"     # http: response has to contain this
+# command = ["pg_isready"] # exec: runs inside the instance, exit 0 is healthy
 interval = 10              # seconds
 timeout = 5                # seconds
-retries = 3
+retries = 3                # consecutive failures before an instance counts as unhealthy
+
+# [deploy.health_check.liveness]
+# Watches serving instances (observation window, blue-green timeout), defaults to the readiness probe
+# type = "tcp"
 
 [deploy.resources]
 # Resource limits
 memory_limit = "1G"
 cpu_limit = 1.0
 
+# [processes.web]
+# Process types, each runs a command from the same image (a Procfile works too).
+# only web gets a port and load balancer routing, its instances and resources default to [deploy]
+# command = "npm start"      # defaults to the image's own command
+
+# [processes.worker]
+# command = "node worker.js"
+# instances = 1            # scale later with: yap app scale <app> worker=3
+# memory = "256M"          # defaults to [deploy]
+# cpu = 1
+
+# [[cron]]
+# Scheduled jobs, each run is a one-off container from the current image
+# name = "cleanup"
+# schedule = "0 3 * * *"   # minute hour day month weekday, or @hourly, @daily...
+# command = "./cleanup"
+# timeout = 600            # seconds before a run is killed
+
 [network]
 # Network configuration
 ssl = false                # Auto-provision SSL certificate
@@ -230,6 +279,9 @@ LOG_LEVEL = "info"
 
 [hooks]
 # Lifecycle hooks
+# prebuild/postbuild run on this machine from the project directory,
+# predeploy/postdeploy run in a one-off container from the new image on the app's vpc.
+# a failing predeploy aborts the deployment before running instances are touched
 # prebuild = "npm run prebuild"
 # postbuild = "npm run postbuild"
 # predeploy = "npm run migrate"
@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
@@ -68,8 +74,60 @@ func SetVersionInfo(v, bt, gc string) {
 	rootCmd.Version = fmt.Sprintf("%s (built: %s, commit: %s)", version, buildTime, gitCommit)
 }
 
+// cancelled by the first ctrl-c or SIGTERM so a long running command can clean up after itself,
+// a second one kills yap as usual
+func interruptContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// starts yap with args in a session of its own so it outlives this command, its output is appended
+// to ~/.yap/logs/<logName>.log
+func startDetached(logName string, args ...string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	logDir := filepath.Join(homeDir, ".yap", "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(logDir, logName+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	detached := exec.Command(executable, args...)
+	detached.Stdout = logFile
+	detached.Stderr = logFile
+	detached.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := detached.Start(); err != nil {
+		return err
+	}
+
+	return detached.Process.Release()
+}
+
+// log lines of the long-running commands, which mostly end up in ~/.yap/logs
+func logTimestampedf(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
 		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("[error] Error: %v", err)))
 		os.Exit(1)
 	}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aelpxy/yap/internal/app"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var watchdogCmd = &cobra.Command{
+	Use:   "watchdog",
+	Short: "Stop crash looping instances and start them again with a backoff",
+	Long: `Run the watchdog, which follows the restart counts, exit codes and out of memory kills
+of every running application's instances.
+
+An instance that restarts crash_loop_threshold times within 5 minutes is stopped and
+started again after a backoff of 30 seconds, doubling each time it crash loops again up
+to 10 minutes. Once it stays up for 5 minutes it counts as recovered. An application
+whose web instances are all crash looping is marked failed until one of them recovers,
+yap app status shows why. Every action is logged.
+
+Deploys start it in the background, so this is only needed to run it under a service
+manager. Only one watchdog runs at a time, and it exits once no application is running
+unless --keep-running is given.
+
+Examples:
+  yap watchdog
+  yap watchdog --interval 5`,
+	Args: cobra.NoArgs,
+	Run:  runWatchdog,
+}
+
+var (
+	watchdogInterval    int
+	watchdogKeepRunning bool
+)
+
+func init() {
+	rootCmd.AddCommand(watchdogCmd)
+
+	watchdogCmd.Flags().IntVar(&watchdogInterval, "interval", 10, "Seconds between looks at the instances")
+	watchdogCmd.Flags().BoolVar(&watchdogKeepRunning, "keep-running", false, "Keep running when no application is running")
+}
+
+var watchdogLock = app.DaemonLockName("watchdog")
+
+func runWatchdog(cmd *cobra.Command, args []string) {
+	if watchdogInterval < 1 {
+		fmt.Fprintf(os.Stderr, "%s interval must be at least 1 second\n", errorStyle.Render("[error]"))
+		os.Exit(1)
+	}
+
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(watchdogLock, time.Second); err != nil {
+		// another watchdog already follows the instances
+		return
+	}
+	defer lockManager.Unlock(watchdogLock)
+
+	registry, err := app.NewRegistryManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to load registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	if err := registry.Initialize(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize registry: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+
+	dockerClient, err := docker.NewClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed to initialize: %v\n", errorStyle.Render("[error]"), err)
+		os.Exit(1)
+	}
+	defer dockerClient.Close()
+
+	ctx, stop := interruptContext(cmd.Context())
+	defer stop()
+
+	logTimestampedf("watchdog started (pid %d, looking every %ds)", os.Getpid(), watchdogInterval)
+
+	monitor := app.NewCrashMonitor()
+	ticker := time.NewTicker(time.Duration(watchdogInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		applications, err := registry.List()
+		if err != nil {
+			logTimestampedf("failed to read registry: %v", err)
+		}
+
+		watched := 0
+		inUse := make(map[string]bool)
+		for i := range applications {
+			application := &applications[i]
+			if !watchdogWatches(application) {
+				continue
+			}
+			watched++
+			for _, containerID := range app.AllContainerIDs(application) {
+				inUse[containerID] = true
+			}
+
+			actions := monitor.Observe(ctx, dockerClient, application, time.Now())
+			if len(actions) == 0 {
+				continue
+			}
+			if err := applyCrashActions(ctx, dockerClient, registry, monitor, application.Name, actions); err != nil {
+				logTimestampedf("%s: %v", application.Name, err)
+			}
+		}
+		if err == nil {
+			monitor.Forget(inUse)
+		}
+
+		if err == nil && watched == 0 && !watchdogKeepRunning {
+			logTimestampedf("no application is running, watchdog exiting")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			logTimestampedf("watchdog stopping")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// running apps, and the ones the watchdog failed itself so it can bring them back. stopped apps
+// stay stopped, their crashed instances wait for yap app start
+func watchdogWatches(application *models.Application) bool {
+	if application.ImageID == "" {
+		return false
+	}
+	return application.Status == models.AppStatusRunning ||
+		(application.Status == models.AppStatusFailed && application.StatusReason != "")
+}
+
+// carries out the actions under the app lock, the same way the autoscaler scales. a deploy or
+// other command holding the lock wins, the next look sees the app again
+func applyCrashActions(ctx context.Context, dockerClient *docker.Client, registry *app.RegistryManager, monitor *app.CrashMonitor, appName string, actions []app.CrashAction) error {
+	lockManager := app.GetGlobalLockManager()
+	if err := lockManager.TryLock(appName, time.Second); err != nil {
+		return fmt.Errorf("another operation in progress, retrying on the next look")
+	}
+	defer lockManager.Unlock(appName)
+
+	application, err := registry.Get(appName)
+	if err != nil {
+		return err
+	}
+	if !watchdogWatches(application) {
+		return nil
+	}
+
+	for _, message := range monitor.Apply(ctx, dockerClient, application, actions, time.Now()) {
+		logTimestampedf("%s: %s", appName, message)
+	}
+
+	application.UpdatedAt = time.Now()
+	if err := registry.Update(*application); err != nil {
+		return fmt.Errorf("failed to update registry: %w", err)
+	}
+	return nil
+}
+
+// starts the watchdog for a running application, warning instead of failing the command
+func maybeEnsureWatchdog(output io.Writer, application *models.Application) {
+	if application.Status != models.AppStatusRunning {
+		return
+	}
+	if err := startDetached("watchdog", "watchdog"); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to start the watchdog: %v\n", err)
+	}
+}
+
+func describeCrashLoop(application *models.Application) string {
+	return fmt.Sprintf("stop after %d restarts in %s, then back off", app.CrashLoopThreshold(application), app.CrashLoopWindow)
+}
@@ -0,0 +1,254 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+)
+
+type ScalingAction string
+
+const (
+	ScalingActionHold ScalingAction = "hold"
+	ScalingActionUp   ScalingAction = "up"
+	ScalingActionDown ScalingAction = "down"
+)
+
+// resource usage of an app's instances as a percentage of their limits, averaged across them
+type AppUsage struct {
+	Instances int
+	CPU       float64
+	Memory    float64
+}
+
+type ScalingDecision struct {
+	App    string
+	Action ScalingAction
+	From   int
+	To     int
+	Reason string
+}
+
+// decides when to scale apps. usage has to stay over or under an app's targets for its scale up or
+// scale down delay before anything happens, so a short spike or lull doesn't scale it back and forth
+type Autoscaler struct {
+	pressure map[string]scalePressure
+}
+
+type scalePressure struct {
+	action ScalingAction
+	since  time.Time
+}
+
+func NewAutoscaler() *Autoscaler {
+	return &Autoscaler{pressure: make(map[string]scalePressure)}
+}
+
+// whether the autoscaler should look at app at all
+func Autoscalable(app *models.Application) bool {
+	return app.AutoScaleEnabled &&
+		app.Status == models.AppStatusRunning &&
+		app.ImageID != "" &&
+		len(app.ContainerIDs) > 0 &&
+		!HasStandby(app)
+}
+
+func (a *Autoscaler) Evaluate(app *models.Application, usage AppUsage, now time.Time) ScalingDecision {
+	current := usage.Instances
+	decision := ScalingDecision{App: app.Name, Action: ScalingActionHold, From: current, To: current}
+
+	minInstances, maxInstances := scalingBounds(app)
+	targetCPU := app.TargetCPU
+	if targetCPU <= 0 {
+		targetCPU = constants.DefaultTargetCPU
+	}
+
+	summary := fmt.Sprintf("cpu %.0f%% of %d%% target", usage.CPU, targetCPU)
+	if app.TargetMemory > 0 {
+		summary += fmt.Sprintf(", memory %.0f%% of %d%% target", usage.Memory, app.TargetMemory)
+	}
+
+	// bounds changed by a deploy apply right away
+	if current < minInstances {
+		delete(a.pressure, app.Name)
+		decision.Action, decision.To = ScalingActionUp, minInstances
+		decision.Reason = fmt.Sprintf("below min_instances %d", minInstances)
+		return decision
+	}
+	if current > maxInstances {
+		delete(a.pressure, app.Name)
+		decision.Action, decision.To = ScalingActionDown, maxInstances
+		decision.Reason = fmt.Sprintf("above max_instances %d", maxInstances)
+		return decision
+	}
+
+	// how far the busiest resource is from its target, 1 is right on it
+	load := usage.CPU / float64(targetCPU)
+	if app.TargetMemory > 0 {
+		load = math.Max(load, usage.Memory/float64(app.TargetMemory))
+	}
+
+	action, to := ScalingActionHold, current
+	switch {
+	case load > 1 && current < maxInstances:
+		action = ScalingActionUp
+		to = int(math.Ceil(float64(current) * load))
+		if to <= current {
+			to = current + 1
+		}
+		if to > maxInstances {
+			to = maxInstances
+		}
+	// only scale down when one instance fewer would still be under the targets
+	case current > minInstances && load*float64(current)/float64(current-1) < 1:
+		action, to = ScalingActionDown, current-1
+	}
+
+	if action == ScalingActionHold {
+		delete(a.pressure, app.Name)
+		decision.Reason = summary
+		if load > 1 {
+			decision.Reason += fmt.Sprintf(", already at max_instances %d", maxInstances)
+		}
+		return decision
+	}
+
+	pressure, ok := a.pressure[app.Name]
+	if !ok || pressure.action != action {
+		pressure = scalePressure{action: action, since: now}
+		a.pressure[app.Name] = pressure
+	}
+
+	delay := scalingDelay(app, action)
+
+	held := now.Sub(pressure.since)
+	if held < delay {
+		decision.Reason = fmt.Sprintf("%s, scaling %s to %d in %s", summary, action, to, (delay - held).Round(time.Second))
+		return decision
+	}
+
+	decision.Action, decision.To = action, to
+	decision.Reason = fmt.Sprintf("%s for %s", summary, held.Round(time.Second))
+	return decision
+}
+
+// starts the delay over, once a decision was carried out the app needs time to settle
+func (a *Autoscaler) Reset(appName string) {
+	delete(a.pressure, appName)
+}
+
+func scalingBounds(app *models.Application) (int, int) {
+	minInstances := app.MinInstances
+	if minInstances < 1 {
+		minInstances = 1
+	}
+	maxInstances := app.MaxInstances
+	if maxInstances < minInstances {
+		maxInstances = minInstances
+	}
+	return minInstances, maxInstances
+}
+
+// samples every instance at once, each sample takes the runtime about a second
+func SampleUsage(ctx context.Context, dockerClient *docker.Client, app *models.Application) (AppUsage, error) {
+	type sample struct {
+		cpu, memory float64
+		err         error
+	}
+
+	samples := make([]sample, len(app.ContainerIDs))
+	var wg sync.WaitGroup
+	for i, containerID := range app.ContainerIDs {
+		wg.Add(1)
+		go func(i int, containerID string) {
+			defer wg.Done()
+			cpu, memory, err := sampleContainer(ctx, dockerClient, containerID, app.CPU)
+			samples[i] = sample{cpu, memory, err}
+		}(i, containerID)
+	}
+	wg.Wait()
+
+	usage := AppUsage{Instances: len(app.ContainerIDs)}
+	for _, s := range samples {
+		// a missing instance makes the average meaningless, better to skip this round
+		if s.err != nil {
+			return usage, s.err
+		}
+		usage.CPU += s.cpu
+		usage.Memory += s.memory
+	}
+	if usage.Instances > 0 {
+		usage.CPU /= float64(usage.Instances)
+		usage.Memory /= float64(usage.Instances)
+	}
+	return usage, nil
+}
+
+// cpu as a percentage of the instance's cpu limit and memory as a percentage of its memory limit
+func sampleContainer(ctx context.Context, dockerClient *docker.Client, containerID string, cpuLimit float64) (float64, float64, error) {
+	resp, err := dockerClient.GetClient().ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get stats for %s: %w", utils.TruncateID(containerID, 12), err)
+	}
+	defer resp.Body.Close()
+
+	var stats dockerTypes.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode stats for %s: %w", utils.TruncateID(containerID, 12), err)
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuLimit <= 0 {
+		cpuLimit = onlineCPUs
+	}
+
+	cpu := 0.0
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 && cpuLimit > 0 {
+		cpu = cpuDelta / systemDelta * onlineCPUs / cpuLimit * 100
+	}
+
+	// page cache can be reclaimed, docker stats leaves it out the same way
+	used := stats.MemoryStats.Usage
+	inactive, ok := stats.MemoryStats.Stats["inactive_file"]
+	if !ok {
+		inactive = stats.MemoryStats.Stats["total_inactive_file"]
+	}
+	if inactive < used {
+		used -= inactive
+	}
+
+	memory := 0.0
+	if stats.MemoryStats.Limit > 0 {
+		memory = float64(used) / float64(stats.MemoryStats.Limit) * 100
+	}
+
+	return cpu, memory, nil
+}
+
+// how long the load has to stay over or under the targets before action is taken
+func scalingDelay(app *models.Application, action ScalingAction) time.Duration {
+	if action == ScalingActionDown {
+		if app.ScaleDownDelay <= 0 {
+			return constants.DefaultScaleDownDelay * time.Second
+		}
+		return time.Duration(app.ScaleDownDelay) * time.Second
+	}
+	if app.ScaleUpDelay <= 0 {
+		return constants.DefaultScaleUpDelay * time.Second
+	}
+	return time.Duration(app.ScaleUpDelay) * time.Second
+}
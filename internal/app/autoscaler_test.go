@@ -0,0 +1,91 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+func TestAutoscalerEvaluate(t *testing.T) {
+	app := &models.Application{Name: "myapp", MinInstances: 1, MaxInstances: 4, TargetCPU: 50, ScaleUpDelay: 60, ScaleDownDelay: 300}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		usage      AppUsage
+		held       time.Duration
+		wantAction ScalingAction
+		wantTo     int
+	}{
+		{
+			name:       "under pressure for less than the delay holds",
+			usage:      AppUsage{Instances: 2, CPU: 80},
+			held:       30 * time.Second,
+			wantAction: ScalingActionHold,
+			wantTo:     2,
+		},
+		{
+			name:       "scales up in proportion to the load",
+			usage:      AppUsage{Instances: 2, CPU: 80},
+			held:       time.Minute,
+			wantAction: ScalingActionUp,
+			wantTo:     4,
+		},
+		{
+			name:       "scale up stops at max_instances",
+			usage:      AppUsage{Instances: 3, CPU: 100},
+			held:       time.Minute,
+			wantAction: ScalingActionUp,
+			wantTo:     4,
+		},
+		{
+			name:       "scale down waits for its own delay",
+			usage:      AppUsage{Instances: 3, CPU: 10},
+			held:       time.Minute,
+			wantAction: ScalingActionHold,
+			wantTo:     3,
+		},
+		{
+			name:       "scales down one instance at a time",
+			usage:      AppUsage{Instances: 3, CPU: 10},
+			held:       5 * time.Minute,
+			wantAction: ScalingActionDown,
+			wantTo:     2,
+		},
+		{
+			name:       "doesn't scale down into the target",
+			usage:      AppUsage{Instances: 2, CPU: 40},
+			held:       5 * time.Minute,
+			wantAction: ScalingActionHold,
+			wantTo:     2,
+		},
+		{
+			name:       "below min_instances scales up at once",
+			usage:      AppUsage{Instances: 0},
+			wantAction: ScalingActionUp,
+			wantTo:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAutoscaler()
+			a.Evaluate(app, tt.usage, now)
+			decision := a.Evaluate(app, tt.usage, now.Add(tt.held))
+			if decision.Action != tt.wantAction || decision.To != tt.wantTo {
+				t.Errorf("Evaluate() = %s to %d (%s), want %s to %d", decision.Action, decision.To, decision.Reason, tt.wantAction, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestScalingDelayDefaults(t *testing.T) {
+	app := &models.Application{}
+	if got := scalingDelay(app, ScalingActionUp); got != time.Minute {
+		t.Errorf("scale up delay = %v, want 1m", got)
+	}
+	if got := scalingDelay(app, ScalingActionDown); got != 5*time.Minute {
+		t.Errorf("scale down delay = %v, want 5m", got)
+	}
+}
@@ -0,0 +1,377 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/router"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+const (
+	// once the confirmation timeout passes: keep the new color if it's healthy, otherwise go back
+	ConfirmationPolicyConfirm = "confirm"
+	// once the confirmation timeout passes: always go back unless someone confirmed in time
+	ConfirmationPolicyRevert = "revert"
+)
+
+func GetEnvironment(app *models.Application, color models.DeploymentColor) *models.Environment {
+	switch color {
+	case models.DeploymentColorBlue:
+		return app.DeploymentState.Blue
+	case models.DeploymentColorGreen:
+		return app.DeploymentState.Green
+	}
+	return nil
+}
+
+func HasStandby(app *models.Application) bool {
+	standby := app.DeploymentState.Standby
+	if standby == "" || standby == models.DeploymentColorDefault {
+		return false
+	}
+	env := GetEnvironment(app, standby)
+	return env != nil && len(env.ContainerIDs) > 0
+}
+
+// recreates a blue-green instance with a new label set, traefik routing is only attached when
+// traefikLabels is non-nil. labels can't be changed on a running container, so the returned ID is new
+func RelabelInstance(ctx context.Context, dockerClient *docker.Client, app *models.Application, containerID string, instanceNum int, color models.DeploymentColor, traefikLabels map[string]string) (string, error) {
+	inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	labels := map[string]string{
+		"yap.managed":      "true",
+		"yap.type":         "app",
+		"yap.app.name":     app.Name,
+		"yap.app.id":       app.ID,
+		"yap.vpc":          app.VPC,
+		"yap.app.instance": fmt.Sprintf("%d", instanceNum),
+		"yap.app.color":    string(color),
+	}
+
+	for k, v := range traefikLabels {
+		labels[k] = v
+	}
+
+	if err := StopInstance(ctx, dockerClient, app, containerID); err != nil {
+		return "", fmt.Errorf("failed to stop container: %w", err)
+	}
+
+	if err := dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{}); err != nil {
+		return "", fmt.Errorf("failed to remove container: %w", err)
+	}
+
+	containerName := fmt.Sprintf("yap-app-%s-%s-%d", app.Name, color, instanceNum)
+
+	containerConfig := &dockerTypes.Config{
+		Image:  inspect.Config.Image,
+		Labels: labels,
+		Env:    inspect.Config.Env,
+	}
+	applyStopConfig(containerConfig, app)
+	applyProcess(containerConfig, app)
+
+	hostConfig := inspect.HostConfig
+
+	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", app.VPC)
+	networkConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			vpcNetworkName: {},
+		},
+	}
+
+	resp, err := dockerClient.GetClient().ContainerCreate(
+		ctx,
+		containerConfig,
+		hostConfig,
+		networkConfig,
+		nil,
+		containerName,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to recreate container: %w", err)
+	}
+
+	if err := dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
+		return resp.ID, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	return resp.ID, nil
+}
+
+// moves traffic from the active color to the standby color, both environments stay alive. a
+// standby on color routing is switched by rewriting the load balancer's routing alone, one from
+// before color routing existed has its instances relabeled
+func SwitchBlueGreen(ctx context.Context, dockerClient *docker.Client, app *models.Application, events EventSink) error {
+	if !HasStandby(app) {
+		return fmt.Errorf("no standby environment to switch to")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	activeColor := app.DeploymentState.Active
+	standbyColor := app.DeploymentState.Standby
+	standbyEnv := GetEnvironment(app, standbyColor)
+
+	colorRouted, err := environmentColorRouted(ctx, dockerClient, app, standbyEnv, standbyColor)
+	if err != nil {
+		return err
+	}
+
+	if colorRouted {
+		if err := router.NewTraefikManager(dockerClient).SetColorRouting(app, standbyColor); err != nil {
+			return fmt.Errorf("failed to route traffic to %s: %w", standbyColor, err)
+		}
+	} else if err := relabelSwitch(ctx, dockerClient, app, events); err != nil {
+		return err
+	}
+
+	app.DeploymentState.Active = standbyColor
+	app.DeploymentState.Standby = activeColor
+	app.ContainerIDs = standbyEnv.ContainerIDs
+	app.ImageID = standbyEnv.ImageID
+
+	return nil
+}
+
+// whether env's instances registered their color's service, instances of an environment are
+// always created together so the first one speaks for all of them
+func environmentColorRouted(ctx context.Context, dockerClient *docker.Client, app *models.Application, env *models.Environment, color models.DeploymentColor) (bool, error) {
+	if env == nil || len(env.ContainerIDs) == 0 {
+		return false, nil
+	}
+
+	inspect, err := dockerClient.GetClient().ContainerInspect(ctx, env.ContainerIDs[0])
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s environment: %w", color, err)
+	}
+	return router.HasColorLabels(inspect.Config.Labels, app.Name, color), nil
+}
+
+// switches environments deployed before color routing by recreating the standby instances with the
+// app's routing labels and the active ones without
+func relabelSwitch(ctx context.Context, dockerClient *docker.Client, app *models.Application, events EventSink) error {
+	activeColor := app.DeploymentState.Active
+	standbyColor := app.DeploymentState.Standby
+	activeEnv := GetEnvironment(app, activeColor)
+	standbyEnv := GetEnvironment(app, standbyColor)
+
+	traefik := router.NewTraefikManager(dockerClient)
+	traefikLabels := traefik.GenerateLabelsForApp(app)
+
+	healthTimeout := time.Duration(app.DeploymentConfig.HealthTimeout) * time.Second
+
+	// relabeling recreates the instances, each one has to pass readiness again before the active
+	// color gives up its traffic. the active color keeps serving until then
+	total := len(standbyEnv.ContainerIDs)
+	for i, containerID := range standbyEnv.ContainerIDs {
+		newID, err := RelabelInstance(ctx, dockerClient, app, containerID, i+1, standbyColor, traefikLabels)
+		if newID != "" {
+			standbyEnv.ContainerIDs[i] = newID
+		}
+		if err != nil {
+			unrouteStandby(ctx, dockerClient, app, standbyEnv, standbyColor, i+1, events)
+			return fmt.Errorf("failed to route traffic to %s-%d: %w", standbyColor, i+1, err)
+		}
+
+		emitEvent(events, app, DeploymentEvent{Type: EventHealthWaiting, Message: fmt.Sprintf("waiting for %s-%d to become ready...", standbyColor, i+1), Instance: i + 1, Total: total, ContainerID: newID, Color: string(standbyColor)})
+		if err := WaitForReady(ctx, dockerClient, app, newID, healthTimeout); err != nil {
+			emitEvent(events, app, DeploymentEvent{Type: EventHealthFailed, Message: fmt.Sprintf("%s-%d failed readiness: %v", standbyColor, i+1, err), Instance: i + 1, Total: total, ContainerID: newID, Color: string(standbyColor), Error: err.Error()})
+			unrouteStandby(ctx, dockerClient, app, standbyEnv, standbyColor, i+1, events)
+			return fmt.Errorf("%s-%d failed readiness, traffic stays on %s: %w", standbyColor, i+1, activeColor, err)
+		}
+		emitEvent(events, app, DeploymentEvent{Type: EventHealthPassed, Message: fmt.Sprintf("%s-%d ready", standbyColor, i+1), Instance: i + 1, Total: total, ContainerID: newID, Color: string(standbyColor)})
+	}
+
+	// a color routed active environment would keep the traffic over the docker routes
+	if err := traefik.ClearColorRouting(app.Name); err != nil {
+		unrouteStandby(ctx, dockerClient, app, standbyEnv, standbyColor, total, events)
+		return err
+	}
+
+	if activeEnv != nil {
+		restore, err := DrainTraffic(ctx, dockerClient, app, standbyEnv.ContainerIDs)
+		if err != nil {
+			emitEvent(events, app, DeploymentEvent{Type: EventWarning, Message: fmt.Sprintf("failed to drain connections: %v", err)})
+		}
+		defer restore()
+
+		for i, containerID := range activeEnv.ContainerIDs {
+			newID, err := RelabelInstance(ctx, dockerClient, app, containerID, i+1, activeColor, nil)
+			if newID != "" {
+				activeEnv.ContainerIDs[i] = newID
+			}
+			if err != nil {
+				return fmt.Errorf("failed to remove traffic from %s-%d: %w", activeColor, i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// takes the first count standby instances out of routing again after a switch failed half way. it
+// runs even when the switch was cancelled, routed standby instances would keep taking traffic
+func unrouteStandby(ctx context.Context, dockerClient *docker.Client, app *models.Application, standbyEnv *models.Environment, standbyColor models.DeploymentColor, count int, events EventSink) {
+	ctx = context.WithoutCancel(ctx)
+	for i := 0; i < count && i < len(standbyEnv.ContainerIDs); i++ {
+		newID, err := RelabelInstance(ctx, dockerClient, app, standbyEnv.ContainerIDs[i], i+1, standbyColor, nil)
+		if newID != "" {
+			standbyEnv.ContainerIDs[i] = newID
+		}
+		if err != nil {
+			emitEvent(events, app, DeploymentEvent{Type: EventWarning, Message: fmt.Sprintf("failed to take %s-%d out of routing: %v", standbyColor, i+1, err)})
+		}
+	}
+}
+
+// the release an environment runs, matched on the image reference it was started from
+func EnvironmentRelease(app *models.Application, env *models.Environment) (*models.DeploymentRecord, int) {
+	if env == nil || env.ImageID == "" {
+		return nil, 0
+	}
+
+	for i := len(app.DeploymentHistory) - 1; i >= 0; i-- {
+		record := &app.DeploymentHistory[i]
+		if env.ImageID == record.ImageTag || env.ImageID == record.ImageID {
+			return record, ReleaseVersion(app, i)
+		}
+	}
+
+	return nil, 0
+}
+
+// true when the standby color still runs the given release, so traffic can move back without a deploy
+func StandbyRunsRelease(app *models.Application, record *models.DeploymentRecord) bool {
+	if app.DeploymentStrategy != models.DeploymentStrategyBlueGreen || !HasStandby(app) {
+		return false
+	}
+
+	env := GetEnvironment(app, app.DeploymentState.Standby)
+	return env.ImageID == record.ImageTag || env.ImageID == record.ImageID
+}
+
+// switches traffic to the standby color and records the switch as a new release, the release that was
+// active is marked with previousStatus. also returns the version the standby color was running
+func SwitchToStandby(ctx context.Context, dockerClient *docker.Client, app *models.Application, previousStatus string, events EventSink) (models.DeploymentRecord, int, error) {
+	if !HasStandby(app) {
+		return models.DeploymentRecord{}, 0, fmt.Errorf("no standby environment to switch to")
+	}
+
+	standbyEnv := GetEnvironment(app, app.DeploymentState.Standby)
+	standbyRelease, standbyVersion := EnvironmentRelease(app, standbyEnv)
+	standbyImage := standbyEnv.ImageID
+
+	if err := SwitchBlueGreen(ctx, dockerClient, app, events); err != nil {
+		return models.DeploymentRecord{}, 0, err
+	}
+
+	var record models.DeploymentRecord
+	if standbyRelease != nil {
+		record = NewRedeployRecord(app, standbyRelease)
+	} else {
+		record = NewDeploymentRecord(NextReleaseVersion(app), standbyImage, "", app.DeploymentStrategy)
+	}
+	RecordRelease(app, record, previousStatus)
+
+	return record, standbyVersion, nil
+}
+
+// removes the standby color's containers and clears it from the deployment state
+func DestroyStandby(ctx context.Context, dockerClient *docker.Client, app *models.Application) error {
+	if !HasStandby(app) {
+		return nil
+	}
+
+	standby := app.DeploymentState.Standby
+	env := GetEnvironment(app, standby)
+
+	for _, containerID := range env.ContainerIDs {
+		StopInstance(ctx, dockerClient, app, containerID)
+
+		if err := dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{
+			Force: true,
+		}); err != nil {
+			return fmt.Errorf("failed to remove %s container: %w", standby, err)
+		}
+	}
+
+	if standby == models.DeploymentColorBlue {
+		app.DeploymentState.Blue = nil
+	} else {
+		app.DeploymentState.Green = nil
+	}
+	app.DeploymentState.Standby = ""
+
+	return nil
+}
+
+// confirms or reverts a blue-green release whose confirmation timeout ran out, depending on the
+// app's confirmation policy and the health of the active color. the decision is written to the
+// active release in the deployment history, a revert also records the release traffic went back to
+func EnforceConfirmationTimeout(ctx context.Context, dockerClient *docker.Client, app *models.Application) (bool, string, error) {
+	activeEnv := GetEnvironment(app, app.DeploymentState.Active)
+	if activeEnv == nil || !HasStandby(app) {
+		return false, "", fmt.Errorf("no pending blue-green deployment")
+	}
+
+	policy := app.DeploymentConfig.ConfirmationPolicy
+	if policy == "" {
+		policy = ConfirmationPolicyConfirm
+	}
+
+	elapsed := time.Since(activeEnv.DeployedAt).Round(time.Second)
+	healthErr := CheckReleaseHealth(ctx, dockerClient, app, activeEnv.ContainerIDs)
+
+	var active *models.DeploymentRecord
+	activeVersion := 0
+	for i := range app.DeploymentHistory {
+		if app.DeploymentHistory[i].Status == DeploymentStatusActive {
+			active = &app.DeploymentHistory[i]
+			activeVersion = ReleaseVersion(app, i)
+		}
+	}
+
+	if policy == ConfirmationPolicyConfirm && healthErr == nil {
+		if err := DestroyStandby(ctx, dockerClient, app); err != nil {
+			return false, "", err
+		}
+
+		decision := fmt.Sprintf("auto-confirmed after %s: %s environment healthy", elapsed, app.DeploymentState.Active)
+		if active != nil {
+			active.Decision = decision
+		}
+		return false, decision, nil
+	}
+
+	reason := fmt.Sprintf("confirmation_policy is %s", policy)
+	if healthErr != nil {
+		reason = healthErr.Error()
+	}
+	decision := fmt.Sprintf("auto-reverted after %s: %s", elapsed, reason)
+
+	if active != nil {
+		active.Decision = decision
+		if healthErr != nil {
+			active.FailureReason = healthErr.Error()
+		}
+	}
+
+	if _, _, err := SwitchToStandby(ctx, dockerClient, app, DeploymentStatusRolledBack, nil); err != nil {
+		return false, "", err
+	}
+	if err := DestroyStandby(ctx, dockerClient, app); err != nil {
+		return false, "", err
+	}
+
+	app.DeploymentHistory[len(app.DeploymentHistory)-1].Decision = fmt.Sprintf("restored by confirmation timeout of release v%d", activeVersion)
+	return true, decision, nil
+}
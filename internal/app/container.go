@@ -40,10 +40,7 @@ func RecreateContainer(
 		return "", fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	timeout := 10
-	if err := dockerClient.GetClient().ContainerStop(ctx, oldContainerID, dockerTypes.StopOptions{
-		Timeout: &timeout,
-	}); err != nil {
+	if err := StopInstance(ctx, dockerClient, app, oldContainerID); err != nil {
 		return "", fmt.Errorf("failed to stop container: %w", err)
 	}
 
@@ -60,8 +57,17 @@ func RecreateContainer(
 	InjectMetadata(envVars, app.ID, instanceNum, "local")
 	envArray := BuildEnvArray(envVars)
 
-	traefik := router.NewTraefikManager(dockerClient)
-	traefikLabels := traefik.GenerateLabelsForApp(app)
+	// a blue-green instance on color routing stays on its color's service
+	color := models.DeploymentColor(containerInfo.Config.Labels["yap.app.color"])
+	var traefikLabels map[string]string
+	if app.Process == "" {
+		traefik := router.NewTraefikManager(dockerClient)
+		if color != "" && router.HasColorLabels(containerInfo.Config.Labels, app.Name, color) {
+			traefikLabels = traefik.GenerateColorLabels(app, color)
+		} else {
+			traefikLabels = traefik.GenerateLabelsForApp(app)
+		}
+	}
 
 	labels := map[string]string{
 		"yap.managed":      "true",
@@ -71,6 +77,9 @@ func RecreateContainer(
 		"yap.vpc":          app.VPC,
 		"yap.app.instance": fmt.Sprintf("%d", instanceNum),
 	}
+	if color != "" {
+		labels["yap.app.color"] = string(color)
+	}
 	for k, v := range traefikLabels {
 		labels[k] = v
 	}
@@ -80,6 +89,8 @@ func RecreateContainer(
 		Labels: labels,
 		Env:    envArray, // NEW env vars here!
 	}
+	applyStopConfig(containerConfig, app)
+	applyProcess(containerConfig, app)
 
 	mounts := prepareVolumeMounts(app.Name, app.Volumes)
 
@@ -0,0 +1,345 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/containerd/errdefs"
+	dockerTypes "github.com/docker/docker/api/types/container"
+)
+
+const (
+	// restarts older than this don't count towards the threshold, an instance that crashes once a
+	// day isn't crash looping
+	CrashLoopWindow = 5 * time.Minute
+
+	crashBackoffBase = 30 * time.Second
+	crashBackoffMax  = 10 * time.Minute
+
+	// a crashed instance that stays up this long after it was started again is healthy
+	crashRecoveryTime = 5 * time.Minute
+)
+
+// what an instance's runtime reports about its restarts and how it last exited
+type InstanceState struct {
+	Status    string
+	Running   bool
+	Restarts  int
+	ExitCode  int
+	OOMKilled bool
+}
+
+func InspectInstance(ctx context.Context, dockerClient *docker.Client, containerID string) (InstanceState, error) {
+	inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return InstanceState{}, err
+	}
+	return InstanceState{
+		Status:    inspect.State.Status,
+		Running:   inspect.State.Running,
+		Restarts:  inspect.RestartCount,
+		ExitCode:  inspect.State.ExitCode,
+		OOMKilled: inspect.State.OOMKilled,
+	}, nil
+}
+
+func DescribeExit(exitCode int, oomKilled bool) string {
+	if oomKilled {
+		return fmt.Sprintf("exit code %d, out of memory", exitCode)
+	}
+	return fmt.Sprintf("exit code %d", exitCode)
+}
+
+func CrashLoopThreshold(app *models.Application) int {
+	if app.CrashLoopThreshold > 0 {
+		return app.CrashLoopThreshold
+	}
+	return constants.DefaultCrashLoopThreshold
+}
+
+// how long an instance waits before it's started again after crash looping attempts times
+func CrashBackoff(attempts int) time.Duration {
+	backoff := crashBackoffBase
+	for i := 1; i < attempts && backoff < crashBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > crashBackoffMax {
+		backoff = crashBackoffMax
+	}
+	return backoff
+}
+
+func FindCrashedInstance(app *models.Application, containerID string) *models.CrashedInstance {
+	for i := range app.CrashedInstances {
+		if app.CrashedInstances[i].ContainerID == containerID {
+			return &app.CrashedInstances[i]
+		}
+	}
+	return nil
+}
+
+// forgets every crashed instance, for commands that replace or start the instances themselves
+func ClearCrashLoop(app *models.Application) {
+	app.CrashedInstances = nil
+	app.StatusReason = ""
+}
+
+type CrashActionType string
+
+const (
+	CrashActionStop    CrashActionType = "stop"    // crash looping, stop it for its backoff
+	CrashActionRetry   CrashActionType = "retry"   // backoff ran out, start it again
+	CrashActionRecover CrashActionType = "recover" // stayed up or went away, forget it
+)
+
+type CrashAction struct {
+	Type        CrashActionType
+	ContainerID string
+	Process     string
+	State       InstanceState
+	Restarts    int
+	Reason      string
+}
+
+// watches instances for crash loops. restart counts are only known by polling, so it remembers
+// when it saw each instance's count go up
+type CrashMonitor struct {
+	counts   map[string]int
+	restarts map[string][]time.Time
+}
+
+func NewCrashMonitor() *CrashMonitor {
+	return &CrashMonitor{
+		counts:   make(map[string]int),
+		restarts: make(map[string][]time.Time),
+	}
+}
+
+// inspects every instance of app, web and the other processes, and returns what has to happen to
+// them. nothing is changed yet, Apply does that under the app lock
+func (m *CrashMonitor) Observe(ctx context.Context, dockerClient *docker.Client, app *models.Application, now time.Time) []CrashAction {
+	var actions []CrashAction
+	threshold := CrashLoopThreshold(app)
+	seen := make(map[string]bool)
+
+	check := func(process, containerID string) {
+		seen[containerID] = true
+		crashed := FindCrashedInstance(app, containerID)
+
+		inspectCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+		state, err := InspectInstance(inspectCtx, dockerClient, containerID)
+		cancel()
+		if err != nil {
+			if errdefs.IsNotFound(err) && crashed != nil {
+				actions = append(actions, CrashAction{Type: CrashActionRecover, ContainerID: containerID, Process: process, Reason: "instance is gone"})
+			}
+			return
+		}
+
+		restarts := m.observeRestarts(containerID, state.Restarts, now)
+
+		switch {
+		case restarts >= threshold && (crashed == nil || !crashed.Stopped):
+			actions = append(actions, CrashAction{
+				Type:        CrashActionStop,
+				ContainerID: containerID,
+				Process:     process,
+				State:       state,
+				Restarts:    restarts,
+				Reason:      fmt.Sprintf("restarted %d times in %s (%s)", restarts, CrashLoopWindow, DescribeExit(state.ExitCode, state.OOMKilled)),
+			})
+		case crashed == nil:
+		case crashed.Stopped && state.Running:
+			// started by hand before its backoff ran out, it's on its retry now
+			actions = append(actions, CrashAction{Type: CrashActionRetry, ContainerID: containerID, Process: process, State: state, Reason: "started outside the watchdog"})
+		case crashed.Stopped && !now.Before(crashed.RetryAt):
+			actions = append(actions, CrashAction{Type: CrashActionRetry, ContainerID: containerID, Process: process, State: state, Reason: fmt.Sprintf("backoff of %s ran out", CrashBackoff(crashed.Attempts))})
+		case !crashed.Stopped && state.Running && now.Sub(crashed.RetriedAt) >= crashRecoveryTime:
+			actions = append(actions, CrashAction{Type: CrashActionRecover, ContainerID: containerID, Process: process, State: state, Reason: fmt.Sprintf("up for %s since it was started again", crashRecoveryTime)})
+		}
+	}
+
+	for _, containerID := range app.ContainerIDs {
+		check(models.ProcessWeb, containerID)
+	}
+	for _, process := range app.Processes {
+		for _, containerID := range process.ContainerIDs {
+			check(process.Name, containerID)
+		}
+	}
+
+	// records of instances a deploy or scale down replaced since
+	for _, crashed := range app.CrashedInstances {
+		if !seen[crashed.ContainerID] {
+			actions = append(actions, CrashAction{Type: CrashActionRecover, ContainerID: crashed.ContainerID, Process: crashed.Process, Reason: "instance was replaced"})
+		}
+	}
+
+	return actions
+}
+
+// notes the restarts since the last look and returns how many fell within the window
+func (m *CrashMonitor) observeRestarts(containerID string, count int, now time.Time) int {
+	last, known := m.counts[containerID]
+	m.counts[containerID] = count
+
+	// the first look only sets the baseline, a start by hand resets the count
+	if known && count > last {
+		for i := 0; i < count-last; i++ {
+			m.restarts[containerID] = append(m.restarts[containerID], now)
+		}
+	}
+
+	recent := m.restarts[containerID][:0]
+	for _, at := range m.restarts[containerID] {
+		if now.Sub(at) < CrashLoopWindow {
+			recent = append(recent, at)
+		}
+	}
+	m.restarts[containerID] = recent
+	return len(recent)
+}
+
+// forgets what it saw of instances that aren't in use anymore
+func (m *CrashMonitor) Forget(containerIDs map[string]bool) {
+	for containerID := range m.counts {
+		if !containerIDs[containerID] {
+			delete(m.counts, containerID)
+			delete(m.restarts, containerID)
+		}
+	}
+}
+
+// carries out actions on app's instances and records them on app, then updates its status: failed
+// once every web instance is crash looping, running again once one of them recovered. returns a
+// line per action for the log
+func (m *CrashMonitor) Apply(ctx context.Context, dockerClient *docker.Client, app *models.Application, actions []CrashAction, now time.Time) []string {
+	var messages []string
+
+	// a deploy or scale may have replaced instances since they were observed
+	current := make(map[string]bool)
+	for _, containerID := range AllContainerIDs(app) {
+		current[containerID] = true
+	}
+
+	for _, action := range actions {
+		if action.Type != CrashActionRecover && !current[action.ContainerID] {
+			continue
+		}
+
+		name := fmt.Sprintf("%s instance %s", action.Process, utils.TruncateID(action.ContainerID, 12))
+		crashed := FindCrashedInstance(app, action.ContainerID)
+
+		switch action.Type {
+		case CrashActionStop:
+			if crashed == nil {
+				app.CrashedInstances = append(app.CrashedInstances, models.CrashedInstance{ContainerID: action.ContainerID, Process: action.Process})
+				crashed = &app.CrashedInstances[len(app.CrashedInstances)-1]
+			}
+
+			// stopped by hand, the restart policy leaves it alone until it's started again
+			if err := StopInstance(ctx, dockerClient, app, action.ContainerID); err != nil && !errdefs.IsNotFound(err) {
+				messages = append(messages, fmt.Sprintf("%s crash looping, failed to stop it: %v", name, err))
+				continue
+			}
+
+			crashed.Attempts++
+			crashed.Restarts = action.Restarts
+			crashed.ExitCode = action.State.ExitCode
+			crashed.OOMKilled = action.State.OOMKilled
+			crashed.Reason = action.Reason
+			crashed.Stopped = true
+			crashed.StoppedAt = now
+			crashed.RetryAt = now.Add(CrashBackoff(crashed.Attempts))
+			m.restarts[action.ContainerID] = nil
+
+			messages = append(messages, fmt.Sprintf("%s crash looping, %s. stopped, starting it again in %s", name, action.Reason, CrashBackoff(crashed.Attempts)))
+
+		case CrashActionRetry:
+			if crashed == nil {
+				continue
+			}
+			if !action.State.Running {
+				startCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+				err := dockerClient.GetClient().ContainerStart(startCtx, action.ContainerID, dockerTypes.StartOptions{})
+				cancel()
+				if err != nil {
+					messages = append(messages, fmt.Sprintf("%s failed to start again: %v", name, err))
+					continue
+				}
+			}
+			crashed.Stopped = false
+			crashed.RetriedAt = now
+			messages = append(messages, fmt.Sprintf("%s started again, %s (stopped %d time(s) so far)", name, action.Reason, crashed.Attempts))
+
+		case CrashActionRecover:
+			if crashed == nil {
+				continue
+			}
+			remaining := app.CrashedInstances[:0]
+			for _, c := range app.CrashedInstances {
+				if c.ContainerID != action.ContainerID {
+					remaining = append(remaining, c)
+				}
+			}
+			app.CrashedInstances = remaining
+			if len(app.CrashedInstances) == 0 {
+				app.CrashedInstances = nil
+			}
+			messages = append(messages, fmt.Sprintf("%s recovered, %s", name, action.Reason))
+		}
+	}
+
+	if message := updateCrashStatus(app); message != "" {
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// only a failure the watchdog set itself is lifted again, a reason is what tells them apart
+func updateCrashStatus(app *models.Application) string {
+	failedByWatchdog := app.Status == models.AppStatusFailed && app.StatusReason != ""
+
+	crashedWeb := 0
+	var latest *models.CrashedInstance
+	for i := range app.CrashedInstances {
+		crashed := &app.CrashedInstances[i]
+		if crashed.Process != models.ProcessWeb {
+			continue
+		}
+		crashedWeb++
+		if latest == nil || crashed.StoppedAt.After(latest.StoppedAt) {
+			latest = crashed
+		}
+	}
+
+	switch {
+	case crashedWeb > 0 && crashedWeb >= len(app.ContainerIDs):
+		app.StatusReason = fmt.Sprintf("every instance is crash looping, last one %s", latest.Reason)
+		if app.Status == models.AppStatusRunning {
+			app.Status = models.AppStatusFailed
+			return fmt.Sprintf("application failed: %s", app.StatusReason)
+		}
+	case crashedWeb > 0:
+		app.StatusReason = fmt.Sprintf("%d of %d instances crash looping, last one %s", crashedWeb, len(app.ContainerIDs), latest.Reason)
+		if failedByWatchdog {
+			app.Status = models.AppStatusRunning
+			return fmt.Sprintf("application running again, %s", app.StatusReason)
+		}
+	case len(app.CrashedInstances) > 0:
+		app.StatusReason = fmt.Sprintf("%d process instance(s) crash looping", len(app.CrashedInstances))
+	case app.StatusReason != "":
+		app.StatusReason = ""
+		if failedByWatchdog {
+			app.Status = models.AppStatusRunning
+			return "application running again, every instance recovered"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,217 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	"github.com/lucsky/cuid"
+)
+
+const (
+	CronSourceConfig = "yap.toml"
+	CronSourceCLI    = "cli"
+
+	CronTriggerSchedule = "schedule"
+	CronTriggerManual   = "manual"
+
+	CronRunSucceeded = "succeeded"
+	CronRunFailed    = "failed"
+	CronRunSkipped   = "skipped"
+
+	// runs kept per job, the oldest ones and their logs go first
+	maxCronHistory = 100
+)
+
+// ~/.yap/cron/<app>, holding a directory per job with its history and run logs
+func CronDir(appName string) string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, ".yap", "cron", appName)
+}
+
+func cronJobDir(appName, jobName string) string {
+	return filepath.Join(CronDir(appName), jobName)
+}
+
+func FindCronJob(app *models.Application, name string) *models.CronJob {
+	for i := range app.CronJobs {
+		if app.CronJobs[i].Name == name {
+			return &app.CronJobs[i]
+		}
+	}
+	return nil
+}
+
+// runs job once in a one-off container from the app's current image and records the run. a run
+// that finds the previous one still going is recorded as skipped instead of overlapping it. the
+// output goes to the run's log file and to output when it isn't nil
+func RunCronJob(ctx context.Context, dockerClient *docker.Client, app *models.Application, job models.CronJob, trigger string, output io.Writer) (*models.CronRun, error) {
+	run := &models.CronRun{
+		ID:        fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), cuid.Slug()),
+		Job:       job.Name,
+		Trigger:   trigger,
+		Image:     app.ImageID,
+		StartedAt: time.Now(),
+	}
+
+	lockManager := GetGlobalLockManager()
+	lockName := fmt.Sprintf("%s.cron.%s", app.Name, job.Name)
+	if err := lockManager.TryLock(lockName, time.Second); err != nil {
+		run.Status = CronRunSkipped
+		run.ExitCode = -1
+		run.Error = "previous run still in progress"
+		run.FinishedAt = time.Now()
+		return run, recordCronRun(app.Name, run)
+	}
+	defer lockManager.Unlock(lockName)
+
+	if err := os.MkdirAll(cronJobDir(app.Name, job.Name), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cron directory: %w", err)
+	}
+
+	run.LogPath = filepath.Join(cronJobDir(app.Name, job.Name), run.ID+".log")
+	logFile, err := os.Create(run.LogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run log: %w", err)
+	}
+	defer logFile.Close()
+
+	var writer io.Writer = logFile
+	if output != nil {
+		writer = io.MultiWriter(logFile, output)
+	}
+
+	runCtx := ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, time.Duration(job.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	if app.ImageID == "" {
+		run.ExitCode = -1
+		err = fmt.Errorf("application has no image, deploy it first")
+	} else {
+		run.ExitCode, err = RunTask(runCtx, dockerClient, app, app.ImageID, "cron-"+job.Name, job.Command, writer, writer)
+	}
+	run.FinishedAt = time.Now()
+
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+		run.Status = CronRunFailed
+		run.Error = fmt.Sprintf("timed out after %ds", job.Timeout)
+	case err != nil:
+		run.Status = CronRunFailed
+		run.Error = err.Error()
+	case run.ExitCode != 0:
+		run.Status = CronRunFailed
+		run.Error = fmt.Sprintf("exited with code %d", run.ExitCode)
+	default:
+		run.Status = CronRunSucceeded
+	}
+
+	if run.Error != "" {
+		fmt.Fprintf(logFile, "\n[yap] %s\n", run.Error)
+	}
+
+	return run, recordCronRun(app.Name, run)
+}
+
+// a job's runs, oldest first
+func CronHistory(appName, jobName string) ([]models.CronRun, error) {
+	data, err := os.ReadFile(filepath.Join(cronJobDir(appName, jobName), "history.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cron history: %w", err)
+	}
+
+	var runs []models.CronRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse cron history: %w", err)
+	}
+	return runs, nil
+}
+
+func recordCronRun(appName string, run *models.CronRun) error {
+	// manual runs and the scheduler can finish at the same time
+	lockManager := GetGlobalLockManager()
+	lockName := fmt.Sprintf("%s.cron.%s.history", appName, run.Job)
+	if err := lockManager.TryLock(lockName, 5*time.Second); err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+	defer lockManager.Unlock(lockName)
+
+	runs, err := CronHistory(appName, run.Job)
+	if err != nil {
+		return err
+	}
+
+	runs = append(runs, *run)
+	if len(runs) > maxCronHistory {
+		for _, old := range runs[:len(runs)-maxCronHistory] {
+			if old.LogPath != "" {
+				os.Remove(old.LogPath)
+			}
+		}
+		runs = runs[len(runs)-maxCronHistory:]
+	}
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cron history: %w", err)
+	}
+
+	dir := cronJobDir(appName, run.Job)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cron directory: %w", err)
+	}
+
+	tmpPath := filepath.Join(dir, "history.json.tmp")
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cron history: %w", err)
+	}
+	return os.Rename(tmpPath, filepath.Join(dir, "history.json"))
+}
+
+// drops a job's history and logs, for jobs that were removed
+func RemoveCronHistory(appName, jobName string) error {
+	return os.RemoveAll(cronJobDir(appName, jobName))
+}
+
+// replaces the jobs yap.toml defined with jobs, keeping the ones added with yap app cron add
+// unless yap.toml now defines a job by the same name
+func SyncCronJobs(app *models.Application, jobs []models.CronJob) {
+	defined := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		defined[job.Name] = true
+	}
+
+	synced := make([]models.CronJob, 0, len(app.CronJobs)+len(jobs))
+	for _, job := range app.CronJobs {
+		if job.Source != CronSourceConfig && !defined[job.Name] {
+			synced = append(synced, job)
+		}
+	}
+
+	for _, job := range jobs {
+		// keep the creation time of jobs that were already there
+		if existing := FindCronJob(app, job.Name); existing != nil && existing.Source == CronSourceConfig {
+			job.CreatedAt = existing.CreatedAt
+		}
+		synced = append(synced, job)
+	}
+
+	app.CronJobs = synced
+}
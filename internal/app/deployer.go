@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aelpxy/yap/pkg/models"
@@ -20,6 +21,8 @@ func NewDeployer(strategyType models.DeploymentStrategy) (*Deployer, error) {
 		strategy = NewRollingStrategy()
 	case models.DeploymentStrategyBlueGreen:
 		strategy = NewBlueGreenStrategy()
+	case models.DeploymentStrategyCanary:
+		strategy = NewCanaryStrategy()
 	default:
 		return nil, fmt.Errorf("unknown deployment strategy: %s", strategyType)
 	}
@@ -29,13 +32,14 @@ func NewDeployer(strategyType models.DeploymentStrategy) (*Deployer, error) {
 	}, nil
 }
 
-func (d *Deployer) Deploy(opts DeploymentOptions) (string, error) {
+func (d *Deployer) Deploy(ctx context.Context, opts DeploymentOptions) (string, error) {
 	if err := d.strategy.Validate(opts); err != nil {
 		return "", fmt.Errorf("deployment validation failed: %w", err)
 	}
 
-	imageID, err := d.strategy.Deploy(opts)
+	imageID, err := d.strategy.Deploy(ctx, opts)
 	if err != nil {
+		opts.emit(DeploymentEvent{Type: EventDeployFailed, Message: "deployment failed", Error: err.Error()})
 		return "", fmt.Errorf("deployment failed: %w", err)
 	}
 
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/router"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+)
+
+func stopTimeout(app *models.Application) int {
+	if app.StopTimeout > 0 {
+		return app.StopTimeout
+	}
+	return constants.DefaultStopTimeout
+}
+
+// new instances carry the stop settings themselves, so a plain docker stop behaves the same
+func applyStopConfig(config *dockerTypes.Config, app *models.Application) {
+	timeout := stopTimeout(app)
+	config.StopTimeout = &timeout
+	config.StopSignal = app.StopSignal
+}
+
+// sends the app's stop signal and gives the instance its grace period before docker kills it
+func StopInstance(ctx context.Context, dockerClient *docker.Client, app *models.Application, containerID string) error {
+	timeout := stopTimeout(app)
+	return dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
+		Signal:  app.StopSignal,
+		Timeout: &timeout,
+	})
+}
+
+// how long stopping count instances may take, drain period included
+func StopDeadline(app *models.Application, count int) time.Duration {
+	perInstance := time.Duration(stopTimeout(app))*time.Second + docker.ContainerOpTimeout
+	return time.Duration(app.DrainPeriod)*time.Second + time.Duration(count)*perInstance
+}
+
+// takes the load balancer off everything but the remaining instances and waits the app's drain
+// period, so requests in flight can finish. the returned func hands routing back to the docker
+// provider and has to be called once the drained instances are gone
+func DrainTraffic(ctx context.Context, dockerClient *docker.Client, app *models.Application, remaining []string) (func(), error) {
+	restore := func() {}
+
+	if app.DrainPeriod <= 0 || len(remaining) == 0 || app.Port == 0 {
+		return restore, nil
+	}
+
+	traefik := router.NewTraefikManager(dockerClient)
+	supported, err := traefik.SupportsWeightedRouting()
+	if err != nil || !supported {
+		// older load balancers can't be re-routed, the stop signal is all the instance gets
+		return restore, nil
+	}
+
+	servers := make([]string, 0, len(remaining))
+	for _, containerID := range remaining {
+		inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+		if err != nil || !inspect.State.Running {
+			continue
+		}
+		for _, networkSettings := range inspect.NetworkSettings.Networks {
+			if networkSettings.IPAddress != "" {
+				servers = append(servers, fmt.Sprintf("http://%s:%d", networkSettings.IPAddress, app.Port))
+				break
+			}
+		}
+	}
+	if len(servers) == 0 {
+		return restore, nil
+	}
+
+	if err := traefik.SetDrainRouting(app, servers); err != nil {
+		return restore, err
+	}
+	restore = func() {
+		traefik.ClearDrainRouting(app.Name)
+	}
+
+	sleepContext(ctx, time.Duration(app.DrainPeriod)*time.Second)
+
+	return restore, nil
+}
+
+// drains containerIDs behind the remaining instances, then stops and removes them. it runs to
+// completion even when the caller was cancelled, half drained instances would stay out of routing
+func DrainAndRemove(dockerClient *docker.Client, app *models.Application, containerIDs, remaining []string, events EventSink) error {
+	if len(containerIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), StopDeadline(app, len(containerIDs)))
+	defer cancel()
+
+	restore, err := DrainTraffic(ctx, dockerClient, app, remaining)
+	if err != nil {
+		emitEvent(events, app, DeploymentEvent{Type: EventWarning, Message: fmt.Sprintf("failed to drain connections: %v", err)})
+	}
+	defer restore()
+
+	var firstErr error
+	for _, containerID := range containerIDs {
+		if err := StopInstance(ctx, dockerClient, app, containerID); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to stop container: %w", err)
+		}
+
+		if err := dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{
+			Force: true,
+		}); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to remove container: %w", err)
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+type DeploymentEventType string
+
+const (
+	EventDeployStarted   DeploymentEventType = "deploy.started"
+	EventDeployCompleted DeploymentEventType = "deploy.completed"
+	EventDeployFailed    DeploymentEventType = "deploy.failed"
+
+	EventPhaseStarted   DeploymentEventType = "phase.started"
+	EventPhaseCompleted DeploymentEventType = "phase.completed"
+
+	EventInstanceCreated  DeploymentEventType = "instance.created"
+	EventInstanceDraining DeploymentEventType = "instance.draining"
+	EventInstanceRemoved  DeploymentEventType = "instance.removed"
+
+	EventHealthWaiting DeploymentEventType = "health.waiting"
+	EventHealthPassed  DeploymentEventType = "health.passed"
+	EventHealthFailed  DeploymentEventType = "health.failed"
+
+	EventTrafficSwitched DeploymentEventType = "traffic.switched"
+
+	EventWaiting DeploymentEventType = "waiting"
+	EventWarning DeploymentEventType = "warning"
+
+	// emitted by the deploy command around the strategy
+	EventBuildCompleted  DeploymentEventType = "build.completed"
+	EventReleaseRecorded DeploymentEventType = "release.recorded"
+)
+
+// one step of a deployment. Message is the human readable line, the other fields are there so
+// consumers don't have to parse it
+type DeploymentEvent struct {
+	Type    DeploymentEventType `json:"type"`
+	Time    time.Time           `json:"time"`
+	App     string              `json:"app"`
+	Process string              `json:"process,omitempty"` // set for processes besides web
+	Message string              `json:"message"`
+
+	Instance    int    `json:"instance,omitempty"` // 1-based position within Total
+	Total       int    `json:"total,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	Color       string `json:"color,omitempty"`  // blue-green environment
+	Weight      int    `json:"weight,omitempty"` // percentage of traffic on the new release
+	Version     int    `json:"version,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type EventSink interface {
+	Emit(event DeploymentEvent)
+}
+
+// renders events as the indented progress lines the cli has always printed
+type TerminalEventSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewTerminalEventSink(w io.Writer) *TerminalEventSink {
+	return &TerminalEventSink{w: w}
+}
+
+func (s *TerminalEventSink) Emit(event DeploymentEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	progress := ""
+	if event.Total > 0 {
+		progress = fmt.Sprintf("[%d/%d] ", event.Instance, event.Total)
+	}
+
+	switch event.Type {
+	case EventDeployStarted:
+		fmt.Fprintf(s.w, "  --> %s\n\n", event.Message)
+	case EventDeployCompleted:
+		fmt.Fprintf(s.w, "\n  [done] %s\n", event.Message)
+	case EventDeployFailed:
+		// the caller reports the error itself
+	case EventPhaseStarted, EventTrafficSwitched:
+		fmt.Fprintf(s.w, "  --> %s\n", event.Message)
+	case EventPhaseCompleted:
+		fmt.Fprintf(s.w, "  [done] %s\n", event.Message)
+	case EventHealthFailed:
+		fmt.Fprintf(s.w, "    [error] %s\n", event.Message)
+	case EventWarning:
+		fmt.Fprintf(s.w, "    [warn] %s\n", event.Message)
+	case EventBuildCompleted, EventReleaseRecorded:
+		// the deploy command prints its own summary
+	default:
+		fmt.Fprintf(s.w, "    %s%s\n", progress, event.Message)
+	}
+}
+
+// writes one json object per line, for pipelines and anything else reading progress programmatically
+type JSONEventSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJSONEventSink(w io.Writer) *JSONEventSink {
+	return &JSONEventSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *JSONEventSink) Emit(event DeploymentEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.encoder.Encode(event)
+}
+
+// fills in what every event carries, without a sink events go to the terminal
+func emitEvent(sink EventSink, app *models.Application, event DeploymentEvent) {
+	if sink == nil {
+		sink = NewTerminalEventSink(os.Stdout)
+	}
+
+	event.Time = time.Now()
+	if app != nil {
+		event.App = app.Name
+		event.Process = app.Process
+	}
+	sink.Emit(event)
+}
+
+func (o DeploymentOptions) emit(event DeploymentEvent) {
+	emitEvent(o.Events, o.App, event)
+}
+
+func (o DeploymentOptions) warn(format string, args ...interface{}) {
+	o.emit(DeploymentEvent{Type: EventWarning, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+const (
+	HookPreBuild   = "prebuild"
+	HookPostBuild  = "postbuild"
+	HookPreDeploy  = "predeploy"
+	HookPostDeploy = "postdeploy"
+)
+
+// runs a build hook on the host from the project directory
+func RunHostHook(ctx context.Context, name, command, dir string, output io.Writer) error {
+	hookCmd := exec.CommandContext(ctx, "sh", "-c", command)
+	hookCmd.Dir = dir
+	hookCmd.Stdout = output
+	hookCmd.Stderr = output
+
+	if err := hookCmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+
+	return nil
+}
+
+// runs a deploy hook as a one-off task from the release image, so it reaches the same databases
+// and volumes the instances will
+func RunReleaseHook(ctx context.Context, dockerClient *docker.Client, app *models.Application, image, name, command string, output io.Writer) error {
+	exitCode, err := RunTask(ctx, dockerClient, app, image, name, []string{"sh", "-c", command}, output, output)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("%s hook exited with code %d", name, exitCode)
+	}
+
+	return nil
+}
@@ -30,6 +30,12 @@ func GetGlobalLockManager() *LockManager {
 	return globalLockManager
 }
 
+// the lock a background daemon holds while it runs. app names can't start with a dot, so these never
+// collide with an app's lock
+func DaemonLockName(daemon string) string {
+	return ".daemon-" + daemon
+}
+
 func (lm *LockManager) TryLock(appName string, timeout time.Duration) error {
 	lockFile := filepath.Join(lm.lockDir, appName+".lock")
 
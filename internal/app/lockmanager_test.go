@@ -0,0 +1,39 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aelpxy/yap/internal/utils"
+)
+
+func TestDaemonLockName(t *testing.T) {
+	for _, daemon := range []string{"cron-scheduler", "autoscaler", "watchdog"} {
+		name := DaemonLockName(daemon)
+		if utils.IsValidName(name) {
+			t.Errorf("DaemonLockName(%q) = %q, which is a valid app name", daemon, name)
+		}
+	}
+}
+
+func TestLockManager(t *testing.T) {
+	lm := &LockManager{lockDir: t.TempDir()}
+
+	if err := lm.TryLock("myapp", time.Second); err != nil {
+		t.Fatalf("TryLock() error = %v", err)
+	}
+	if !lm.IsLocked("myapp") {
+		t.Error("myapp not locked")
+	}
+	if err := lm.TryLock("myapp", 200*time.Millisecond); err == nil {
+		t.Error("TryLock() took a lock that's held")
+	}
+	if lm.IsLocked(DaemonLockName("myapp")) {
+		t.Error("an app lock holds the daemon lock of the same name")
+	}
+
+	lm.Unlock("myapp")
+	if lm.IsLocked("myapp") {
+		t.Error("myapp still locked after Unlock")
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+const observePollInterval = 5 * time.Second
+
+// watches a freshly deployed release for the given window and returns the reason it went bad, if it did
+func ObserveRelease(ctx context.Context, dockerClient *docker.Client, app *models.Application, window time.Duration) error {
+	spec := LivenessProbe(app)
+	probe, err := NewProbe(dockerClient, spec, app.Port)
+	if err != nil {
+		return err
+	}
+	threshold := probeRetries(spec)
+
+	baselineRestarts := make(map[string]int, len(app.ContainerIDs))
+	for _, containerID := range app.ContainerIDs {
+		inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect instance %s: %w", containerID[:12], err)
+		}
+		baselineRestarts[containerID] = inspect.RestartCount
+	}
+
+	interval := observePollInterval
+	if spec.Interval > 0 {
+		interval = time.Duration(spec.Interval) * time.Second
+	}
+
+	healthFailures := make(map[string]int, len(app.ContainerIDs))
+
+	deadline := time.Now().Add(window)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		for i, containerID := range app.ContainerIDs {
+			instanceNum := i + 1
+
+			inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+			if err != nil {
+				return fmt.Errorf("instance %d disappeared: %w", instanceNum, err)
+			}
+
+			if inspect.State.OOMKilled {
+				return fmt.Errorf("instance %d was killed for running out of memory", instanceNum)
+			}
+			if inspect.RestartCount > baselineRestarts[containerID] {
+				return fmt.Errorf("instance %d restarted %d time(s) (last exit code %d)", instanceNum, inspect.RestartCount-baselineRestarts[containerID], inspect.State.ExitCode)
+			}
+			if !inspect.State.Running {
+				return fmt.Errorf("instance %d exited (exit code %d)", instanceNum, inspect.State.ExitCode)
+			}
+
+			if spec.Type == models.HealthProbeNone {
+				continue
+			}
+
+			err = ProbeInstance(ctx, dockerClient, app, probe, spec, containerID)
+			if err == nil {
+				healthFailures[containerID] = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			healthFailures[containerID]++
+			if healthFailures[containerID] >= threshold {
+				return fmt.Errorf("instance %d failed %d consecutive health checks (%s): %v", instanceNum, healthFailures[containerID], DescribeProbe(spec), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// point-in-time health of a set of instances, an instance gets the liveness probe's retries
+// before it counts as down
+func CheckReleaseHealth(ctx context.Context, dockerClient *docker.Client, app *models.Application, containerIDs []string) error {
+	spec := LivenessProbe(app)
+	probe, err := NewProbe(dockerClient, spec, app.Port)
+	if err != nil {
+		return err
+	}
+
+	for i, containerID := range containerIDs {
+		instanceNum := i + 1
+
+		var lastErr error
+		for attempt := 0; attempt < probeRetries(spec); attempt++ {
+			if attempt > 0 {
+				if err := sleepContext(ctx, 2*time.Second); err != nil {
+					return err
+				}
+			}
+
+			lastErr = ProbeInstance(ctx, dockerClient, app, probe, spec, containerID)
+			if lastErr == nil {
+				break
+			}
+			if isInstanceDown(lastErr) {
+				break
+			}
+		}
+
+		if lastErr != nil {
+			return fmt.Errorf("instance %d failed health check: %v", instanceNum, lastErr)
+		}
+	}
+
+	return nil
+}
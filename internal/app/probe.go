@@ -0,0 +1,399 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aelpxy/yap/internal/constants"
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+)
+
+const (
+	defaultProbeTimeout = 3 * time.Second
+	defaultProbeRetries = 3
+
+	// how often readiness is polled when the probe has no interval of its own
+	readinessPollInterval = 2 * time.Second
+
+	// without a probe an instance counts as ready once it stayed up this long
+	unprobedSettleTime = 5 * time.Second
+)
+
+// the instance itself stopped, as opposed to running but failing its probe
+type instanceDownError struct {
+	reason string
+}
+
+func (e *instanceDownError) Error() string {
+	return e.reason
+}
+
+func isInstanceDown(err error) bool {
+	var down *instanceDownError
+	return errors.As(err, &down)
+}
+
+// an unprobed instance that hasn't been up for unprobedSettleTime yet
+type instanceSettlingError struct {
+	remaining time.Duration
+}
+
+func (e *instanceSettlingError) Error() string {
+	return fmt.Sprintf("settling, %s to go", e.remaining.Round(time.Second))
+}
+
+// checks a single instance, a nil error means healthy
+type Probe interface {
+	Check(ctx context.Context, target ProbeTarget) error
+}
+
+type ProbeTarget struct {
+	ContainerID string
+	Address     string // ip on the app's vpc network
+}
+
+func NewProbe(dockerClient *docker.Client, spec models.HealthProbe, appPort int) (Probe, error) {
+	port := spec.Port
+	if port == 0 {
+		port = appPort
+	}
+
+	switch spec.Type {
+	case models.HealthProbeHTTP:
+		return &httpProbe{
+			path:           spec.Path,
+			port:           port,
+			expectedStatus: spec.ExpectedStatus,
+			expectedBody:   spec.ExpectedBody,
+		}, nil
+	case models.HealthProbeTCP:
+		return &tcpProbe{port: port}, nil
+	case models.HealthProbeExec:
+		if len(spec.Command) == 0 {
+			return nil, fmt.Errorf("exec health check requires a command")
+		}
+		return &execProbe{dockerClient: dockerClient, command: spec.Command}, nil
+	case models.HealthProbeNone, "":
+		return noneProbe{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown health check type: %s", spec.Type)
+}
+
+type httpProbe struct {
+	path           string
+	port           int
+	expectedStatus int
+	expectedBody   string
+}
+
+func (p *httpProbe) Check(ctx context.Context, target ProbeTarget) error {
+	if target.Address == "" {
+		return fmt.Errorf("no address yet")
+	}
+
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(target.Address, fmt.Sprintf("%d", p.port)), p.path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid health check url: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if p.expectedStatus != 0 {
+		if resp.StatusCode != p.expectedStatus {
+			return fmt.Errorf("status %d, expected %d", resp.StatusCode, p.expectedStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if p.expectedBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		if !strings.Contains(string(body), p.expectedBody) {
+			return fmt.Errorf("response does not contain %q", p.expectedBody)
+		}
+	}
+
+	return nil
+}
+
+type tcpProbe struct {
+	port int
+}
+
+func (p *tcpProbe) Check(ctx context.Context, target ProbeTarget) error {
+	if target.Address == "" {
+		return fmt.Errorf("no address yet")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target.Address, fmt.Sprintf("%d", p.port)))
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	return nil
+}
+
+type execProbe struct {
+	dockerClient *docker.Client
+	command      []string
+}
+
+func (p *execProbe) Check(ctx context.Context, target ProbeTarget) error {
+	exec, err := p.dockerClient.GetClient().ContainerExecCreate(ctx, target.ContainerID, dockerTypes.ExecOptions{
+		Cmd: p.command,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	if err := p.dockerClient.GetClient().ContainerExecStart(ctx, exec.ID, dockerTypes.ExecStartOptions{Detach: true}); err != nil {
+		return fmt.Errorf("failed to start exec: %w", err)
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		inspect, err := p.dockerClient.GetClient().ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect exec: %w", err)
+		}
+		if !inspect.Running {
+			if inspect.ExitCode != 0 {
+				return fmt.Errorf("%s exited with code %d", p.command[0], inspect.ExitCode)
+			}
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// running is all that's checked, ProbeInstance already does that
+type noneProbe struct{}
+
+func (noneProbe) Check(ctx context.Context, target ProbeTarget) error {
+	return nil
+}
+
+// apps registered before probes existed get an http probe on their health path, or none without one
+func ReadinessProbe(app *models.Application) models.HealthProbe {
+	if app.ReadinessProbe != nil {
+		return *app.ReadinessProbe
+	}
+
+	if app.HealthCheckPath == "" {
+		return models.HealthProbe{Type: models.HealthProbeNone}
+	}
+	return models.HealthProbe{
+		Type:     models.HealthProbeHTTP,
+		Path:     app.HealthCheckPath,
+		Interval: app.HealthCheckInterval,
+		Timeout:  app.HealthCheckTimeout,
+		Retries:  defaultProbeRetries,
+	}
+}
+
+func LivenessProbe(app *models.Application) models.HealthProbe {
+	if app.LivenessProbe != nil {
+		return *app.LivenessProbe
+	}
+	return ReadinessProbe(app)
+}
+
+// whether readiness was configured for the app rather than taken from the defaults
+func ReadinessConfigured(app *models.Application) bool {
+	return app.ReadinessProbe != nil
+}
+
+// stores the probes on the app and keeps the older http fields in sync, the load balancer
+// health check only understands http. readiness that wasn't configured only lives in those
+// fields, the way apps registered before probes existed have it
+func SetHealthProbes(app *models.Application, readiness models.HealthProbe, liveness *models.HealthProbe, configured bool) {
+	app.ReadinessProbe = nil
+	if configured {
+		app.ReadinessProbe = &readiness
+	}
+	app.LivenessProbe = liveness
+
+	app.HealthCheckPath = ""
+	if readiness.Type == models.HealthProbeHTTP {
+		app.HealthCheckPath = readiness.Path
+	}
+	app.HealthCheckInterval = readiness.Interval
+	app.HealthCheckTimeout = readiness.Timeout
+}
+
+func DescribeProbe(spec models.HealthProbe) string {
+	var desc string
+	switch spec.Type {
+	case models.HealthProbeHTTP:
+		desc = "http " + spec.Path
+		if spec.ExpectedStatus != 0 {
+			desc += fmt.Sprintf(" (expect %d)", spec.ExpectedStatus)
+		}
+		if spec.ExpectedBody != "" {
+			desc += fmt.Sprintf(" (body contains %q)", spec.ExpectedBody)
+		}
+	case models.HealthProbeTCP:
+		desc = "tcp connect"
+	case models.HealthProbeExec:
+		desc = "exec " + strings.Join(spec.Command, " ")
+	default:
+		return "none (instance must stay running)"
+	}
+
+	if spec.Port != 0 {
+		desc += fmt.Sprintf(" on port %d", spec.Port)
+	}
+	return fmt.Sprintf("%s every %ds, timeout %ds, %d retries", desc, spec.Interval, spec.Timeout, spec.Retries)
+}
+
+func probeTimeout(spec models.HealthProbe) time.Duration {
+	if spec.Timeout > 0 {
+		return time.Duration(spec.Timeout) * time.Second
+	}
+	return defaultProbeTimeout
+}
+
+func probeRetries(spec models.HealthProbe) int {
+	if spec.Retries > 0 {
+		return spec.Retries
+	}
+	return defaultProbeRetries
+}
+
+// inspects the instance and runs the probe against it once, an instance that isn't running fails
+// whatever the probe says
+func ProbeInstance(ctx context.Context, dockerClient *docker.Client, app *models.Application, probe Probe, spec models.HealthProbe, containerID string) error {
+	inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if inspect.State.OOMKilled {
+		return &instanceDownError{reason: "killed for running out of memory"}
+	}
+	if !inspect.State.Running {
+		return &instanceDownError{reason: fmt.Sprintf("container stopped unexpectedly (exit code %d)", inspect.State.ExitCode)}
+	}
+
+	target := ProbeTarget{ContainerID: containerID}
+	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", app.VPC)
+	if networkSettings, ok := inspect.NetworkSettings.Networks[vpcNetworkName]; ok {
+		target.Address = networkSettings.IPAddress
+	} else {
+		// instances of a redeploy into another vpc aren't on the stored app's network yet
+		for _, networkSettings := range inspect.NetworkSettings.Networks {
+			target.Address = networkSettings.IPAddress
+			break
+		}
+	}
+
+	if spec.Type == models.HealthProbeNone || spec.Type == "" {
+		startedAt, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+		if uptime := time.Since(startedAt); err == nil && uptime < unprobedSettleTime {
+			return &instanceSettlingError{remaining: unprobedSettleTime - uptime}
+		}
+		return nil
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout(spec))
+	defer cancel()
+
+	return probe.Check(probeCtx, target)
+}
+
+// probes the instance straight away, then polls until it passes or the deadline runs out. timeout
+// is the deployment's readiness deadline, see readinessTiming for how the probe stretches it
+func WaitForReady(ctx context.Context, dockerClient *docker.Client, app *models.Application, containerID string, timeout time.Duration) error {
+	spec := ReadinessProbe(app)
+	probe, err := NewProbe(dockerClient, spec, app.Port)
+	if err != nil {
+		return err
+	}
+
+	interval, deadline := readinessTiming(spec, timeout)
+	return pollReady(ctx, interval, deadline, func(ctx context.Context) error {
+		return ProbeInstance(ctx, dockerClient, app, probe, spec, containerID)
+	})
+}
+
+// how often readiness is polled and how long it may take in total. polls never wait past the
+// deadline, and the deadline leaves room for an unprobed instance to settle and for the probe to
+// use its retries at its interval
+func readinessTiming(spec models.HealthProbe, timeout time.Duration) (interval, deadline time.Duration) {
+	deadline = timeout
+	if deadline <= 0 {
+		deadline = time.Duration(constants.DefaultHealthTimeout) * time.Second
+	}
+
+	interval = readinessPollInterval
+	if spec.Interval > 0 {
+		interval = time.Duration(spec.Interval) * time.Second
+	}
+	interval = min(interval, deadline)
+
+	if spec.Type == models.HealthProbeNone || spec.Type == "" {
+		deadline = max(deadline, unprobedSettleTime+readinessPollInterval)
+	} else {
+		deadline = max(deadline, time.Duration(probeRetries(spec))*interval)
+	}
+	return interval, deadline
+}
+
+// runs check until it passes, the instance goes down or deadline runs out. a settling instance
+// is checked again as soon as it's due instead of at the next interval
+func pollReady(ctx context.Context, interval, deadline time.Duration, check func(ctx context.Context) error) error {
+	readyCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	lastErr := fmt.Errorf("no response")
+	for {
+		err := check(readyCtx)
+		if err == nil {
+			return nil
+		}
+		if readyCtx.Err() == nil {
+			lastErr = err
+		}
+		// a crashed instance won't come back by waiting
+		if isInstanceDown(err) {
+			return err
+		}
+
+		wait := interval
+		var settling *instanceSettlingError
+		if errors.As(err, &settling) {
+			wait = min(wait, settling.remaining)
+		}
+		if sleepContext(readyCtx, wait) != nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("health check timeout exceeded (last error: %v)", lastErr)
+		}
+	}
+}
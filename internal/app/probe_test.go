@@ -0,0 +1,192 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+func TestReadinessTiming(t *testing.T) {
+	tests := []struct {
+		name         string
+		spec         models.HealthProbe
+		timeout      time.Duration
+		wantInterval time.Duration
+		wantDeadline time.Duration
+	}{
+		{
+			name:         "deploy defaults poll within the deadline",
+			spec:         models.HealthProbe{Type: models.HealthProbeHTTP, Interval: 10, Retries: 3},
+			timeout:      5 * time.Second,
+			wantInterval: 5 * time.Second,
+			wantDeadline: 15 * time.Second,
+		},
+		{
+			name:         "interval shorter than the deadline",
+			spec:         models.HealthProbe{Type: models.HealthProbeHTTP, Interval: 10, Retries: 3},
+			timeout:      60 * time.Second,
+			wantInterval: 10 * time.Second,
+			wantDeadline: 60 * time.Second,
+		},
+		{
+			name:         "retries stretch the deadline",
+			spec:         models.HealthProbe{Type: models.HealthProbeTCP, Interval: 10, Retries: 5},
+			timeout:      30 * time.Second,
+			wantInterval: 10 * time.Second,
+			wantDeadline: 50 * time.Second,
+		},
+		{
+			name:         "no interval polls at the default",
+			spec:         models.HealthProbe{Type: models.HealthProbeExec},
+			timeout:      30 * time.Second,
+			wantInterval: readinessPollInterval,
+			wantDeadline: 30 * time.Second,
+		},
+		{
+			name:         "no timeout uses the default deadline",
+			spec:         models.HealthProbe{Type: models.HealthProbeHTTP, Interval: 10, Retries: 3},
+			wantInterval: 10 * time.Second,
+			wantDeadline: 30 * time.Second,
+		},
+		{
+			name:         "none covers the settle time",
+			spec:         models.HealthProbe{Type: models.HealthProbeNone},
+			timeout:      5 * time.Second,
+			wantInterval: readinessPollInterval,
+			wantDeadline: unprobedSettleTime + readinessPollInterval,
+		},
+		{
+			name:         "none keeps a longer deadline",
+			spec:         models.HealthProbe{Type: models.HealthProbeNone},
+			timeout:      30 * time.Second,
+			wantInterval: readinessPollInterval,
+			wantDeadline: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval, deadline := readinessTiming(tt.spec, tt.timeout)
+			if interval != tt.wantInterval {
+				t.Errorf("interval = %v, want %v", interval, tt.wantInterval)
+			}
+			if deadline != tt.wantDeadline {
+				t.Errorf("deadline = %v, want %v", deadline, tt.wantDeadline)
+			}
+			if interval > deadline {
+				t.Errorf("interval %v longer than the deadline %v", interval, deadline)
+			}
+		})
+	}
+}
+
+// a check that fails with the given errors in order, then passes
+func checkSequence(errs ...error) (func(ctx context.Context) error, *int) {
+	calls := 0
+	return func(ctx context.Context) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestPollReady(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		interval  time.Duration
+		deadline  time.Duration
+		wantCalls int
+		wantErr   string
+	}{
+		{
+			name:      "probes straight away",
+			interval:  time.Hour,
+			deadline:  time.Hour,
+			wantCalls: 1,
+		},
+		{
+			name:      "polls until the probe passes",
+			errs:      []error{errors.New("connection refused"), errors.New("status 503")},
+			interval:  10 * time.Millisecond,
+			deadline:  time.Second,
+			wantCalls: 3,
+		},
+		{
+			name:      "a settling instance is checked when it's due",
+			errs:      []error{&instanceSettlingError{remaining: 20 * time.Millisecond}},
+			interval:  time.Hour,
+			deadline:  time.Second,
+			wantCalls: 2,
+		},
+		{
+			name:      "a crashed instance fails at once",
+			errs:      []error{&instanceDownError{reason: "container stopped unexpectedly (exit code 1)"}},
+			interval:  10 * time.Millisecond,
+			deadline:  time.Second,
+			wantCalls: 1,
+			wantErr:   "container stopped unexpectedly",
+		},
+		{
+			name:      "timeout reports the last error",
+			errs:      []error{errors.New("status 503"), errors.New("status 503"), errors.New("status 503")},
+			interval:  40 * time.Millisecond,
+			deadline:  60 * time.Millisecond,
+			wantCalls: 2,
+			wantErr:   "health check timeout exceeded (last error: status 503)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, calls := checkSequence(tt.errs...)
+			err := pollReady(context.Background(), tt.interval, tt.deadline, check)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("pollReady() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("pollReady() error = %v, want %q", err, tt.wantErr)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("checks = %d, want %d", *calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPollReadyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	check := func(context.Context) error {
+		cancel()
+		return errors.New("connection refused")
+	}
+
+	if err := pollReady(ctx, time.Hour, time.Hour, check); !errors.Is(err, context.Canceled) {
+		t.Errorf("pollReady() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestSetHealthProbes(t *testing.T) {
+	readiness := models.HealthProbe{Type: models.HealthProbeHTTP, Path: "/ready", Interval: 5, Timeout: 2, Retries: 3}
+
+	configured := &models.Application{}
+	SetHealthProbes(configured, readiness, nil, true)
+	if !ReadinessConfigured(configured) {
+		t.Error("configured readiness not stored")
+	}
+
+	defaults := &models.Application{}
+	SetHealthProbes(defaults, readiness, nil, false)
+	if ReadinessConfigured(defaults) {
+		t.Error("default readiness stored as configured")
+	}
+	if got := ReadinessProbe(defaults); got.Type != models.HealthProbeHTTP || got.Path != "/ready" || got.Interval != 5 {
+		t.Errorf("ReadinessProbe() = %+v, want the http probe from the legacy fields", got)
+	}
+}
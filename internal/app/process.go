@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+	"github.com/lucsky/cuid"
+)
+
+// the process an instance of app runs, web for the application itself
+func processName(app *models.Application) string {
+	if app.Process == "" {
+		return models.ProcessWeb
+	}
+	return app.Process
+}
+
+// container names of an instance start with this. dots can't appear in app names, so a worker
+// process never collides with another app's web instances
+func instanceNamePrefix(app *models.Application) string {
+	if app.Process == "" {
+		return fmt.Sprintf("yap-app-%s", app.Name)
+	}
+	return fmt.Sprintf("yap-app-%s.%s", app.Name, app.Process)
+}
+
+// the name of a fresh instance. the random suffix keeps it clear of the instance it replaces and
+// of leftovers from a process scaled to 0 and back
+func newInstanceName(app *models.Application, instanceNum int) string {
+	return fmt.Sprintf("%s-%d-%s", instanceNamePrefix(app), instanceNum, cuid.Slug())
+}
+
+// labels the instance with its process and runs the process command through a shell, the way a
+// Procfile line would run
+func applyProcess(config *dockerTypes.Config, app *models.Application) {
+	if config.Labels != nil {
+		config.Labels["yap.app.process"] = processName(app)
+	}
+	if app.Command != "" {
+		config.Entrypoint = []string{"/bin/sh", "-c"}
+		config.Cmd = []string{app.Command}
+	}
+}
+
+// a copy of app standing in for one of its processes, so strategies and the scaler can work on it
+// like on any app. web is the application itself. the process has no port, so it gets no routing,
+// no draining and no probe beyond staying up
+func ProcessApplication(app *models.Application, name string) (*models.Application, *models.Process, error) {
+	if name == models.ProcessWeb {
+		return app, nil, nil
+	}
+
+	for i := range app.Processes {
+		process := &app.Processes[i]
+		if process.Name != name {
+			continue
+		}
+
+		view := *app
+		view.Process = process.Name
+		view.Command = process.Command
+		view.Instances = process.Instances
+		view.ContainerIDs = append([]string(nil), process.ContainerIDs...)
+		view.Memory = process.Memory
+		if view.Memory == 0 {
+			view.Memory = app.Memory
+		}
+		view.CPU = process.CPU
+		if view.CPU == 0 {
+			view.CPU = app.CPU
+		}
+
+		view.Port = 0
+		view.Published = false
+		view.CustomDomains = nil
+		view.DrainPeriod = 0
+		none := models.HealthProbe{Type: models.HealthProbeNone}
+		view.ReadinessProbe = &none
+		view.LivenessProbe = &none
+		view.HealthCheckPath = ""
+		view.AutoScaleEnabled = false
+		view.DeploymentState = models.DeploymentState{Active: models.DeploymentColorDefault}
+		view.Processes = nil
+
+		return &view, process, nil
+	}
+
+	return nil, nil, fmt.Errorf("process not found: %s", name)
+}
+
+// every instance of the app, web first and then each process
+func AllContainerIDs(app *models.Application) []string {
+	containerIDs := append([]string(nil), app.ContainerIDs...)
+	for _, process := range app.Processes {
+		containerIDs = append(containerIDs, process.ContainerIDs...)
+	}
+	return containerIDs
+}
+
+// rolls the processes besides web onto opts.NewImageID. they take no traffic, so blue-green and
+// canary have nothing to shift and roll them instead. stops at the first process that fails, the
+// ones already rolled keep the new image
+func DeployProcesses(ctx context.Context, dockerClient *docker.Client, opts DeploymentOptions) error {
+	for _, process := range opts.App.Processes {
+		if err := DeployProcess(ctx, dockerClient, opts, process.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rolls a single process onto opts.NewImageID, the way DeployProcesses rolls each of them
+func DeployProcess(ctx context.Context, dockerClient *docker.Client, opts DeploymentOptions, name string) error {
+	strategyType := models.DeploymentStrategyRolling
+	if opts.App.DeploymentStrategy == models.DeploymentStrategyRecreate {
+		strategyType = models.DeploymentStrategyRecreate
+	}
+
+	view, stored, err := ProcessApplication(opts.App, name)
+	if err != nil {
+		return err
+	}
+
+	processOpts := opts
+	processOpts.App = view
+	processOpts.TraefikLabels = nil
+	processOpts.MemoryMB = view.Memory
+	processOpts.CPUCores = view.CPU
+	if processOpts.Config.MaxSurge < 1 {
+		processOpts.Config.MaxSurge = 1
+	}
+
+	if view.Instances == 0 {
+		if err := DrainAndRemove(dockerClient, view, view.ContainerIDs, nil, opts.Events); err != nil {
+			return fmt.Errorf("process %s: %w", name, err)
+		}
+		stored.ContainerIDs = nil
+		return nil
+	}
+
+	deployer, err := NewDeployer(strategyType)
+	if err != nil {
+		return err
+	}
+
+	processOpts.emit(DeploymentEvent{
+		Type:    EventPhaseStarted,
+		Message: fmt.Sprintf("deploying %s process (%d instances)...", name, view.Instances),
+	})
+
+	_, err = deployer.Deploy(ctx, processOpts)
+	stored.ContainerIDs = view.ContainerIDs
+	if err != nil {
+		return fmt.Errorf("process %s: %w", name, err)
+	}
+	return nil
+}
+
+// stops and removes the instances of processes that are no longer defined
+func RemoveProcesses(dockerClient *docker.Client, app *models.Application, processes []models.Process, events EventSink) error {
+	var firstErr error
+	for _, process := range processes {
+		view := *app
+		view.Process = process.Name
+		view.DrainPeriod = 0
+
+		emitEvent(events, &view, DeploymentEvent{
+			Type:    EventPhaseStarted,
+			Message: fmt.Sprintf("removing %s process (%d instances)...", process.Name, len(process.ContainerIDs)),
+		})
+		if err := DrainAndRemove(dockerClient, &view, process.ContainerIDs, nil, events); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("process %s: %w", process.Name, err)
+		}
+	}
+	return firstErr
+}
+
+// recreates the instances of every process besides web with the app's current settings, the
+// counterpart of recreating web's instances after env or link changes
+func RecreateProcesses(dockerClient *docker.Client, app *models.Application, vpcNetworkName string) error {
+	for _, process := range app.Processes {
+		view, stored, err := ProcessApplication(app, process.Name)
+		if err != nil {
+			return err
+		}
+
+		for i, containerID := range view.ContainerIDs {
+			newID, err := RecreateContainer(dockerClient, containerID, view, vpcNetworkName, i+1)
+			if err != nil {
+				return fmt.Errorf("process %s: %w", process.Name, err)
+			}
+			stored.ContainerIDs[i] = newID
+		}
+	}
+	return nil
+}
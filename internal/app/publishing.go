@@ -62,7 +62,7 @@ func (pm *PublishingManager) PublishApp(ctx context.Context, appName, customDoma
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
-	if err := pm.recreateContainersWithPublishing(ctx, app); err != nil {
+	if err := pm.rolloutPublishing(ctx, app); err != nil {
 		app.Published = false
 		app.PublishedDomain = ""
 		app.PublishedURL = ""
@@ -101,7 +101,7 @@ func (pm *PublishingManager) UnpublishApp(ctx context.Context, appName string) e
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
-	if err := pm.recreateContainersWithPublishing(ctx, app); err != nil {
+	if err := pm.rolloutPublishing(ctx, app); err != nil {
 		return fmt.Errorf("failed to update containers: %w", err)
 	}
 
@@ -143,7 +143,7 @@ func (pm *PublishingManager) AddCustomDomain(ctx context.Context, appName, domai
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
-	if err := pm.recreateContainersWithPublishing(ctx, app); err != nil {
+	if err := pm.rolloutPublishing(ctx, app); err != nil {
 		return fmt.Errorf("failed to update containers: %w", err)
 	}
 
@@ -182,36 +182,27 @@ func (pm *PublishingManager) RemoveCustomDomain(ctx context.Context, appName, do
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
-	if err := pm.recreateContainersWithPublishing(ctx, app); err != nil {
+	if err := pm.rolloutPublishing(ctx, app); err != nil {
 		return fmt.Errorf("failed to update containers: %w", err)
 	}
 
 	return nil
 }
 
-func (pm *PublishingManager) recreateContainersWithPublishing(ctx context.Context, app *models.Application) error {
-
-	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", app.VPC)
-
-	for i := 1; i <= app.Instances; i++ {
-		if i-1 >= len(app.ContainerIDs) {
-			return fmt.Errorf("container ID not found for instance %d", i)
-		}
-
-		oldContainerID := app.ContainerIDs[i-1]
-		newContainerID, err := RecreateContainer(pm.dockerClient, oldContainerID, app, vpcNetworkName, i)
-		if err != nil {
-			return fmt.Errorf("failed to recreate instance %d: %w", i, err)
-		}
-
-		app.ContainerIDs[i-1] = newContainerID
+// routing lives in the instances' labels, so web is rolled onto its current image with the app's
+// deployment strategy to pick the change up. the other processes take no traffic and keep running
+func (pm *PublishingManager) rolloutPublishing(ctx context.Context, app *models.Application) error {
+	if app.ImageID == "" || len(app.ContainerIDs) == 0 {
+		return nil
 	}
 
-	if err := pm.registry.Update(*app); err != nil {
-		return fmt.Errorf("failed to update registry: %w", err)
-	}
+	_, err := rolloutImage(ctx, pm.dockerClient, app, app.ImageID, nil)
 
-	return nil
+	// the strategy may have replaced instances before it failed
+	if updateErr := pm.registry.Update(*app); updateErr != nil && err == nil {
+		return fmt.Errorf("failed to update registry: %w", updateErr)
+	}
+	return err
 }
 
 func isValidDomain(domain string) bool {
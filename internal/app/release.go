@@ -0,0 +1,265 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/router"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+const (
+	DeploymentStatusActive     = "active"
+	DeploymentStatusSuperseded = "superseded"
+	DeploymentStatusRolledBack = "rolled-back"
+	DeploymentStatusFailed     = "failed"
+)
+
+// records written before versioning have no version, their position in history is their version
+func ReleaseVersion(app *models.Application, index int) int {
+	if app.DeploymentHistory[index].Version > 0 {
+		return app.DeploymentHistory[index].Version
+	}
+	return index + 1
+}
+
+func NextReleaseVersion(app *models.Application) int {
+	if app == nil {
+		return 1
+	}
+
+	latest := app.CurrentVersion
+	for i := range app.DeploymentHistory {
+		if v := ReleaseVersion(app, i); v > latest {
+			latest = v
+		}
+	}
+
+	return latest + 1
+}
+
+func FindRelease(app *models.Application, version int) (*models.DeploymentRecord, error) {
+	for i := range app.DeploymentHistory {
+		if ReleaseVersion(app, i) == version {
+			return &app.DeploymentHistory[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release v%d not found", version)
+}
+
+func NewDeploymentRecord(version int, imageID, imageTag string, strategy models.DeploymentStrategy) models.DeploymentRecord {
+	return models.DeploymentRecord{
+		ID:         fmt.Sprintf("dep-%s", time.Now().Format("20060102-150405")),
+		Version:    version,
+		ImageID:    imageID,
+		ImageTag:   imageTag,
+		Strategy:   strategy,
+		DeployedAt: time.Now(),
+		Status:     DeploymentStatusActive,
+		Operator:   CurrentOperator(),
+	}
+}
+
+// a new release that puts an earlier one back. it runs the same image, so the source details carry over
+func NewRedeployRecord(app *models.Application, previous *models.DeploymentRecord) models.DeploymentRecord {
+	record := NewDeploymentRecord(NextReleaseVersion(app), previous.ImageID, previous.ImageTag, app.DeploymentStrategy)
+	record.Source = previous.Source
+	record.GitRef = previous.GitRef
+	record.GitCommit = previous.GitCommit
+	record.GitBranch = previous.GitBranch
+	record.BuildMethod = previous.BuildMethod
+	return record
+}
+
+// user@host of whoever runs yap, good enough to tell operators apart after an incident
+func CurrentOperator() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if name == "" {
+		name = "unknown"
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return name
+	}
+	return fmt.Sprintf("%s@%s", name, host)
+}
+
+func SnapshotConfig(app *models.Application) *models.ReleaseConfig {
+	envKeys := make([]string, 0, len(app.EnvVars))
+	for key := range app.EnvVars {
+		envKeys = append(envKeys, key)
+	}
+	sort.Strings(envKeys)
+
+	return &models.ReleaseConfig{
+		Instances: app.Instances,
+		Memory:    app.Memory,
+		CPU:       app.CPU,
+		Port:      app.Port,
+		EnvKeys:   envKeys,
+	}
+}
+
+// appends the record as the active release and marks the previously active one with previousStatus
+func RecordRelease(app *models.Application, record models.DeploymentRecord, previousStatus string) {
+	for i := range app.DeploymentHistory {
+		if app.DeploymentHistory[i].Status == DeploymentStatusActive {
+			app.DeploymentHistory[i].Status = previousStatus
+		}
+	}
+
+	if record.Config == nil {
+		record.Config = SnapshotConfig(app)
+	}
+
+	app.DeploymentHistory = append(app.DeploymentHistory, record)
+	if record.Version > app.CurrentVersion {
+		app.CurrentVersion = record.Version
+	}
+}
+
+// keeps a release that never went live in the history, the active release stays as it is
+func RecordFailedRelease(app *models.Application, record models.DeploymentRecord, reason error) {
+	record.Status = DeploymentStatusFailed
+	record.FailureReason = reason.Error()
+	app.DeploymentHistory = append(app.DeploymentHistory, record)
+}
+
+// latest release older than version that didn't fail, along with its version
+func PreviousRelease(app *models.Application, version int) (*models.DeploymentRecord, int) {
+	for i := len(app.DeploymentHistory) - 1; i >= 0; i-- {
+		v := ReleaseVersion(app, i)
+		if v >= version {
+			continue
+		}
+		if app.DeploymentHistory[i].Status == DeploymentStatusFailed {
+			continue
+		}
+		return &app.DeploymentHistory[i], v
+	}
+	return nil, 0
+}
+
+// legacy records stored the moving :latest tag instead of a digest
+func IsLegacyRelease(record *models.DeploymentRecord) bool {
+	return record.ImageTag == "" && !strings.HasPrefix(record.ImageID, "sha256:")
+}
+
+// returns the image reference to start a release from: the release tag while it still
+// points at the recorded digest, otherwise the digest itself
+func ResolveReleaseImage(ctx context.Context, dockerClient *docker.Client, record *models.DeploymentRecord) (string, error) {
+	if record.ImageTag != "" {
+		inspect, _, err := dockerClient.GetClient().ImageInspectWithRaw(ctx, record.ImageTag)
+		if err == nil && (record.ImageID == "" || inspect.ID == record.ImageID) {
+			return record.ImageTag, nil
+		}
+	}
+
+	if record.ImageID == "" {
+		return "", fmt.Errorf("release has no image recorded")
+	}
+
+	if _, _, err := dockerClient.GetClient().ImageInspectWithRaw(ctx, record.ImageID); err != nil {
+		return "", fmt.Errorf("image %s not found: %w", record.ImageID, err)
+	}
+
+	return record.ImageID, nil
+}
+
+// redeploys an earlier release with the app's configured strategy, a blue-green standby that still
+// runs the release just gets the traffic back
+func RedeployRelease(ctx context.Context, dockerClient *docker.Client, app *models.Application, record *models.DeploymentRecord) (string, error) {
+	if StandbyRunsRelease(app, record) {
+		if err := SwitchBlueGreen(ctx, dockerClient, app, nil); err != nil {
+			return "", fmt.Errorf("failed to switch to standby environment: %w", err)
+		}
+		return app.ImageID, redeployProcesses(ctx, dockerClient, DeploymentOptions{
+			App:        app,
+			NewImageID: app.ImageID,
+			Config:     app.DeploymentConfig,
+			VPCName:    app.VPC,
+		})
+	}
+
+	image, err := ResolveReleaseImage(ctx, dockerClient, record)
+	if err != nil {
+		return "", err
+	}
+
+	opts, err := rolloutImage(ctx, dockerClient, app, image, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return image, redeployProcesses(ctx, dockerClient, opts)
+}
+
+// rolls app onto its current image with its configured strategy, web and then the other
+// processes, so settings that can only change on new containers reach every instance the way a
+// deploy rolls them out. nothing is built and no release is recorded
+func RolloutCurrentImage(ctx context.Context, dockerClient *docker.Client, app *models.Application, events EventSink) error {
+	if app.ImageID == "" {
+		return fmt.Errorf("application has no image, deploy it first")
+	}
+
+	opts, err := rolloutImage(ctx, dockerClient, app, app.ImageID, events)
+	if err != nil {
+		return err
+	}
+
+	return DeployProcesses(ctx, dockerClient, opts)
+}
+
+// rolls web onto image with the app's strategy, the options it used carry over to the processes
+func rolloutImage(ctx context.Context, dockerClient *docker.Client, app *models.Application, image string, events EventSink) (DeploymentOptions, error) {
+	strategy := app.DeploymentStrategy
+	if strategy == "" {
+		strategy = models.DeploymentStrategyRecreate
+	}
+
+	// the new instances go into the standby slot, so whatever sits there has to go first
+	if strategy == models.DeploymentStrategyBlueGreen {
+		if err := DestroyStandby(ctx, dockerClient, app); err != nil {
+			return DeploymentOptions{}, fmt.Errorf("failed to free standby environment: %w", err)
+		}
+	}
+
+	deployer, err := NewDeployer(strategy)
+	if err != nil {
+		return DeploymentOptions{}, err
+	}
+
+	opts := DeploymentOptions{
+		App:           app,
+		NewImageID:    image,
+		Config:        app.DeploymentConfig,
+		VPCName:       app.VPC,
+		TraefikLabels: router.NewTraefikManager(dockerClient).GenerateLabelsForApp(app),
+		MemoryMB:      app.Memory,
+		CPUCores:      app.CPU,
+		Events:        events,
+	}
+
+	if _, err := deployer.Deploy(ctx, opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// the other processes go back to the release web went back to
+func redeployProcesses(ctx context.Context, dockerClient *docker.Client, opts DeploymentOptions) error {
+	if err := DeployProcesses(ctx, dockerClient, opts); err != nil {
+		return fmt.Errorf("web was redeployed, but %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+)
+
+// applies app's memory and cpu limits to its running instances without restarting them, a
+// blue-green standby included. instances updated before a failure keep the new limits, callers
+// roll the instances instead when the runtime refuses
+func UpdateResources(ctx context.Context, dockerClient *docker.Client, app *models.Application) error {
+	containerIDs := append([]string(nil), app.ContainerIDs...)
+	if HasStandby(app) {
+		containerIDs = append(containerIDs, GetEnvironment(app, app.DeploymentState.Standby).ContainerIDs...)
+	}
+
+	memory := int64(app.Memory) * 1024 * 1024
+	update := dockerTypes.UpdateConfig{
+		Resources: dockerTypes.Resources{
+			Memory: memory,
+			// created without a swap limit, the runtime allowed as much swap as memory. raising memory
+			// past that old limit fails unless swap moves along
+			MemorySwap: memory * 2,
+			NanoCPUs:   int64(app.CPU * 1e9),
+		},
+	}
+
+	for _, containerID := range containerIDs {
+		updateCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+		_, err := dockerClient.GetClient().ContainerUpdate(updateCtx, containerID, update)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", utils.TruncateID(containerID, 12), err)
+		}
+	}
+
+	return nil
+}
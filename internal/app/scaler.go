@@ -3,14 +3,23 @@ package app
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/internal/router"
 	"github.com/aelpxy/yap/pkg/models"
+	"github.com/containerd/errdefs"
 	dockerTypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 )
 
+// adds count instances and waits for each to pass the app's readiness probe. if any of them fails
+// to start or to become ready, every instance this call created is removed again, so the app is
+// left as it was. instance numbers continue after the highest one in use and names carry a random
+// suffix, so they never collide with rolled or leftover containers
 func ScaleUp(
 	dockerClient *docker.Client,
 	app *models.Application,
@@ -20,15 +29,26 @@ func ScaleUp(
 	ctx := context.Background()
 	newContainerIDs := make([]string, 0, count)
 
-	currentCount := len(app.ContainerIDs)
+	rollback := func(err error) ([]string, error) {
+		for _, containerID := range newContainerIDs {
+			dockerClient.GetClient().ContainerRemove(context.Background(), containerID, dockerTypes.RemoveOptions{Force: true})
+		}
+		return nil, fmt.Errorf("%w (removed the %d new instance(s))", err, len(newContainerIDs))
+	}
+
+	firstInstance := highestInstanceNumber(ctx, dockerClient, app.ContainerIDs) + 1
 
 	traefik := router.NewTraefikManager(dockerClient)
 
 	for i := 0; i < count; i++ {
-		instanceNum := currentCount + i + 1
-		containerName := fmt.Sprintf("yap-app-%s-%d", app.Name, instanceNum)
+		instanceNum := firstInstance + i
+		containerName := newInstanceName(app, instanceNum)
 
-		traefikLabels := traefik.GenerateLabelsForApp(app)
+		// only web takes traffic
+		var traefikLabels map[string]string
+		if app.Process == "" {
+			traefikLabels = traefik.GenerateLabelsForApp(app)
+		}
 
 		labels := map[string]string{
 			"yap.managed":      "true",
@@ -54,6 +74,8 @@ func ScaleUp(
 			Labels: labels,
 			Env:    envArray,
 		}
+		applyStopConfig(containerConfig, app)
+		applyProcess(containerConfig, app)
 
 		mounts := prepareVolumeMounts(app.Name, app.Volumes)
 
@@ -83,46 +105,147 @@ func ScaleUp(
 			containerName,
 		)
 		if err != nil {
-			return newContainerIDs, fmt.Errorf("failed to create instance %d: %w", instanceNum, err)
+			return rollback(fmt.Errorf("failed to create instance %d: %w", instanceNum, err))
 		}
+		newContainerIDs = append(newContainerIDs, resp.ID)
 
 		if err := dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
-			return newContainerIDs, fmt.Errorf("failed to start instance %d: %w", instanceNum, err)
+			return rollback(fmt.Errorf("failed to start instance %d: %w", instanceNum, err))
 		}
+	}
 
-		newContainerIDs = append(newContainerIDs, resp.ID)
+	healthTimeout := time.Duration(app.DeploymentConfig.HealthTimeout) * time.Second
+	if healthTimeout == 0 {
+		healthTimeout = 30 * time.Second
+	}
+
+	// the instances start up side by side, so they share one health timeout
+	readyErrs := make([]error, len(newContainerIDs))
+	var wg sync.WaitGroup
+	for i, containerID := range newContainerIDs {
+		wg.Add(1)
+		go func(i int, containerID string) {
+			defer wg.Done()
+			readyErrs[i] = WaitForReady(ctx, dockerClient, app, containerID, healthTimeout)
+		}(i, containerID)
+	}
+	wg.Wait()
+
+	for i, err := range readyErrs {
+		if err != nil {
+			return rollback(fmt.Errorf("instance %d not ready: %w", firstInstance+i, err))
+		}
 	}
 
 	return newContainerIDs, nil
 }
 
+func highestInstanceNumber(ctx context.Context, dockerClient *docker.Client, containerIDs []string) int {
+	highest := len(containerIDs)
+	for _, containerID := range containerIDs {
+		inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+		if err != nil || inspect.Config == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(inspect.Config.Labels["yap.app.instance"]); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// removes count instances, the ones that are gone or stopped first, then the ones failing the
+// readiness probe, then the oldest. returns the instances that are left, in their original order
 func ScaleDown(
 	dockerClient *docker.Client,
 	app *models.Application,
 	count int,
-) error {
-	ctx := context.Background()
-
-	if count >= len(app.ContainerIDs) {
-		return fmt.Errorf("cannot remove %d instances (only %d running, must keep at least 1)", count, len(app.ContainerIDs))
+) ([]string, error) {
+	// web has to keep serving, other processes can be scaled down to nothing
+	if app.Process == "" && count >= len(app.ContainerIDs) {
+		return app.ContainerIDs, fmt.Errorf("cannot remove %d instances (only %d running, must keep at least 1)", count, len(app.ContainerIDs))
+	}
+	if count > len(app.ContainerIDs) {
+		return app.ContainerIDs, fmt.Errorf("cannot remove %d instances (only %d running)", count, len(app.ContainerIDs))
 	}
 
-	toRemove := app.ContainerIDs[len(app.ContainerIDs)-count:]
+	toRemove, keep, missing := pickInstancesToRemove(dockerClient, app, count)
 
+	// a container that no longer exists only has to be forgotten
+	present := make([]string, 0, len(toRemove))
 	for _, containerID := range toRemove {
-		timeout := 10
-		if err := dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
-			Timeout: &timeout,
-		}); err != nil {
-			return fmt.Errorf("failed to stop container: %w", err)
+		if !missing[containerID] {
+			present = append(present, containerID)
 		}
+	}
+
+	if err := DrainAndRemove(dockerClient, app, present, keep, nil); err != nil {
+		return app.ContainerIDs, err
+	}
+	return keep, nil
+}
+
+type removalCandidate struct {
+	containerID string
+	rank        int // 0 gone or stopped, 1 failing its probe, 2 healthy
+	created     time.Time
+}
+
+func pickInstancesToRemove(dockerClient *docker.Client, app *models.Application, count int) ([]string, []string, map[string]bool) {
+	spec := ReadinessProbe(app)
+	probe, probeErr := NewProbe(dockerClient, spec, app.Port)
+
+	missing := make(map[string]bool)
+	candidates := make([]removalCandidate, 0, len(app.ContainerIDs))
+	for _, containerID := range app.ContainerIDs {
+		candidate := removalCandidate{containerID: containerID, rank: 2}
+
+		ctx, cancel := context.WithTimeout(context.Background(), docker.ContainerOpTimeout)
+		inspect, err := dockerClient.GetClient().ContainerInspect(ctx, containerID)
+		switch {
+		case err != nil:
+			candidate.rank = 0
+			missing[containerID] = errdefs.IsNotFound(err)
+		case inspect.State == nil || !inspect.State.Running:
+			candidate.rank = 0
+		case probeErr != nil || ProbeInstance(ctx, dockerClient, app, probe, spec, containerID) != nil:
+			candidate.rank = 1
+		}
+		if err == nil {
+			candidate.created, _ = time.Parse(time.RFC3339Nano, inspect.Created)
+		}
+		cancel()
+
+		candidates = append(candidates, candidate)
+	}
+
+	toRemove, keep := selectRemovals(app.ContainerIDs, candidates, count)
+	return toRemove, keep, missing
+}
+
+// the count instances to remove, gone or stopped first, then failing ones, then the oldest. keep
+// holds the rest in containerIDs order
+func selectRemovals(containerIDs []string, candidates []removalCandidate, count int) ([]string, []string) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		return candidates[i].created.Before(candidates[j].created)
+	})
+
+	removed := make(map[string]bool, count)
+	toRemove := make([]string, 0, count)
+	for _, candidate := range candidates[:count] {
+		removed[candidate.containerID] = true
+		toRemove = append(toRemove, candidate.containerID)
+	}
 
-		if err := dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{
-			Force: true,
-		}); err != nil {
-			return fmt.Errorf("failed to remove container: %w", err)
+	keep := make([]string, 0, len(containerIDs)-count)
+	for _, containerID := range containerIDs {
+		if !removed[containerID] {
+			keep = append(keep, containerID)
 		}
 	}
 
-	return nil
+	return toRemove, keep
 }
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectRemovals(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name       string
+		candidates []removalCandidate
+		count      int
+		wantRemove []string
+		wantKeep   []string
+	}{
+		{
+			name: "oldest healthy instance goes first",
+			candidates: []removalCandidate{
+				{containerID: "a", rank: 2, created: at(2)},
+				{containerID: "b", rank: 2, created: at(1)},
+				{containerID: "c", rank: 2, created: at(3)},
+			},
+			count:      1,
+			wantRemove: []string{"b"},
+			wantKeep:   []string{"a", "c"},
+		},
+		{
+			name: "failing instances go before healthy ones",
+			candidates: []removalCandidate{
+				{containerID: "a", rank: 2, created: at(1)},
+				{containerID: "b", rank: 1, created: at(3)},
+				{containerID: "c", rank: 2, created: at(2)},
+			},
+			count:      1,
+			wantRemove: []string{"b"},
+			wantKeep:   []string{"a", "c"},
+		},
+		{
+			name: "stopped instances go before failing ones",
+			candidates: []removalCandidate{
+				{containerID: "a", rank: 1, created: at(1)},
+				{containerID: "b", rank: 2, created: at(2)},
+				{containerID: "c", rank: 0, created: at(3)},
+				{containerID: "d", rank: 2, created: at(4)},
+			},
+			count:      2,
+			wantRemove: []string{"c", "a"},
+			wantKeep:   []string{"b", "d"},
+		},
+		{
+			name: "instances that can't be inspected keep their order",
+			candidates: []removalCandidate{
+				{containerID: "a", rank: 2, created: at(1)},
+				{containerID: "b", rank: 0},
+				{containerID: "c", rank: 0},
+			},
+			count:      2,
+			wantRemove: []string{"b", "c"},
+			wantKeep:   []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerIDs := make([]string, 0, len(tt.candidates))
+			for _, candidate := range tt.candidates {
+				containerIDs = append(containerIDs, candidate.containerID)
+			}
+
+			toRemove, keep := selectRemovals(containerIDs, tt.candidates, tt.count)
+			if !reflect.DeepEqual(toRemove, tt.wantRemove) {
+				t.Errorf("removed = %q, want %q", toRemove, tt.wantRemove)
+			}
+			if !reflect.DeepEqual(keep, tt.wantKeep) {
+				t.Errorf("kept = %q, want %q", keep, tt.wantKeep)
+			}
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package app
 
 import (
+	"context"
+	"time"
+
+	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/pkg/models"
 )
 
 type DeploymentStrategy interface {
-	Deploy(opts DeploymentOptions) (string, error)
+	Deploy(ctx context.Context, opts DeploymentOptions) (string, error)
 
 	Validate(opts DeploymentOptions) error
 }
@@ -23,4 +27,24 @@ type DeploymentOptions struct {
 
 	MemoryMB int
 	CPUCores float64
+
+	Events EventSink // progress goes to the terminal when nil
+}
+
+// cleanup has to finish even when the deploy was cancelled, so it gets its own deadline
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), docker.ContainerOpTimeout)
+}
+
+// waits for d, returning early with the context's error if it's cancelled first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/router"
 	"github.com/aelpxy/yap/pkg/models"
 	dockerTypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
@@ -26,21 +27,16 @@ func (s *BlueGreenStrategy) Validate(opts DeploymentOptions) error {
 	if opts.NewImageID == "" {
 		return fmt.Errorf("image ID is required")
 	}
-	if opts.App.HealthCheckPath == "" {
-		return fmt.Errorf("blue-green deployment requires health check endpoint")
-	}
 	return nil
 }
 
-func (s *BlueGreenStrategy) Deploy(opts DeploymentOptions) (string, error) {
+func (s *BlueGreenStrategy) Deploy(ctx context.Context, opts DeploymentOptions) (string, error) {
 	var err error
 	s.dockerClient, err = docker.NewClient()
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize docker client: %w", err)
 	}
 
-	ctx := context.Background()
-
 	currentColor := opts.App.DeploymentState.Active
 	if currentColor == "" || currentColor == models.DeploymentColorDefault {
 		currentColor = models.DeploymentColorBlue
@@ -53,69 +49,144 @@ func (s *BlueGreenStrategy) Deploy(opts DeploymentOptions) (string, error) {
 		newColor = models.DeploymentColorBlue
 	}
 
-	fmt.Printf("  --> blue-green deployment\n")
-	fmt.Printf("    current active: %s\n", currentColor)
-	fmt.Printf("    deploying: %s environment (%d instances)\n", newColor, opts.App.Instances)
-	fmt.Println()
+	opts.emit(DeploymentEvent{
+		Type:    EventDeployStarted,
+		Message: fmt.Sprintf("blue-green deployment (active: %s, deploying: %s with %d instances)", currentColor, newColor, opts.App.Instances),
+		Color:   string(newColor),
+	})
 
 	healthTimeout := time.Duration(opts.Config.HealthTimeout) * time.Second
 	if healthTimeout == 0 {
 		healthTimeout = 30 * time.Second
 	}
 
-	fmt.Printf("  --> deploying %s environment...\n", newColor)
+	// with color routing the new color registers its own service and traffic moves over by
+	// rewriting one routing file, older load balancers get the instances relabeled instead
+	traefik := router.NewTraefikManager(s.dockerClient)
+	colorRouted, err := traefik.SupportsWeightedRouting()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect load balancer: %w", err)
+	}
+	var routingLabels map[string]string
+	if colorRouted {
+		routingLabels = traefik.GenerateColorLabels(opts.App, newColor)
+	}
+
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: fmt.Sprintf("deploying %s environment...", newColor), Color: string(newColor)})
 	newContainerIDs := make([]string, 0, opts.App.Instances)
 
 	for i := 1; i <= opts.App.Instances; i++ {
-		fmt.Printf("    [%d/%d] deploying %s-%d...\n", i, opts.App.Instances, newColor, i)
-
-		containerID, err := s.createInstance(ctx, opts, i, newColor, false)
+		containerID, err := s.createInstance(ctx, opts, i, newColor, routingLabels)
 		if err != nil {
-			s.cleanup(ctx, newContainerIDs)
+			s.cleanup(newContainerIDs)
 			return "", fmt.Errorf("failed to create %s instance %d: %w", newColor, i, err)
 		}
 		newContainerIDs = append(newContainerIDs, containerID)
-	}
 
-	fmt.Println()
+		opts.emit(DeploymentEvent{
+			Type:        EventInstanceCreated,
+			Message:     fmt.Sprintf("created %s-%d", newColor, i),
+			Instance:    i,
+			Total:       opts.App.Instances,
+			ContainerID: containerID,
+			Color:       string(newColor),
+		})
+	}
 
-	fmt.Println("  --> waiting for health checks...")
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: "waiting for health checks..."})
 	for i, containerID := range newContainerIDs {
 		instanceNum := i + 1
-		fmt.Printf("    [%d/%d] checking %s-%d (timeout: %ds)...\n", instanceNum, opts.App.Instances, newColor, instanceNum, int(healthTimeout.Seconds()))
+		opts.emit(DeploymentEvent{
+			Type:        EventHealthWaiting,
+			Message:     fmt.Sprintf("checking %s-%d (timeout: %ds)...", newColor, instanceNum, int(healthTimeout.Seconds())),
+			Instance:    instanceNum,
+			Total:       opts.App.Instances,
+			ContainerID: containerID,
+			Color:       string(newColor),
+		})
 
-		if err := s.waitForHealthy(ctx, containerID, healthTimeout); err != nil {
-			fmt.Printf("    [error] %s-%d health check failed: %v\n", newColor, instanceNum, err)
-			s.cleanup(ctx, newContainerIDs)
+		if err := WaitForReady(ctx, s.dockerClient, opts.App, containerID, healthTimeout); err != nil {
+			opts.emit(DeploymentEvent{
+				Type:        EventHealthFailed,
+				Message:     fmt.Sprintf("%s-%d health check failed: %v", newColor, instanceNum, err),
+				Instance:    instanceNum,
+				Total:       opts.App.Instances,
+				ContainerID: containerID,
+				Color:       string(newColor),
+				Error:       err.Error(),
+			})
+			s.cleanup(newContainerIDs)
 			return "", fmt.Errorf("health check failed for %s-%d: %w", newColor, instanceNum, err)
 		}
 
-		fmt.Printf("    [%d/%d] %s-%d healthy\n", instanceNum, opts.App.Instances, newColor, instanceNum)
+		opts.emit(DeploymentEvent{
+			Type:        EventHealthPassed,
+			Message:     fmt.Sprintf("%s-%d healthy", newColor, instanceNum),
+			Instance:    instanceNum,
+			Total:       opts.App.Instances,
+			ContainerID: containerID,
+			Color:       string(newColor),
+		})
 	}
 
-	fmt.Println()
+	// last point the deploy can be called off, a half switched environment is worse than either side
+	if err := ctx.Err(); err != nil {
+		s.cleanup(newContainerIDs)
+		return "", err
+	}
+	switchCtx := context.Background()
 
-	fmt.Printf("  --> switching traffic to %s...\n", newColor)
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: fmt.Sprintf("switching traffic to %s...", newColor), Color: string(newColor)})
 
-	for i, containerID := range newContainerIDs {
-		instanceNum := i + 1
-		if err := s.updateContainerLabels(ctx, containerID, opts, instanceNum, newColor, true); err != nil {
-			fmt.Printf("    [warn] failed to update labels for %s-%d: %v\n", newColor, instanceNum, err)
+	oldEnv := GetEnvironment(opts.App, currentColor)
+	if colorRouted {
+		if err := traefik.SetColorRouting(opts.App, newColor); err != nil {
+			s.cleanup(newContainerIDs)
+			return "", fmt.Errorf("failed to route traffic to %s: %w", newColor, err)
 		}
-	}
-
-	oldEnv := s.getEnvironment(opts.App, currentColor)
-	if oldEnv != nil && len(oldEnv.ContainerIDs) > 0 {
-		for i, containerID := range oldEnv.ContainerIDs {
+	} else {
+		for i, containerID := range newContainerIDs {
 			instanceNum := i + 1
-			if err := s.updateContainerLabels(ctx, containerID, opts, instanceNum, currentColor, false); err != nil {
-				fmt.Printf("    [warn] failed to remove labels from %s-%d: %v\n", currentColor, instanceNum, err)
+			newID, err := RelabelInstance(switchCtx, s.dockerClient, opts.App, containerID, instanceNum, newColor, opts.TraefikLabels)
+			if err != nil {
+				opts.warn("failed to update labels for %s-%d: %v", newColor, instanceNum, err)
+				continue
+			}
+			newContainerIDs[i] = newID
+		}
+
+		if oldEnv != nil && len(oldEnv.ContainerIDs) > 0 {
+			if opts.App.DrainPeriod > 0 {
+				opts.emit(DeploymentEvent{
+					Type:    EventInstanceDraining,
+					Message: fmt.Sprintf("draining %s for %ds...", currentColor, opts.App.DrainPeriod),
+					Color:   string(currentColor),
+				})
+			}
+			restore, err := DrainTraffic(switchCtx, s.dockerClient, opts.App, newContainerIDs)
+			if err != nil {
+				opts.warn("failed to drain connections: %v", err)
+			}
+			defer restore()
+
+			for i, containerID := range oldEnv.ContainerIDs {
+				instanceNum := i + 1
+				newID, err := RelabelInstance(switchCtx, s.dockerClient, opts.App, containerID, instanceNum, currentColor, nil)
+				if err != nil {
+					opts.warn("failed to remove labels from %s-%d: %v", currentColor, instanceNum, err)
+					continue
+				}
+				oldEnv.ContainerIDs[i] = newID
 			}
 		}
 	}
 
-	fmt.Printf("  [done] traffic switched to %s\n", newColor)
-	fmt.Println()
+	opts.emit(DeploymentEvent{
+		Type:    EventTrafficSwitched,
+		Message: fmt.Sprintf("traffic switched to %s", newColor),
+		Color:   string(newColor),
+		Weight:  100,
+	})
 
 	newEnv := &models.Environment{
 		ContainerIDs: newContainerIDs,
@@ -136,9 +207,21 @@ func (s *BlueGreenStrategy) Deploy(opts DeploymentOptions) (string, error) {
 	opts.App.ContainerIDs = newContainerIDs
 
 	if opts.Config.AutoConfirm {
-		fmt.Printf("  --> auto-confirm enabled, destroying %s environment...\n", currentColor)
+		opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: fmt.Sprintf("auto-confirm enabled, destroying %s environment...", currentColor)})
 		if oldEnv != nil && len(oldEnv.ContainerIDs) > 0 {
-			s.cleanup(ctx, oldEnv.ContainerIDs)
+			if err := DrainAndRemove(s.dockerClient, opts.App, oldEnv.ContainerIDs, nil, opts.Events); err != nil {
+				opts.warn("%v", err)
+			}
+			for i, containerID := range oldEnv.ContainerIDs {
+				opts.emit(DeploymentEvent{
+					Type:        EventInstanceRemoved,
+					Message:     fmt.Sprintf("removed %s-%d", currentColor, i+1),
+					Instance:    i + 1,
+					Total:       len(oldEnv.ContainerIDs),
+					ContainerID: containerID,
+					Color:       string(currentColor),
+				})
+			}
 			if currentColor == models.DeploymentColorBlue {
 				opts.App.DeploymentState.Blue = nil
 			} else {
@@ -146,24 +229,19 @@ func (s *BlueGreenStrategy) Deploy(opts DeploymentOptions) (string, error) {
 			}
 			opts.App.DeploymentState.Standby = ""
 		}
-		fmt.Printf("  [done] %s environment destroyed\n", currentColor)
+		opts.emit(DeploymentEvent{Type: EventPhaseCompleted, Message: fmt.Sprintf("%s environment destroyed", currentColor), Color: string(currentColor)})
 	} else {
-		fmt.Printf("  %s environment kept for rollback\n", currentColor)
-		fmt.Println()
-		fmt.Println("  to complete deployment:")
-		fmt.Printf("    yap app deployment confirm %s\n", opts.App.Name)
-		fmt.Println()
-		fmt.Println("  to rollback:")
-		fmt.Printf("    yap app deployment rollback %s\n", opts.App.Name)
+		// the deploy command tells the operator how to confirm, roll back or switch
+		opts.emit(DeploymentEvent{Type: EventPhaseCompleted, Message: fmt.Sprintf("%s environment kept for rollback", currentColor), Color: string(currentColor)})
 	}
 
-	fmt.Println()
-	fmt.Printf("  [done] blue-green deployment completed\n")
+	opts.emit(DeploymentEvent{Type: EventDeployCompleted, Message: "blue-green deployment completed"})
 
 	return opts.NewImageID, nil
 }
 
-func (s *BlueGreenStrategy) createInstance(ctx context.Context, opts DeploymentOptions, instanceNum int, color models.DeploymentColor, withTraefikLabels bool) (string, error) {
+// routingLabels are the color's routing labels, nil leaves the instance out of routing until it's relabeled
+func (s *BlueGreenStrategy) createInstance(ctx context.Context, opts DeploymentOptions, instanceNum int, color models.DeploymentColor, routingLabels map[string]string) (string, error) {
 	containerName := fmt.Sprintf("yap-app-%s-%s-%d", opts.App.Name, color, instanceNum)
 
 	labels := map[string]string{
@@ -176,10 +254,8 @@ func (s *BlueGreenStrategy) createInstance(ctx context.Context, opts DeploymentO
 		"yap.app.color":    string(color),
 	}
 
-	if withTraefikLabels {
-		for k, v := range opts.TraefikLabels {
-			labels[k] = v
-		}
+	for k, v := range routingLabels {
+		labels[k] = v
 	}
 
 	envVars := make(map[string]string)
@@ -194,6 +270,8 @@ func (s *BlueGreenStrategy) createInstance(ctx context.Context, opts DeploymentO
 		Labels: labels,
 		Env:    envArray,
 	}
+	applyStopConfig(containerConfig, opts.App)
+	applyProcess(containerConfig, opts.App)
 
 	mounts := prepareVolumeMounts(opts.App.Name, opts.App.Volumes)
 
@@ -215,8 +293,11 @@ func (s *BlueGreenStrategy) createInstance(ctx context.Context, opts DeploymentO
 		},
 	}
 
+	opCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+	defer cancel()
+
 	resp, err := s.dockerClient.GetClient().ContainerCreate(
-		ctx,
+		opCtx,
 		containerConfig,
 		hostConfig,
 		networkConfig,
@@ -227,131 +308,18 @@ func (s *BlueGreenStrategy) createInstance(ctx context.Context, opts DeploymentO
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
-	if err := s.dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
-		s.dockerClient.GetClient().ContainerRemove(ctx, resp.ID, dockerTypes.RemoveOptions{Force: true})
+	if err := s.dockerClient.GetClient().ContainerStart(opCtx, resp.ID, dockerTypes.StartOptions{}); err != nil {
+		s.cleanup([]string{resp.ID})
 		return "", fmt.Errorf("failed to start container: %w", err)
 	}
 
 	return resp.ID, nil
 }
 
-func (s *BlueGreenStrategy) updateContainerLabels(ctx context.Context, containerID string, opts DeploymentOptions, instanceNum int, color models.DeploymentColor, addTraefikLabels bool) error {
-	inspect, err := s.dockerClient.GetClient().ContainerInspect(ctx, containerID)
-	if err != nil {
-		return fmt.Errorf("failed to inspect container: %w", err)
-	}
-
-	labels := map[string]string{
-		"yap.managed":      "true",
-		"yap.type":         "app",
-		"yap.app.name":     opts.App.Name,
-		"yap.app.id":       opts.App.ID,
-		"yap.vpc":          opts.VPCName,
-		"yap.app.instance": fmt.Sprintf("%d", instanceNum),
-		"yap.app.color":    string(color),
-	}
-
-	if addTraefikLabels {
-		for k, v := range opts.TraefikLabels {
-			labels[k] = v
-		}
-	}
-
-	timeout := 10
-	if err := s.dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
-		Timeout: &timeout,
-	}); err != nil {
-		return fmt.Errorf("failed to stop container: %w", err)
-	}
-
-	if err := s.dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{}); err != nil {
-		return fmt.Errorf("failed to remove container: %w", err)
-	}
-
-	containerName := fmt.Sprintf("yap-app-%s-%s-%d", opts.App.Name, color, instanceNum)
-
-	containerConfig := &dockerTypes.Config{
-		Image:  inspect.Config.Image,
-		Labels: labels,
-		Env:    inspect.Config.Env,
-	}
-
-	hostConfig := inspect.HostConfig
-
-	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", opts.VPCName)
-	networkConfig := &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			vpcNetworkName: {},
-		},
-	}
-
-	resp, err := s.dockerClient.GetClient().ContainerCreate(
-		ctx,
-		containerConfig,
-		hostConfig,
-		networkConfig,
-		nil,
-		containerName,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to recreate container: %w", err)
-	}
-
-	if err := s.dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
-		return fmt.Errorf("failed to start container: %w", err)
-	}
-
-	return nil
-}
-
-func (s *BlueGreenStrategy) getEnvironment(app *models.Application, color models.DeploymentColor) *models.Environment {
-	if color == models.DeploymentColorBlue {
-		return app.DeploymentState.Blue
-	} else if color == models.DeploymentColorGreen {
-		return app.DeploymentState.Green
-	}
-	return nil
-}
-
-func (s *BlueGreenStrategy) waitForHealthy(ctx context.Context, containerID string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			inspect, err := s.dockerClient.GetClient().ContainerInspect(ctx, containerID)
-			if err != nil {
-				return fmt.Errorf("failed to inspect container: %w", err)
-			}
-
-			if !inspect.State.Running {
-				return fmt.Errorf("container stopped unexpectedly")
-			}
-
-			startedAt, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
-			if err != nil {
-				if inspect.State.Running {
-					return nil
-				}
-			}
-
-			if inspect.State.Running && time.Since(startedAt) > 5*time.Second {
-				return nil
-			}
-
-			if time.Now().After(deadline) {
-				return fmt.Errorf("health check timeout exceeded")
-			}
-
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-	}
-}
+func (s *BlueGreenStrategy) cleanup(containerIDs []string) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
 
-func (s *BlueGreenStrategy) cleanup(ctx context.Context, containerIDs []string) {
 	for _, id := range containerIDs {
 		s.dockerClient.GetClient().ContainerRemove(ctx, id, dockerTypes.RemoveOptions{
 			Force: true,
@@ -0,0 +1,422 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/internal/router"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/lucsky/cuid"
+)
+
+var defaultCanarySteps = []int{10, 50, 100}
+
+// how often canary instances are probed while a step is observed
+const canaryObserveInterval = 5 * time.Second
+
+type CanaryStrategy struct {
+	dockerClient *docker.Client
+	traefik      *router.TraefikManager
+}
+
+func NewCanaryStrategy() *CanaryStrategy {
+	return &CanaryStrategy{}
+}
+
+func (s *CanaryStrategy) Validate(opts DeploymentOptions) error {
+	if opts.App == nil {
+		return fmt.Errorf("application configuration is required")
+	}
+	if opts.NewImageID == "" {
+		return fmt.Errorf("image ID is required")
+	}
+	if len(opts.Config.CanarySteps) > 0 {
+		if err := models.ValidateCanarySteps(opts.Config.CanarySteps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CanaryStrategy) Deploy(ctx context.Context, opts DeploymentOptions) (string, error) {
+	var err error
+	s.dockerClient, err = docker.NewClient()
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize docker client: %w", err)
+	}
+	s.traefik = router.NewTraefikManager(s.dockerClient)
+
+	healthTimeout := time.Duration(opts.Config.HealthTimeout) * time.Second
+	if healthTimeout == 0 {
+		healthTimeout = 30 * time.Second
+	}
+
+	if len(opts.App.ContainerIDs) == 0 {
+		opts.emit(DeploymentEvent{Type: EventDeployStarted, Message: "no running release to compare against, deploying all instances"})
+		containerIDs, err := s.promote(ctx, opts, nil, healthTimeout)
+		if err != nil {
+			return "", err
+		}
+		opts.App.ContainerIDs = containerIDs
+		opts.emit(DeploymentEvent{Type: EventDeployCompleted, Message: "canary deployment completed"})
+		return opts.NewImageID, nil
+	}
+
+	supported, err := s.traefik.SupportsWeightedRouting()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect load balancer: %w", err)
+	}
+	if !supported {
+		return "", fmt.Errorf("load balancer does not support weighted routing (created by an older yap), remove the yap-traefik container so the next deploy recreates it")
+	}
+
+	steps := opts.Config.CanarySteps
+	if len(steps) == 0 {
+		steps = defaultCanarySteps
+	}
+
+	interval := time.Duration(opts.Config.CanaryInterval) * time.Second
+	if interval == 0 {
+		interval = 60 * time.Second
+	}
+
+	canaryCount := opts.Config.CanaryInstances
+	if canaryCount < 1 {
+		canaryCount = 1
+	}
+
+	opts.emit(DeploymentEvent{
+		Type:    EventDeployStarted,
+		Message: fmt.Sprintf("canary deployment (%d canary instance(s), steps: %v, interval: %ds)", canaryCount, steps, int(interval.Seconds())),
+	})
+
+	canaryIDs := make([]string, 0, canaryCount)
+	for i := 1; i <= canaryCount; i++ {
+		containerID, err := s.createInstance(ctx, opts, i, true)
+		if err != nil {
+			s.cleanup(canaryIDs)
+			return "", fmt.Errorf("failed to create canary instance %d: %w", i, err)
+		}
+		canaryIDs = append(canaryIDs, containerID)
+
+		opts.emit(DeploymentEvent{
+			Type:        EventInstanceCreated,
+			Message:     fmt.Sprintf("created canary-%d", i),
+			Instance:    i,
+			Total:       canaryCount,
+			ContainerID: containerID,
+		})
+	}
+
+	for i, containerID := range canaryIDs {
+		opts.emit(DeploymentEvent{
+			Type:        EventHealthWaiting,
+			Message:     fmt.Sprintf("waiting for canary-%d health check (timeout: %ds)...", i+1, int(healthTimeout.Seconds())),
+			Instance:    i + 1,
+			Total:       canaryCount,
+			ContainerID: containerID,
+		})
+		if err := WaitForReady(ctx, s.dockerClient, opts.App, containerID, healthTimeout); err != nil {
+			opts.emit(DeploymentEvent{
+				Type:        EventHealthFailed,
+				Message:     fmt.Sprintf("canary-%d health check failed: %v", i+1, err),
+				Instance:    i + 1,
+				Total:       canaryCount,
+				ContainerID: containerID,
+				Error:       err.Error(),
+			})
+			s.cleanup(canaryIDs)
+			return "", fmt.Errorf("health check failed for canary-%d: %w", i+1, err)
+		}
+		opts.emit(DeploymentEvent{
+			Type:        EventHealthPassed,
+			Message:     fmt.Sprintf("canary-%d healthy", i+1),
+			Instance:    i + 1,
+			Total:       canaryCount,
+			ContainerID: containerID,
+		})
+	}
+
+	for _, weight := range steps {
+		if err := s.traefik.SetWeightedRouting(opts.App, weight); err != nil {
+			s.abort(opts, canaryIDs)
+			return "", fmt.Errorf("failed to shift traffic: %w", err)
+		}
+		opts.emit(DeploymentEvent{
+			Type:    EventTrafficSwitched,
+			Message: fmt.Sprintf("routing %d%% of traffic to canary", weight),
+			Weight:  weight,
+		})
+
+		if weight == 100 {
+			break
+		}
+
+		opts.emit(DeploymentEvent{Type: EventWaiting, Message: fmt.Sprintf("observing canary for %ds...", int(interval.Seconds()))})
+		if err := s.observe(ctx, canaryIDs, opts, interval); err != nil {
+			opts.emit(DeploymentEvent{
+				Type:    EventHealthFailed,
+				Message: fmt.Sprintf("canary unhealthy at %d%%: %v", weight, err),
+				Weight:  weight,
+				Error:   err.Error(),
+			})
+			s.abort(opts, canaryIDs)
+			return "", fmt.Errorf("canary failed at %d%% traffic: %w", weight, err)
+		}
+		opts.emit(DeploymentEvent{
+			Type:    EventHealthPassed,
+			Message: fmt.Sprintf("canary healthy at %d%%", weight),
+			Weight:  weight,
+		})
+	}
+
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: "promoting canary release..."})
+	containerIDs, err := s.promote(ctx, opts, opts.App.ContainerIDs, healthTimeout)
+	if err != nil {
+		s.abort(opts, canaryIDs)
+		return "", err
+	}
+
+	if err := s.traefik.ClearWeightedRouting(opts.App.Name); err != nil {
+		opts.warn("%v", err)
+	}
+
+	if err := DrainAndRemove(s.dockerClient, opts.App, canaryIDs, containerIDs, opts.Events); err != nil {
+		opts.warn("failed to remove canary instances: %v", err)
+	}
+	for i, containerID := range canaryIDs {
+		opts.emit(DeploymentEvent{
+			Type:        EventInstanceRemoved,
+			Message:     fmt.Sprintf("removed canary-%d", i+1),
+			Instance:    i + 1,
+			Total:       len(canaryIDs),
+			ContainerID: containerID,
+		})
+	}
+	opts.emit(DeploymentEvent{Type: EventPhaseCompleted, Message: "canary instances removed"})
+
+	opts.App.ContainerIDs = containerIDs
+
+	opts.emit(DeploymentEvent{Type: EventDeployCompleted, Message: "canary deployment completed"})
+
+	return opts.NewImageID, nil
+}
+
+// replaces the stable instances with the full set of new ones, while the weighted router
+// still sends everything to the canary so the stable service can be swapped underneath it
+func (s *CanaryStrategy) promote(ctx context.Context, opts DeploymentOptions, oldContainerIDs []string, healthTimeout time.Duration) ([]string, error) {
+	newContainerIDs := make([]string, 0, opts.App.Instances)
+
+	for i := 1; i <= opts.App.Instances; i++ {
+		containerID, err := s.createInstance(ctx, opts, i, false)
+		if err != nil {
+			s.cleanup(newContainerIDs)
+			return nil, fmt.Errorf("failed to create instance %d: %w", i, err)
+		}
+		newContainerIDs = append(newContainerIDs, containerID)
+
+		opts.emit(DeploymentEvent{
+			Type:        EventInstanceCreated,
+			Message:     fmt.Sprintf("created instance %d", i),
+			Instance:    i,
+			Total:       opts.App.Instances,
+			ContainerID: containerID,
+		})
+	}
+
+	for i, containerID := range newContainerIDs {
+		if err := WaitForReady(ctx, s.dockerClient, opts.App, containerID, healthTimeout); err != nil {
+			opts.emit(DeploymentEvent{
+				Type:        EventHealthFailed,
+				Message:     fmt.Sprintf("instance %d health check failed: %v", i+1, err),
+				Instance:    i + 1,
+				Total:       opts.App.Instances,
+				ContainerID: containerID,
+				Error:       err.Error(),
+			})
+			s.cleanup(newContainerIDs)
+			return nil, fmt.Errorf("health check failed for instance %d: %w", i+1, err)
+		}
+		opts.emit(DeploymentEvent{
+			Type:        EventHealthPassed,
+			Message:     "healthy",
+			Instance:    i + 1,
+			Total:       opts.App.Instances,
+			ContainerID: containerID,
+		})
+	}
+
+	// the new instances are healthy, so the old ones go even if the deploy was cancelled meanwhile.
+	// the canary has all the traffic by now, there's nothing to drain
+	if err := DrainAndRemove(s.dockerClient, opts.App, oldContainerIDs, nil, opts.Events); err != nil {
+		opts.warn("failed to remove old instances: %v", err)
+	}
+
+	for i, containerID := range oldContainerIDs {
+		opts.emit(DeploymentEvent{
+			Type:        EventInstanceRemoved,
+			Message:     fmt.Sprintf("removed old instance %d", i+1),
+			Instance:    i + 1,
+			Total:       len(oldContainerIDs),
+			ContainerID: containerID,
+		})
+	}
+
+	return newContainerIDs, nil
+}
+
+// hands all traffic back to the stable instances and throws the canary away
+func (s *CanaryStrategy) abort(opts DeploymentOptions, canaryIDs []string) {
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: "reverting traffic to stable release..."})
+	if err := s.traefik.ClearWeightedRouting(opts.App.Name); err != nil {
+		opts.warn("%v", err)
+	}
+	if err := DrainAndRemove(s.dockerClient, opts.App, canaryIDs, opts.App.ContainerIDs, opts.Events); err != nil {
+		opts.warn("failed to remove canary instances: %v", err)
+	}
+	opts.emit(DeploymentEvent{Type: EventTrafficSwitched, Message: "canary removed, stable release serving all traffic"})
+}
+
+func (s *CanaryStrategy) observe(ctx context.Context, containerIDs []string, opts DeploymentOptions, duration time.Duration) error {
+	spec := LivenessProbe(opts.App)
+	probe, err := NewProbe(s.dockerClient, spec, opts.App.Port)
+	if err != nil {
+		return err
+	}
+	failures := make(map[string]int, len(containerIDs))
+
+	deadline := time.Now().Add(duration)
+	ticker := time.NewTicker(observeTick(duration))
+	defer ticker.Stop()
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ticker.C:
+			for i, containerID := range containerIDs {
+				err := ProbeInstance(ctx, s.dockerClient, opts.App, probe, spec, containerID)
+				if err == nil {
+					failures[containerID] = 0
+					continue
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				failures[containerID]++
+				if isInstanceDown(err) || failures[containerID] >= probeRetries(spec) {
+					return fmt.Errorf("canary-%d: %w", i+1, err)
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// steps shorter than the observe interval still get probed before they end
+func observeTick(duration time.Duration) time.Duration {
+	if duration > 0 && duration < canaryObserveInterval {
+		return duration
+	}
+	return canaryObserveInterval
+}
+
+func (s *CanaryStrategy) createInstance(ctx context.Context, opts DeploymentOptions, instanceNum int, canary bool) (string, error) {
+	containerName := newInstanceName(opts.App, instanceNum)
+	if canary {
+		containerName = fmt.Sprintf("%s-canary-%d-%s", instanceNamePrefix(opts.App), instanceNum, cuid.Slug())
+	}
+
+	labels := map[string]string{
+		"yap.managed":      "true",
+		"yap.type":         "app",
+		"yap.app.name":     opts.App.Name,
+		"yap.app.id":       opts.App.ID,
+		"yap.vpc":          opts.VPCName,
+		"yap.app.instance": fmt.Sprintf("%d", instanceNum),
+	}
+	if canary {
+		labels["yap.app.canary"] = "true"
+		for k, v := range s.traefik.GenerateCanaryLabels(opts.App) {
+			labels[k] = v
+		}
+	} else {
+		for k, v := range opts.TraefikLabels {
+			labels[k] = v
+		}
+	}
+
+	envVars := make(map[string]string)
+	for k, v := range opts.App.EnvVars {
+		envVars[k] = v
+	}
+	InjectMetadata(envVars, opts.App.ID, instanceNum, "local")
+	envArray := BuildEnvArray(envVars)
+
+	containerConfig := &dockerTypes.Config{
+		Image:  opts.NewImageID,
+		Labels: labels,
+		Env:    envArray,
+	}
+	applyStopConfig(containerConfig, opts.App)
+	applyProcess(containerConfig, opts.App)
+
+	mounts := prepareVolumeMounts(opts.App.Name, opts.App.Volumes)
+
+	hostConfig := &dockerTypes.HostConfig{
+		RestartPolicy: dockerTypes.RestartPolicy{
+			Name: "unless-stopped",
+		},
+		Resources: dockerTypes.Resources{
+			Memory:   int64(opts.MemoryMB) * 1024 * 1024,
+			NanoCPUs: int64(opts.CPUCores * 1e9),
+		},
+		Mounts: mounts,
+	}
+
+	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", opts.VPCName)
+	networkConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			vpcNetworkName: {},
+		},
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+	defer cancel()
+
+	resp, err := s.dockerClient.GetClient().ContainerCreate(
+		opCtx,
+		containerConfig,
+		hostConfig,
+		networkConfig,
+		nil,
+		containerName,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+
+	if err := s.dockerClient.GetClient().ContainerStart(opCtx, resp.ID, dockerTypes.StartOptions{}); err != nil {
+		s.cleanup([]string{resp.ID})
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	return resp.ID, nil
+}
+
+func (s *CanaryStrategy) cleanup(containerIDs []string) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+
+	for _, id := range containerIDs {
+		s.dockerClient.GetClient().ContainerRemove(ctx, id, dockerTypes.RemoveOptions{
+			Force: true,
+		})
+	}
+}
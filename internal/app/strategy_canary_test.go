@@ -0,0 +1,38 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserveTick(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		want     time.Duration
+	}{
+		{
+			name:     "long steps probe at the interval",
+			duration: time.Minute,
+			want:     canaryObserveInterval,
+		},
+		{
+			name:     "short steps probe before they end",
+			duration: 2 * time.Second,
+			want:     2 * time.Second,
+		},
+		{
+			name:     "no duration probes at the interval",
+			duration: 0,
+			want:     canaryObserveInterval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := observeTick(tt.duration); got != tt.want {
+				t.Errorf("observeTick(%v) = %v, want %v", tt.duration, got, tt.want)
+			}
+		})
+	}
+}
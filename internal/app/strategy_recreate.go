@@ -10,6 +10,9 @@ import (
 	"github.com/docker/docker/api/types/network"
 )
 
+// how long instances get to start when the app has no readiness probe configured
+const recreateStartGrace = 2 * time.Second
+
 type RecreateStrategy struct {
 	dockerClient *docker.Client
 }
@@ -28,58 +31,115 @@ func (s *RecreateStrategy) Validate(opts DeploymentOptions) error {
 	return nil
 }
 
-func (s *RecreateStrategy) Deploy(opts DeploymentOptions) (string, error) {
+func (s *RecreateStrategy) Deploy(ctx context.Context, opts DeploymentOptions) (string, error) {
 	var err error
 	s.dockerClient, err = docker.NewClient()
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize docker client: %w", err)
 	}
 
-	ctx := context.Background()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	opts.emit(DeploymentEvent{
+		Type:    EventDeployStarted,
+		Message: fmt.Sprintf("recreate deployment (%d instances)", opts.App.Instances),
+	})
 
 	if len(opts.App.ContainerIDs) > 0 {
-		fmt.Println("  --> stopping old instances...")
-		for _, containerID := range opts.App.ContainerIDs {
-			timeout := 10
-			if err := s.dockerClient.GetClient().ContainerStop(ctx, containerID, dockerTypes.StopOptions{
-				Timeout: &timeout,
-			}); err != nil {
-				fmt.Printf("    [warn] failed to stop container %s: %v\n", containerID[:12], err)
-			}
-
-			if err := s.dockerClient.GetClient().ContainerRemove(ctx, containerID, dockerTypes.RemoveOptions{
-				Force: true,
-			}); err != nil {
-				fmt.Printf("    [warn] failed to remove container %s: %v\n", containerID[:12], err)
-			}
+		opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: "stopping old instances..."})
+		// nothing is left to drain to, the old instances just get their stop signal and grace period
+		oldContainerIDs := opts.App.ContainerIDs
+		if err := DrainAndRemove(s.dockerClient, opts.App, oldContainerIDs, nil, opts.Events); err != nil {
+			opts.warn("%v", err)
 		}
-		fmt.Println("  [done] old instances stopped")
+		for i, containerID := range oldContainerIDs {
+			opts.emit(DeploymentEvent{
+				Type:        EventInstanceRemoved,
+				Message:     fmt.Sprintf("removed old instance %d", i+1),
+				Instance:    i + 1,
+				Total:       len(oldContainerIDs),
+				ContainerID: containerID,
+			})
+		}
+		opts.emit(DeploymentEvent{Type: EventPhaseCompleted, Message: "old instances stopped"})
 	}
 
-	fmt.Println("  --> deploying instances...")
+	// the old instances are gone from here on, a failure leaves the app with nothing running
+	opts.App.ContainerIDs = nil
+
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: "deploying instances..."})
 
 	containerIDs := make([]string, 0, opts.App.Instances)
 
 	for i := 1; i <= opts.App.Instances; i++ {
 		containerID, err := s.createInstance(ctx, opts, i)
 		if err != nil {
-			s.cleanup(ctx, containerIDs)
+			s.cleanup(containerIDs)
 			return "", fmt.Errorf("failed to create instance %d: %w", i, err)
 		}
 		containerIDs = append(containerIDs, containerID)
+
+		opts.emit(DeploymentEvent{
+			Type:        EventInstanceCreated,
+			Message:     fmt.Sprintf("created instance %d", i),
+			Instance:    i,
+			Total:       opts.App.Instances,
+			ContainerID: containerID,
+		})
 	}
 
 	opts.App.ContainerIDs = containerIDs
 
-	fmt.Println("  [done] instances deployed")
+	opts.emit(DeploymentEvent{Type: EventPhaseCompleted, Message: "instances deployed"})
 
-	time.Sleep(2 * time.Second)
+	// recreate used to only give instances a moment to start, apps that never configured a
+	// readiness probe keep that rather than failing on a /health endpoint they don't have
+	if !ReadinessConfigured(opts.App) {
+		if err := sleepContext(ctx, recreateStartGrace); err != nil {
+			return "", err
+		}
+		opts.emit(DeploymentEvent{Type: EventDeployCompleted, Message: "recreate deployment completed"})
+		return opts.NewImageID, nil
+	}
+
+	healthTimeout := time.Duration(opts.Config.HealthTimeout) * time.Second
+	if healthTimeout == 0 {
+		healthTimeout = 30 * time.Second
+	}
+
+	// the old instances are gone, so on a redeploy instances that never become ready stay up for debugging
+	opts.emit(DeploymentEvent{Type: EventPhaseStarted, Message: "waiting for health checks..."})
+	for i, containerID := range containerIDs {
+		if err := WaitForReady(ctx, s.dockerClient, opts.App, containerID, healthTimeout); err != nil {
+			opts.emit(DeploymentEvent{
+				Type:        EventHealthFailed,
+				Message:     fmt.Sprintf("instance %d health check failed: %v", i+1, err),
+				Instance:    i + 1,
+				Total:       len(containerIDs),
+				ContainerID: containerID,
+				Error:       err.Error(),
+			})
+			return "", fmt.Errorf("instance %d not ready: %w", i+1, err)
+		}
+
+		opts.emit(DeploymentEvent{
+			Type:        EventHealthPassed,
+			Message:     "healthy",
+			Instance:    i + 1,
+			Total:       len(containerIDs),
+			ContainerID: containerID,
+		})
+	}
+
+	opts.emit(DeploymentEvent{Type: EventDeployCompleted, Message: "recreate deployment completed"})
 
 	return opts.NewImageID, nil
 }
 
 func (s *RecreateStrategy) createInstance(ctx context.Context, opts DeploymentOptions, instanceNum int) (string, error) {
-	containerName := fmt.Sprintf("yap-app-%s-%d", opts.App.Name, instanceNum)
+	containerName := newInstanceName(opts.App, instanceNum)
 
 	labels := map[string]string{
 		"yap.managed":      "true",
@@ -105,6 +165,8 @@ func (s *RecreateStrategy) createInstance(ctx context.Context, opts DeploymentOp
 		Labels: labels,
 		Env:    envArray,
 	}
+	applyStopConfig(containerConfig, opts.App)
+	applyProcess(containerConfig, opts.App)
 
 	mounts := prepareVolumeMounts(opts.App.Name, opts.App.Volumes)
 
@@ -126,8 +188,11 @@ func (s *RecreateStrategy) createInstance(ctx context.Context, opts DeploymentOp
 		},
 	}
 
+	opCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+	defer cancel()
+
 	resp, err := s.dockerClient.GetClient().ContainerCreate(
-		ctx,
+		opCtx,
 		containerConfig,
 		hostConfig,
 		networkConfig,
@@ -138,15 +203,18 @@ func (s *RecreateStrategy) createInstance(ctx context.Context, opts DeploymentOp
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
-	if err := s.dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
-		s.dockerClient.GetClient().ContainerRemove(ctx, resp.ID, dockerTypes.RemoveOptions{Force: true})
+	if err := s.dockerClient.GetClient().ContainerStart(opCtx, resp.ID, dockerTypes.StartOptions{}); err != nil {
+		s.cleanup([]string{resp.ID})
 		return "", fmt.Errorf("failed to start container: %w", err)
 	}
 
 	return resp.ID, nil
 }
 
-func (s *RecreateStrategy) cleanup(ctx context.Context, containerIDs []string) {
+func (s *RecreateStrategy) cleanup(containerIDs []string) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+
 	for _, id := range containerIDs {
 		s.dockerClient.GetClient().ContainerRemove(ctx, id, dockerTypes.RemoveOptions{
 			Force: true,
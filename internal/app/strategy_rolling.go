@@ -3,7 +3,6 @@ package app
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/aelpxy/yap/internal/docker"
@@ -26,24 +25,19 @@ func (s *RollingStrategy) Validate(opts DeploymentOptions) error {
 	if opts.NewImageID == "" {
 		return fmt.Errorf("image ID is required")
 	}
-	if opts.App.HealthCheckPath == "" {
-		return fmt.Errorf("rolling deployment requires health check endpoint")
-	}
 	if opts.Config.MaxSurge < 1 {
 		return fmt.Errorf("max_surge must be at least 1")
 	}
 	return nil
 }
 
-func (s *RollingStrategy) Deploy(opts DeploymentOptions) (string, error) {
+func (s *RollingStrategy) Deploy(ctx context.Context, opts DeploymentOptions) (string, error) {
 	var err error
 	s.dockerClient, err = docker.NewClient()
 	if err != nil {
 		return "", fmt.Errorf("failed to initialize docker client: %w", err)
 	}
 
-	ctx := context.Background()
-
 	currentInstances := opts.App.ContainerIDs
 	targetInstances := opts.App.Instances
 	maxSurge := opts.Config.MaxSurge
@@ -51,8 +45,10 @@ func (s *RollingStrategy) Deploy(opts DeploymentOptions) (string, error) {
 		maxSurge = 1
 	}
 
-	fmt.Printf("  --> rolling deployment (%d instances, max surge: %d)\n", targetInstances, maxSurge)
-	fmt.Println()
+	opts.emit(DeploymentEvent{
+		Type:    EventDeployStarted,
+		Message: fmt.Sprintf("rolling deployment (%d instances, max surge: %d)", targetInstances, maxSurge),
+	})
 
 	rollingInterval := time.Duration(opts.Config.RollingInterval) * time.Second
 	if rollingInterval == 0 {
@@ -75,82 +71,112 @@ func (s *RollingStrategy) Deploy(opts DeploymentOptions) (string, error) {
 		batchContainerIDs := make([]string, 0, batchSize)
 		for j := 0; j < batchSize; j++ {
 			instanceNum := i + j + 1
-			fmt.Printf("    [%d/%d] deploying instance %d...\n", instanceNum, targetInstances, instanceNum)
-
 			containerID, err := s.createInstance(ctx, opts, instanceNum)
 			if err != nil {
-				s.cleanup(ctx, newContainerIDs)
-				s.cleanup(ctx, batchContainerIDs)
+				s.abort(opts, currentInstances, newContainerIDs, batchContainerIDs)
 				return "", fmt.Errorf("failed to create instance %d: %w", instanceNum, err)
 			}
 			batchContainerIDs = append(batchContainerIDs, containerID)
+
+			opts.emit(DeploymentEvent{
+				Type:        EventInstanceCreated,
+				Message:     fmt.Sprintf("created instance %d", instanceNum),
+				Instance:    instanceNum,
+				Total:       targetInstances,
+				ContainerID: containerID,
+			})
 		}
 
 		for j, containerID := range batchContainerIDs {
 			instanceNum := i + j + 1
-			fmt.Printf("    [%d/%d] waiting for health check (timeout: %ds)...\n", instanceNum, targetInstances, int(healthTimeout.Seconds()))
-
-			if err := s.waitForHealthy(ctx, containerID, opts, healthTimeout); err != nil {
-				fmt.Printf("    [error] instance %d health check failed: %v\n", instanceNum, err)
-				s.cleanup(ctx, newContainerIDs)
-				s.cleanup(ctx, batchContainerIDs)
+			opts.emit(DeploymentEvent{
+				Type:        EventHealthWaiting,
+				Message:     fmt.Sprintf("waiting for health check (timeout: %ds)...", int(healthTimeout.Seconds())),
+				Instance:    instanceNum,
+				Total:       targetInstances,
+				ContainerID: containerID,
+			})
+
+			if err := WaitForReady(ctx, s.dockerClient, opts.App, containerID, healthTimeout); err != nil {
+				opts.emit(DeploymentEvent{
+					Type:        EventHealthFailed,
+					Message:     fmt.Sprintf("instance %d health check failed: %v", instanceNum, err),
+					Instance:    instanceNum,
+					Total:       targetInstances,
+					ContainerID: containerID,
+					Error:       err.Error(),
+				})
+				s.abort(opts, currentInstances, newContainerIDs, batchContainerIDs)
 				return "", fmt.Errorf("health check failed for instance %d: %w", instanceNum, err)
 			}
 
+			message := "healthy! (new instance)"
 			if i+j < len(currentInstances) {
-				fmt.Printf("    [%d/%d] healthy! replacing old instance %d\n", instanceNum, targetInstances, instanceNum)
-			} else {
-				fmt.Printf("    [%d/%d] healthy! (new instance)\n", instanceNum, targetInstances)
+				message = fmt.Sprintf("healthy! replacing old instance %d", instanceNum)
 			}
+			opts.emit(DeploymentEvent{
+				Type:        EventHealthPassed,
+				Message:     message,
+				Instance:    instanceNum,
+				Total:       targetInstances,
+				ContainerID: containerID,
+			})
 		}
 
-		if len(currentInstances) > 0 {
-			for j := 0; j < batchSize; j++ {
-				if i+j < len(currentInstances) {
-					instanceNum := i + j + 1
-					oldContainerID := currentInstances[i+j]
-
-					timeout := 10
-					if err := s.dockerClient.GetClient().ContainerStop(ctx, oldContainerID, dockerTypes.StopOptions{
-						Timeout: &timeout,
-					}); err != nil {
-						fmt.Printf("    [warn] failed to stop old instance %d: %v\n", instanceNum, err)
-					}
-
-					if err := s.dockerClient.GetClient().ContainerRemove(ctx, oldContainerID, dockerTypes.RemoveOptions{
-						Force: true,
-					}); err != nil {
-						fmt.Printf("    [warn] failed to remove old instance %d: %v\n", instanceNum, err)
-					}
-
-					fmt.Printf("    [%d/%d] removed old instance %d\n", instanceNum, targetInstances, instanceNum)
-				}
+		if i < len(currentInstances) {
+			replaced := currentInstances[i:min(i+batchSize, len(currentInstances))]
+
+			// everything but the replaced instances keeps serving while they drain
+			remaining := append(append([]string{}, newContainerIDs...), batchContainerIDs...)
+			remaining = append(remaining, remainingInstances(currentInstances, i+batchSize)...)
+
+			// the replacements are already healthy, so the old instances go even if the deploy
+			// was cancelled meanwhile
+			if opts.App.DrainPeriod > 0 {
+				opts.emit(DeploymentEvent{
+					Type:    EventInstanceDraining,
+					Message: fmt.Sprintf("draining %d old instance(s) for %ds...", len(replaced), opts.App.DrainPeriod),
+				})
+			}
+			if err := DrainAndRemove(s.dockerClient, opts.App, replaced, remaining, opts.Events); err != nil {
+				opts.warn("failed to remove old instances: %v", err)
+			}
+
+			for j, containerID := range replaced {
+				instanceNum := i + j + 1
+				opts.emit(DeploymentEvent{
+					Type:        EventInstanceRemoved,
+					Message:     fmt.Sprintf("removed old instance %d", instanceNum),
+					Instance:    instanceNum,
+					Total:       targetInstances,
+					ContainerID: containerID,
+				})
 			}
 		}
 
 		newContainerIDs = append(newContainerIDs, batchContainerIDs...)
 
 		if i+batchSize < targetInstances {
-			fmt.Printf("\n    waiting %ds before next batch...\n\n", int(rollingInterval.Seconds()))
-			time.Sleep(rollingInterval)
+			opts.emit(DeploymentEvent{
+				Type:    EventWaiting,
+				Message: fmt.Sprintf("waiting %ds before next batch...", int(rollingInterval.Seconds())),
+			})
+			if err := sleepContext(ctx, rollingInterval); err != nil {
+				opts.App.ContainerIDs = append(newContainerIDs, remainingInstances(currentInstances, len(newContainerIDs))...)
+				return "", err
+			}
 		}
 	}
 
 	opts.App.ContainerIDs = newContainerIDs
 
-	fmt.Println()
-	fmt.Println("  [done] rolling deployment completed")
+	opts.emit(DeploymentEvent{Type: EventDeployCompleted, Message: "rolling deployment completed"})
 
 	return opts.NewImageID, nil
 }
 
 func (s *RollingStrategy) createInstance(ctx context.Context, opts DeploymentOptions, instanceNum int) (string, error) {
-	var containerName string
-	if len(opts.App.ContainerIDs) > 0 {
-		containerName = fmt.Sprintf("yap-app-%s-%d-%d", opts.App.Name, instanceNum, time.Now().Unix())
-	} else {
-		containerName = fmt.Sprintf("yap-app-%s-%d", opts.App.Name, instanceNum)
-	}
+	containerName := newInstanceName(opts.App, instanceNum)
 
 	labels := map[string]string{
 		"yap.managed":      "true",
@@ -176,6 +202,8 @@ func (s *RollingStrategy) createInstance(ctx context.Context, opts DeploymentOpt
 		Labels: labels,
 		Env:    envArray,
 	}
+	applyStopConfig(containerConfig, opts.App)
+	applyProcess(containerConfig, opts.App)
 
 	mounts := prepareVolumeMounts(opts.App.Name, opts.App.Volumes)
 
@@ -197,8 +225,11 @@ func (s *RollingStrategy) createInstance(ctx context.Context, opts DeploymentOpt
 		},
 	}
 
+	opCtx, cancel := context.WithTimeout(ctx, docker.ContainerOpTimeout)
+	defer cancel()
+
 	resp, err := s.dockerClient.GetClient().ContainerCreate(
-		ctx,
+		opCtx,
 		containerConfig,
 		hostConfig,
 		networkConfig,
@@ -209,72 +240,35 @@ func (s *RollingStrategy) createInstance(ctx context.Context, opts DeploymentOpt
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
 
-	if err := s.dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
-		s.dockerClient.GetClient().ContainerRemove(ctx, resp.ID, dockerTypes.RemoveOptions{Force: true})
+	if err := s.dockerClient.GetClient().ContainerStart(opCtx, resp.ID, dockerTypes.StartOptions{}); err != nil {
+		s.cleanup([]string{resp.ID})
 		return "", fmt.Errorf("failed to start container: %w", err)
 	}
 
 	return resp.ID, nil
 }
 
-func (s *RollingStrategy) waitForHealthy(ctx context.Context, containerID string, opts DeploymentOptions, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	httpClient := &http.Client{
-		Timeout: 3 * time.Second,
-	}
-
-	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", opts.VPCName)
-
-	for {
-		select {
-		case <-ticker.C:
-			inspect, err := s.dockerClient.GetClient().ContainerInspect(ctx, containerID)
-			if err != nil {
-				return fmt.Errorf("failed to inspect container: %w", err)
-			}
-
-			if !inspect.State.Running {
-				return fmt.Errorf("container stopped unexpectedly")
-			}
-
-			var containerIP string
-			if networkSettings, ok := inspect.NetworkSettings.Networks[vpcNetworkName]; ok {
-				containerIP = networkSettings.IPAddress
-			}
-
-			if containerIP == "" {
-				continue
-			}
-
-			healthURL := fmt.Sprintf("http://%s:%d%s", containerIP, opts.App.Port, opts.App.HealthCheckPath)
-
-			resp, err := httpClient.Get(healthURL)
-			if err != nil {
-				continue
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-				return nil // Healthy!
-			}
-
-			if time.Now().After(deadline) {
-				return fmt.Errorf("health check timeout exceeded (last error: %v)", err)
-			}
-
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-	}
+// removes the batch that never became healthy; earlier batches already replaced old instances,
+// so they stay and the app is left with them plus the old instances not reached yet
+func (s *RollingStrategy) abort(opts DeploymentOptions, currentInstances, newContainerIDs, batchContainerIDs []string) {
+	s.cleanup(batchContainerIDs)
+	opts.App.ContainerIDs = append(newContainerIDs, remainingInstances(currentInstances, len(newContainerIDs))...)
 }
 
-func (s *RollingStrategy) cleanup(ctx context.Context, containerIDs []string) {
+func (s *RollingStrategy) cleanup(containerIDs []string) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+
 	for _, id := range containerIDs {
 		s.dockerClient.GetClient().ContainerRemove(ctx, id, dockerTypes.RemoveOptions{
 			Force: true,
 		})
 	}
 }
+
+func remainingInstances(currentInstances []string, replaced int) []string {
+	if replaced >= len(currentInstances) {
+		return nil
+	}
+	return currentInstances[replaced:]
+}
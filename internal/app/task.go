@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aelpxy/yap/internal/docker"
+	"github.com/aelpxy/yap/pkg/models"
+	dockerTypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/lucsky/cuid"
+)
+
+// runs a command in an ephemeral container from the given image with the app's env, volumes, limits
+// and vpc network, streams its output and removes it afterwards. returns the command's exit code
+func RunTask(ctx context.Context, dockerClient *docker.Client, app *models.Application, image, name string, command []string, stdout, stderr io.Writer) (int, error) {
+	// runs of the same task can overlap, and instance names start with yap-app-
+	containerName := fmt.Sprintf("yap-task-%s-%s-%s", app.Name, name, cuid.Slug())
+
+	labels := map[string]string{
+		"yap.managed":  "true",
+		"yap.type":     "task",
+		"yap.app.name": app.Name,
+		"yap.app.id":   app.ID,
+		"yap.vpc":      app.VPC,
+		"yap.app.task": name,
+	}
+
+	envVars := make(map[string]string)
+	for k, v := range app.EnvVars {
+		envVars[k] = v
+	}
+	InjectMetadata(envVars, app.ID, 0, "local")
+
+	containerConfig := &dockerTypes.Config{
+		Image:  image,
+		Labels: labels,
+		Env:    BuildEnvArray(envVars),
+		Cmd:    command,
+	}
+
+	hostConfig := &dockerTypes.HostConfig{
+		Resources: dockerTypes.Resources{
+			Memory:   int64(app.Memory) * 1024 * 1024,
+			NanoCPUs: int64(app.CPU * 1e9),
+		},
+		Mounts: prepareVolumeMounts(app.Name, app.Volumes),
+	}
+
+	vpcNetworkName := fmt.Sprintf("%s.yap-vpc-network", app.VPC)
+	networkConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			vpcNetworkName: {},
+		},
+	}
+
+	resp, err := dockerClient.GetClient().ContainerCreate(ctx, containerConfig, hostConfig, networkConfig, nil, containerName)
+	if err != nil {
+		return -1, fmt.Errorf("failed to create %s container: %w", name, err)
+	}
+	// the caller's context may already be cancelled, the container has to go either way
+	defer dockerClient.GetClient().ContainerRemove(context.Background(), resp.ID, dockerTypes.RemoveOptions{Force: true})
+
+	if err := dockerClient.GetClient().ContainerStart(ctx, resp.ID, dockerTypes.StartOptions{}); err != nil {
+		return -1, fmt.Errorf("failed to start %s container: %w", name, err)
+	}
+
+	logs, err := dockerClient.GetClient().ContainerLogs(ctx, resp.ID, dockerTypes.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err == nil {
+		stdcopy.StdCopy(stdout, stderr, logs)
+		logs.Close()
+	}
+
+	statusCh, errCh := dockerClient.GetClient().ContainerWait(ctx, resp.ID, dockerTypes.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return -1, fmt.Errorf("error waiting for %s container: %w", name, err)
+	case status := <-statusCh:
+		return int(status.StatusCode), nil
+	}
+}
@@ -1,6 +1,7 @@
 package builder
 
 import (
+	"archive/tar"
 	"bufio"
 	"context"
 	"encoding/json"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aelpxy/yap/internal/docker"
 	"github.com/aelpxy/yap/pkg/models"
@@ -26,18 +28,65 @@ func NewBuilder(dockerClient *docker.Client) *Builder {
 	}
 }
 
-func (b *Builder) BuildDockerfile(projectPath, imageName string, output io.Writer) (string, error) {
-	ctx := context.Background()
+// a Dockerfile outside the context is sent along with it under this name, the way docker build does
+const contextDockerfile = ".yap.Dockerfile"
 
-	buildContext, err := b.createBuildContext(projectPath)
+func (b *Builder) BuildDockerfile(ctx context.Context, contextPath, dockerfilePath, imageName string, opts BuildOptions, output io.Writer) (string, error) {
+	buildArgs, err := ResolveBuildArgs(opts.BuildArgs)
+	if err != nil {
+		return "", err
+	}
+
+	dockerfile, err := filepath.Rel(contextPath, dockerfilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve dockerfile: %w", err)
+	}
+	outsideContext := dockerfile == ".." || strings.HasPrefix(dockerfile, "../")
+
+	// the tar is rewritten to add the Dockerfile, that only works uncompressed
+	compression := archive.Gzip
+	if outsideContext {
+		compression = archive.Uncompressed
+	}
+
+	excludes, err := ResolveContextExcludes(contextPath, dockerfilePath, opts.Exclude)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(output, "  --> excluding from the context: %s\n", excludes.Source)
+
+	buildContext, err := createBuildContext(contextPath, excludes, compression)
 	if err != nil {
 		return "", fmt.Errorf("failed to create build context: %w", err)
 	}
+
+	if outsideContext {
+		content, err := os.ReadFile(dockerfilePath)
+		if err != nil {
+			buildContext.Close()
+			return "", fmt.Errorf("failed to read dockerfile: %w", err)
+		}
+		buildContext = archive.ReplaceFileTarWrapper(buildContext, map[string]archive.TarModifierFunc{
+			contextDockerfile: func(path string, header *tar.Header, r io.Reader) (*tar.Header, []byte, error) {
+				return &tar.Header{
+					Name:     contextDockerfile,
+					Mode:     0o644,
+					Size:     int64(len(content)),
+					ModTime:  time.Now(),
+					Typeflag: tar.TypeReg,
+				}, content, nil
+			},
+		})
+		dockerfile = contextDockerfile
+	}
 	defer buildContext.Close()
 
 	buildOptions := types.ImageBuildOptions{
 		Tags:           []string{imageName},
-		Dockerfile:     "Dockerfile",
+		Dockerfile:     filepath.ToSlash(dockerfile),
+		BuildArgs:      buildArgs,
+		Target:         opts.Target,
+		Labels:         opts.Labels,
 		Remove:         true,
 		ForceRemove:    true,
 		PullParent:     true,
@@ -58,6 +107,51 @@ func (b *Builder) BuildDockerfile(projectPath, imageName string, output io.Write
 	return imageID, nil
 }
 
+// KEY=VALUE args get $VAR and ${VAR} replaced from the environment yap runs in, a bare KEY takes the
+// variable as is and is left to the Dockerfile's default when it isn't set, like docker build --build-arg
+func ResolveBuildArgs(args []string) (map[string]*string, error) {
+	resolved := make(map[string]*string, len(args))
+	for _, arg := range args {
+		if err := models.ValidateBuildArg(arg); err != nil {
+			return nil, err
+		}
+
+		key, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue {
+			if env, ok := os.LookupEnv(key); ok {
+				resolved[key] = &env
+			} else {
+				resolved[key] = nil
+			}
+			continue
+		}
+
+		value = os.ExpandEnv(value)
+		resolved[key] = &value
+	}
+	return resolved, nil
+}
+
+// the resolved args as KEY=VALUE, for the builders that take them as build time environment
+func buildEnv(args []string) ([]string, error) {
+	resolved, err := ResolveBuildArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var env []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		key, _, _ := strings.Cut(arg, "=")
+		if seen[key] || resolved[key] == nil {
+			continue
+		}
+		seen[key] = true
+		env = append(env, key+"="+*resolved[key])
+	}
+	return env, nil
+}
+
 func (b *Builder) streamBuildOutput(reader io.Reader, output io.Writer) (string, error) {
 	var imageID string
 	scanner := bufio.NewScanner(reader)
@@ -125,66 +219,71 @@ func (b *Builder) streamBuildOutput(reader io.Reader, output io.Writer) (string,
 	return imageID, nil
 }
 
-func (b *Builder) createBuildContext(projectPath string) (io.ReadCloser, error) {
-	absPath, err := filepath.Abs(projectPath)
-	if err != nil {
-		return nil, err
-	}
+// build settings from yap.toml and the deploy flags, zero values keep the defaults. paths are
+// relative to the project
+type BuildOptions struct {
+	Dockerfile string            // defaults to the Dockerfile in the context
+	Context    string            // subdirectory that is built, for every build method
+	BuildArgs  []string          // KEY=VALUE or KEY, see ResolveBuildArgs
+	Target     string            // dockerfile stage to build
+	Labels     map[string]string // added to the image
+	Exclude    []string          // used without a .dockerignore, nil keeps DefaultExcludes
+
+	Builder     string   // paketo builder image
+	Buildpacks  []string // paketo buildpacks to run instead of the ones the builder detects
+	CacheVolume string   // volume paketo keeps its cache in
+}
 
-	exclusions := []string{
-		".git",
-		".gitignore",
-		"node_modules",
-		".env",
-		".env.local",
-		"*.log",
-		".DS_Store",
-		"__pycache__",
-		"*.pyc",
-		".pytest_cache",
-		"venv",
-		".venv",
-		"target",
-		"dist",
-		"build",
+// where the context and the Dockerfile are, both kept inside the project
+func ResolveBuildPaths(projectPath string, opts BuildOptions) (string, string, error) {
+	contextPath := projectPath
+	if opts.Context != "" {
+		if err := models.ValidateBuildPath("context", opts.Context); err != nil {
+			return "", "", err
+		}
+		contextPath = filepath.Join(projectPath, opts.Context)
+		info, err := os.Stat(contextPath)
+		if err != nil || !info.IsDir() {
+			return "", "", fmt.Errorf("build context not found at %s", contextPath)
+		}
 	}
 
-	dockerIgnorePath := filepath.Join(absPath, ".dockerignore")
-	if _, err := os.Stat(dockerIgnorePath); err == nil {
-		content, err := os.ReadFile(dockerIgnorePath)
-		if err == nil {
-			lines := strings.Split(string(content), "\n")
-			for _, line := range lines {
-				line = strings.TrimSpace(line)
-				if line != "" && !strings.HasPrefix(line, "#") {
-					exclusions = append(exclusions, line)
-				}
-			}
+	dockerfilePath := filepath.Join(contextPath, "Dockerfile")
+	if opts.Dockerfile != "" {
+		if err := models.ValidateBuildPath("dockerfile", opts.Dockerfile); err != nil {
+			return "", "", err
 		}
+		dockerfilePath = filepath.Join(projectPath, opts.Dockerfile)
 	}
 
-	buildContext, err := archive.TarWithOptions(absPath, &archive.TarOptions{
-		ExcludePatterns: exclusions,
-		Compression:     archive.Gzip,
-	})
-
-	return buildContext, err
+	return contextPath, dockerfilePath, nil
 }
 
-func (b *Builder) BuildWithMethod(projectPath, appName string, buildMethod string, output io.Writer) (*BuildResult, error) {
+func (b *Builder) BuildWithMethod(ctx context.Context, projectPath, appName string, version int, buildMethod string, opts BuildOptions, output io.Writer) (*BuildResult, error) {
 	var buildType models.BuildType
 	var dockerfilePath string
-	var err error
+
+	contextPath, configuredDockerfile, err := ResolveBuildPaths(projectPath, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	if buildMethod == "auto" {
-		buildType, dockerfilePath, err = DetectBuildMethod(projectPath)
-		if err != nil {
-			return nil, err
+		if _, err := os.Stat(configuredDockerfile); err == nil {
+			buildType, dockerfilePath = models.BuildTypeDockerfile, configuredDockerfile
+		} else if opts.Dockerfile != "" {
+			// a Dockerfile that was asked for never falls back to another build method
+			return nil, fmt.Errorf("dockerfile not found at %s", configuredDockerfile)
+		} else {
+			buildType, dockerfilePath, err = DetectBuildMethod(contextPath)
+			if err != nil {
+				return nil, err
+			}
 		}
 	} else {
 		switch buildMethod {
 		case "dockerfile":
-			dockerfilePath = filepath.Join(projectPath, "Dockerfile")
+			dockerfilePath = configuredDockerfile
 			if _, err := os.Stat(dockerfilePath); err != nil {
 				return nil, fmt.Errorf("dockerfile not found at %s", dockerfilePath)
 			}
@@ -195,67 +294,100 @@ func (b *Builder) BuildWithMethod(projectPath, appName string, buildMethod strin
 			}
 			buildType = models.BuildTypeNixpacks
 		case "paketo":
+			if !IsPackInstalled() {
+				return nil, fmt.Errorf("pack is not installed (see https://buildpacks.io/docs/for-platform-operators/how-to/integrate-ci/pack/)")
+			}
 			buildType = models.BuildTypePacketo
 		default:
 			return nil, fmt.Errorf("invalid build method: %s (valid: auto, dockerfile, nixpacks, paketo)", buildMethod)
 		}
 	}
 
-	return b.buildInternal(projectPath, appName, buildType, dockerfilePath, output)
+	return b.buildInternal(ctx, contextPath, appName, version, buildType, dockerfilePath, opts, output)
 }
 
-func (b *Builder) Build(projectPath, appName string, output io.Writer) (*BuildResult, error) {
+func (b *Builder) Build(ctx context.Context, projectPath, appName string, version int, output io.Writer) (*BuildResult, error) {
 	buildType, dockerfilePath, err := DetectBuildMethod(projectPath)
 	if err != nil {
 		return nil, err
 	}
 
-	return b.buildInternal(projectPath, appName, buildType, dockerfilePath, output)
+	return b.buildInternal(ctx, projectPath, appName, version, buildType, dockerfilePath, BuildOptions{}, output)
+}
+
+// every build gets its own immutable tag so older releases stay addressable
+func ReleaseTag(appName string, version int) string {
+	return fmt.Sprintf("yap/%s:v%d", appName, version)
 }
 
-func (b *Builder) buildInternal(projectPath, appName string, buildType models.BuildType, dockerfilePath string, output io.Writer) (*BuildResult, error) {
+func LatestTag(appName string) string {
+	return fmt.Sprintf("yap/%s:latest", appName)
+}
 
-	language, _ := DetectLanguage(projectPath)
+func (b *Builder) buildInternal(ctx context.Context, contextPath, appName string, version int, buildType models.BuildType, dockerfilePath string, opts BuildOptions, output io.Writer) (*BuildResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, docker.ImageBuildTimeout)
+	defer cancel()
 
-	imageName := fmt.Sprintf("yap/%s:latest", appName)
+	language, _ := DetectLanguage(contextPath)
+
+	imageName := ReleaseTag(appName, version)
 
 	fmt.Fprintf(output, "  --> detected build method: %s\n", buildType)
 	if language != "unknown" {
 		fmt.Fprintf(output, "  --> detected language: %s\n", language)
 	}
+	if buildType != models.BuildTypeDockerfile && opts.Target != "" {
+		fmt.Fprintf(output, "  [warn] target %s only applies to dockerfile builds\n", opts.Target)
+	}
 	fmt.Fprintln(output, "")
 
 	var imageID string
+	var buildpack string
 	var err error
 
 	switch buildType {
 	case models.BuildTypeDockerfile:
 		fmt.Fprintln(output, "  --> building with dockerfile...")
-		imageID, err = b.BuildDockerfile(projectPath, imageName, output)
+		imageID, err = b.BuildDockerfile(ctx, contextPath, dockerfilePath, imageName, opts, output)
 		if err != nil {
 			return nil, fmt.Errorf("dockerfile build failed: %w", err)
 		}
 
 	case models.BuildTypeNixpacks:
 		fmt.Fprintln(output, "  --> building with nixpacks...")
-		imageID, err = b.BuildNixpacks(projectPath, imageName, output)
+		imageID, err = b.BuildNixpacks(ctx, contextPath, imageName, opts, output)
 		if err != nil {
 			return nil, fmt.Errorf("nixpacks build failed: %w", err)
 		}
 
 	case models.BuildTypePacketo:
-		return nil, fmt.Errorf("packeto support not yet implemented")
+		fmt.Fprintln(output, "  --> building with paketo buildpacks...")
+		imageID, err = b.BuildPaketo(ctx, contextPath, imageName, appName, opts, output)
+		if err != nil {
+			return nil, fmt.Errorf("paketo build failed: %w", err)
+		}
+		buildpack = opts.Builder
+		if buildpack == "" {
+			buildpack = DefaultPaketoBuilder
+		}
 
 	default:
 		return nil, fmt.Errorf("unsupported build type: %s", buildType)
 	}
 
+	// latest is just a convenience pointer, deployments always use the release tag
+	if err := b.dockerClient.GetClient().ImageTag(ctx, imageID, LatestTag(appName)); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to tag %s: %v\n", LatestTag(appName), err)
+	}
+
 	result := &BuildResult{
 		ImageID:        imageID,
 		ImageName:      imageName,
+		Version:        version,
 		BuildType:      buildType,
 		Language:       language,
 		DockerfilePath: dockerfilePath,
+		Buildpack:      buildpack,
 	}
 
 	return result, nil
@@ -264,7 +396,11 @@ func (b *Builder) buildInternal(projectPath, appName string, buildType models.Bu
 type BuildResult struct {
 	ImageID        string
 	ImageName      string
+	Version        int
 	BuildType      models.BuildType
 	Language       string
 	DockerfilePath string
+	Buildpack      string // builder image of a paketo build
+	Port           int    // first port the image exposes, 0 when unknown
+	Source         string // image reference a prebuilt release came from
 }
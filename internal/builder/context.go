@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// left out of the context when the project has no .dockerignore and [build] exclude isn't set
+var DefaultExcludes = []string{
+	".git",
+	".gitignore",
+	"node_modules",
+	".env",
+	".env.local",
+	"*.log",
+	".DS_Store",
+	"__pycache__",
+	"*.pyc",
+	".pytest_cache",
+	"venv",
+	".venv",
+	"target",
+	"dist",
+	"build",
+}
+
+// the patterns a context is filtered with and where they came from
+type ContextExcludes struct {
+	Patterns []string
+	Source   string
+}
+
+// the first of: <Dockerfile>.dockerignore next to the Dockerfile, the context's .dockerignore,
+// [build] exclude (nil when it isn't set), the default list. the patterns follow docker's
+// .dockerignore rules, later patterns win and ! brings files back
+func ResolveContextExcludes(contextPath, dockerfilePath string, exclude []string) (*ContextExcludes, error) {
+	excludes := &ContextExcludes{}
+
+	candidates := []string{filepath.Join(contextPath, ".dockerignore")}
+	if dockerfilePath != "" {
+		candidates = append([]string{dockerfilePath + ".dockerignore"}, candidates...)
+	}
+
+	found := false
+	for _, path := range candidates {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		excludes.Patterns, err = ignorefile.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		excludes.Source = path
+		found = true
+		break
+	}
+
+	if !found && exclude != nil {
+		patterns, err := ignorefile.ReadAll(strings.NewReader(strings.Join(exclude, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse [build] exclude: %w", err)
+		}
+		excludes.Patterns = patterns
+		excludes.Source = "[build] exclude"
+	} else if !found {
+		excludes.Patterns = append([]string(nil), DefaultExcludes...)
+		excludes.Source = "default exclusions"
+	}
+
+	if _, err := patternmatcher.New(excludes.Patterns); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern in %s: %w", excludes.Source, err)
+	}
+
+	// the daemon needs the Dockerfile and .dockerignore whatever the patterns say, docker build keeps them too
+	keep := []string{".dockerignore"}
+	if dockerfilePath != "" {
+		if rel, err := filepath.Rel(contextPath, dockerfilePath); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			keep = append(keep, filepath.ToSlash(rel))
+		}
+	}
+	for _, path := range keep {
+		if excluded, _ := patternmatcher.MatchesOrParentMatches(path, excludes.Patterns); excluded {
+			excludes.Patterns = append(excludes.Patterns, "!"+path)
+		}
+	}
+
+	return excludes, nil
+}
+
+func createBuildContext(contextPath string, excludes *ContextExcludes, compression archive.Compression) (io.ReadCloser, error) {
+	absPath, err := filepath.Abs(contextPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.TarWithOptions(absPath, &archive.TarOptions{
+		ExcludePatterns: excludes.Patterns,
+		Compression:     compression,
+	})
+}
+
+type ContextFile struct {
+	Path string
+	Size int64
+}
+
+// the files a dockerfile build sends to the daemon, read back from the same archive the build uses
+func ListBuildContext(contextPath string, excludes *ContextExcludes) ([]ContextFile, error) {
+	buildContext, err := createBuildContext(contextPath, excludes, archive.Uncompressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build context: %w", err)
+	}
+	defer buildContext.Close()
+
+	var files []ContextFile
+	reader := tar.NewReader(buildContext)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build context: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		files = append(files, ContextFile{Path: header.Name, Size: header.Size})
+	}
+
+	return files, nil
+}
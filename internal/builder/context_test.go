@@ -0,0 +1,244 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestResolveContextExcludes(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		context    string
+		dockerfile string
+		exclude    []string
+		// a file relative to the project, or the source's name when no file was used
+		wantSource   string
+		wantPatterns []string
+		wantFiles    []string
+		wantErr      bool
+	}{
+		{
+			name: "defaults without a .dockerignore",
+			files: map[string]string{
+				"Dockerfile":                 "FROM scratch",
+				"main.go":                    "package main",
+				"dist/index.html":            "<html>",
+				"node_modules/left/index.js": "",
+				".env":                       "SECRET=1",
+			},
+			dockerfile:   "Dockerfile",
+			wantSource:   "default exclusions",
+			wantPatterns: DefaultExcludes,
+			wantFiles:    []string{"Dockerfile", "main.go"},
+		},
+		{
+			name: "[build] exclude replaces the defaults",
+			files: map[string]string{
+				"Dockerfile":      "FROM scratch",
+				"README.md":       "",
+				"dist/index.html": "<html>",
+			},
+			dockerfile:   "Dockerfile",
+			exclude:      []string{"*.md"},
+			wantSource:   "[build] exclude",
+			wantPatterns: []string{"*.md"},
+			wantFiles:    []string{"Dockerfile", "dist/index.html"},
+		},
+		{
+			name: "empty [build] exclude sends everything",
+			files: map[string]string{
+				"Dockerfile":      "FROM scratch",
+				"dist/index.html": "<html>",
+				"node_modules/x":  "",
+			},
+			dockerfile: "Dockerfile",
+			exclude:    []string{},
+			wantSource: "[build] exclude",
+			wantFiles:  []string{"Dockerfile", "dist/index.html", "node_modules/x"},
+		},
+		{
+			name: ".dockerignore keeps dist when it doesn't list it",
+			files: map[string]string{
+				".dockerignore":   "node_modules\n",
+				"Dockerfile":      "FROM scratch",
+				"dist/index.html": "<html>",
+				"node_modules/x":  "",
+			},
+			dockerfile:   "Dockerfile",
+			exclude:      []string{"dist"},
+			wantSource:   ".dockerignore",
+			wantPatterns: []string{"node_modules"},
+			wantFiles:    []string{".dockerignore", "Dockerfile", "dist/index.html"},
+		},
+		{
+			name: "<Dockerfile>.dockerignore beats .dockerignore",
+			files: map[string]string{
+				".dockerignore":                      "dist\n",
+				"docker/app.Dockerfile":              "FROM scratch",
+				"docker/app.Dockerfile.dockerignore": "*.md\n",
+				"README.md":                          "",
+				"dist/index.html":                    "<html>",
+			},
+			dockerfile:   "docker/app.Dockerfile",
+			wantSource:   "docker/app.Dockerfile.dockerignore",
+			wantPatterns: []string{"*.md"},
+			wantFiles: []string{
+				".dockerignore",
+				"dist/index.html",
+				"docker/app.Dockerfile",
+				"docker/app.Dockerfile.dockerignore",
+			},
+		},
+		{
+			name: "negation brings files back",
+			files: map[string]string{
+				".dockerignore":   "dist\n!dist/index.html\n",
+				"Dockerfile":      "FROM scratch",
+				"dist/index.html": "<html>",
+				"dist/app.js.map": "",
+			},
+			dockerfile:   "Dockerfile",
+			wantSource:   ".dockerignore",
+			wantPatterns: []string{"dist", "!dist/index.html"},
+			wantFiles:    []string{".dockerignore", "Dockerfile", "dist/index.html"},
+		},
+		{
+			name: "later patterns win",
+			files: map[string]string{
+				".dockerignore": "!secret.txt\n*.txt\n",
+				"Dockerfile":    "FROM scratch",
+				"secret.txt":    "",
+			},
+			dockerfile:   "Dockerfile",
+			wantSource:   ".dockerignore",
+			wantPatterns: []string{"!secret.txt", "*.txt"},
+			wantFiles:    []string{".dockerignore", "Dockerfile"},
+		},
+		{
+			name: "the Dockerfile and .dockerignore are kept",
+			files: map[string]string{
+				".dockerignore": "*\n!main.go\n",
+				"Dockerfile":    "FROM scratch",
+				"main.go":       "package main",
+				"README.md":     "",
+			},
+			dockerfile:   "Dockerfile",
+			wantSource:   ".dockerignore",
+			wantPatterns: []string{"*", "!main.go", "!.dockerignore", "!Dockerfile"},
+			wantFiles:    []string{".dockerignore", "Dockerfile", "main.go"},
+		},
+		{
+			name: "a Dockerfile in an excluded directory is kept",
+			files: map[string]string{
+				".dockerignore":     "docker\n",
+				"docker/Dockerfile": "FROM scratch",
+				"docker/notes.md":   "",
+				"main.go":           "package main",
+			},
+			dockerfile:   "docker/Dockerfile",
+			wantSource:   ".dockerignore",
+			wantPatterns: []string{"docker", "!docker/Dockerfile"},
+			wantFiles:    []string{".dockerignore", "docker/Dockerfile", "main.go"},
+		},
+		{
+			name: "a Dockerfile outside the context isn't added",
+			files: map[string]string{
+				"Dockerfile":        "FROM scratch",
+				"app/.dockerignore": "*\n!main.go\n",
+				"app/main.go":       "package main",
+			},
+			context:      "app",
+			dockerfile:   "Dockerfile",
+			wantSource:   "app/.dockerignore",
+			wantPatterns: []string{"*", "!main.go", "!.dockerignore"},
+			wantFiles:    []string{".dockerignore", "main.go"},
+		},
+		{
+			name: "no Dockerfile only keeps .dockerignore",
+			files: map[string]string{
+				".dockerignore": "*\n",
+				"main.go":       "package main",
+			},
+			wantSource:   ".dockerignore",
+			wantPatterns: []string{"*", "!.dockerignore"},
+			wantFiles:    []string{".dockerignore"},
+		},
+		{
+			name: "invalid pattern",
+			files: map[string]string{
+				".dockerignore": "[\n",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectPath := t.TempDir()
+			for path, content := range tt.files {
+				full := filepath.Join(projectPath, path)
+				if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			contextPath := filepath.Join(projectPath, tt.context)
+			dockerfilePath := ""
+			if tt.dockerfile != "" {
+				dockerfilePath = filepath.Join(projectPath, tt.dockerfile)
+			}
+
+			excludes, err := ResolveContextExcludes(contextPath, dockerfilePath, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveContextExcludes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			wantSource := tt.wantSource
+			if _, ok := tt.files[wantSource]; ok {
+				wantSource = filepath.Join(projectPath, wantSource)
+			}
+			if excludes.Source != wantSource {
+				t.Errorf("Source = %q, want %q", excludes.Source, wantSource)
+			}
+			if len(excludes.Patterns) != 0 || len(tt.wantPatterns) != 0 {
+				if !reflect.DeepEqual(excludes.Patterns, tt.wantPatterns) {
+					t.Errorf("Patterns = %q, want %q", excludes.Patterns, tt.wantPatterns)
+				}
+			}
+
+			files, err := ListBuildContext(contextPath, excludes)
+			if err != nil {
+				t.Fatalf("ListBuildContext() error = %v", err)
+			}
+			var got []string
+			for _, file := range files {
+				got = append(got, file.Path)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("context files = %q, want %q", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestResolveContextExcludesDoesNotShareDefaults(t *testing.T) {
+	excludes, err := ResolveContextExcludes(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	excludes.Patterns[0] = "changed"
+	if DefaultExcludes[0] == "changed" {
+		t.Error("the resolved patterns share DefaultExcludes' backing array")
+	}
+}
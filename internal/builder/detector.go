@@ -21,7 +21,12 @@ func DetectBuildMethod(projectPath string) (models.BuildType, string, error) {
 		}
 	}
 
-	return "", "", fmt.Errorf("no supported build method detected (no Dockerfile found, nixpacks not available)")
+	// buildpacks only know whether they apply once the build runs, so pack comes last
+	if IsPackInstalled() {
+		return models.BuildTypePacketo, "", nil
+	}
+
+	return "", "", fmt.Errorf("no supported build method detected (no Dockerfile found, nixpacks and pack not available)")
 }
 
 func DetectLanguage(projectPath string) (string, error) {
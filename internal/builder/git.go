@@ -0,0 +1,134 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type GitCheckout struct {
+	Dir    string
+	URL    string
+	Ref    string
+	Commit string
+	Branch string // empty when ref is a tag or commit
+}
+
+func IsGitInstalled() bool {
+	cmd := exec.Command("git", "--version")
+	return cmd.Run() == nil
+}
+
+// clones a repository (remote url or local path) into a temporary workspace and checks out ref,
+// which can be a branch, tag or commit sha. an empty ref checks out the default branch
+func CheckoutGit(ctx context.Context, url, ref string, output io.Writer) (*GitCheckout, error) {
+	if !IsGitInstalled() {
+		return nil, fmt.Errorf("git is not installed")
+	}
+
+	dir, err := os.MkdirTemp("", "yap-git-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+
+	checkout := &GitCheckout{
+		Dir: dir,
+		URL: url,
+		Ref: ref,
+	}
+
+	fmt.Fprintf(output, "  --> cloning %s...\n", url)
+	if _, err := runGit(ctx, dir, output, "clone", "--quiet", "--no-checkout", "--", url, "."); err != nil {
+		checkout.Cleanup()
+		return nil, err
+	}
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	// branches only exist as remote-tracking refs after a clone
+	var commit string
+	for _, candidate := range []string{ref, "origin/" + ref} {
+		sha, err := runGit(ctx, dir, nil, "rev-parse", "--verify", "--quiet", candidate+"^{commit}")
+		if err == nil {
+			commit = sha
+			break
+		}
+	}
+	if commit == "" {
+		checkout.Cleanup()
+		return nil, fmt.Errorf("ref %s not found in %s", ref, url)
+	}
+
+	if _, err := runGit(ctx, dir, output, "checkout", "--quiet", "--detach", commit); err != nil {
+		checkout.Cleanup()
+		return nil, err
+	}
+
+	checkout.Commit = commit
+	if ref == "HEAD" {
+		if head, err := runGit(ctx, dir, nil, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil {
+			checkout.Branch = strings.TrimPrefix(head, "origin/")
+		}
+	} else if _, err := runGit(ctx, dir, nil, "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+ref); err == nil {
+		checkout.Branch = ref
+	}
+	fmt.Fprintf(output, "  --> checked out %s (%s)\n", ref, commit[:12])
+
+	return checkout, nil
+}
+
+// commit and branch a local project directory is at, both empty when it isn't a git repository.
+// the branch is empty on a detached head
+func LocalGitInfo(ctx context.Context, dir string) (commit, branch string) {
+	if !IsGitInstalled() {
+		return "", ""
+	}
+
+	commit, err := runGit(ctx, dir, nil, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		return "", ""
+	}
+
+	branch, err = runGit(ctx, dir, nil, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		branch = ""
+	}
+
+	return commit, branch
+}
+
+func (g *GitCheckout) Cleanup() {
+	os.RemoveAll(g.Dir)
+}
+
+func runGit(ctx context.Context, dir string, output io.Writer, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+
+	if output != nil && stderr.Len() > 0 {
+		fmt.Fprint(output, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
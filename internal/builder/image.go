@@ -0,0 +1,62 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// turns a prebuilt image into a release: uses the local copy when present, pulls it otherwise,
+// and tags it with the release tag so rollbacks work the same as for built images
+func (b *Builder) UseImage(ctx context.Context, imageRef, appName string, version int, output io.Writer) (*BuildResult, error) {
+	inspect, _, err := b.dockerClient.GetClient().ImageInspectWithRaw(ctx, imageRef)
+	if err != nil {
+		fmt.Fprintf(output, "  --> pulling %s...\n", imageRef)
+		if err := b.dockerClient.PullImageContext(ctx, imageRef, output); err != nil {
+			return nil, err
+		}
+
+		inspect, _, err = b.dockerClient.GetClient().ImageInspectWithRaw(ctx, imageRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect image %s: %w", imageRef, err)
+		}
+	} else {
+		fmt.Fprintf(output, "  --> using local image %s\n", imageRef)
+	}
+
+	imageName := ReleaseTag(appName, version)
+	if err := b.dockerClient.GetClient().ImageTag(ctx, inspect.ID, imageName); err != nil {
+		return nil, fmt.Errorf("failed to tag %s: %w", imageName, err)
+	}
+
+	if err := b.dockerClient.GetClient().ImageTag(ctx, inspect.ID, LatestTag(appName)); err != nil {
+		fmt.Fprintf(output, "  [warn] failed to tag %s: %v\n", LatestTag(appName), err)
+	}
+
+	port := 0
+	if inspect.Config != nil {
+		var ports []int
+		for p := range inspect.Config.ExposedPorts {
+			if p.Proto() == "tcp" {
+				ports = append(ports, p.Int())
+			}
+		}
+		sort.Ints(ports)
+		if len(ports) > 0 {
+			port = ports[0]
+		}
+	}
+
+	return &BuildResult{
+		ImageID:   inspect.ID,
+		ImageName: imageName,
+		Version:   version,
+		BuildType: models.BuildTypeImage,
+		Language:  "unknown",
+		Port:      port,
+		Source:    imageRef,
+	}, nil
+}
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -61,7 +62,7 @@ func GetNixpacksPlan(projectPath string) (*NixpacksPlan, error) {
 	return &plan, nil
 }
 
-func (b *Builder) BuildNixpacks(projectPath, imageName string, output io.Writer) (string, error) {
+func (b *Builder) BuildNixpacks(ctx context.Context, projectPath, imageName string, opts BuildOptions, output io.Writer) (string, error) {
 	plan, err := GetNixpacksPlan(projectPath)
 	if err != nil {
 		return "", err
@@ -74,7 +75,20 @@ func (b *Builder) BuildNixpacks(projectPath, imageName string, output io.Writer)
 	fmt.Fprintln(output, "")
 	fmt.Fprintf(output, "  --> running nixpacks build...\n")
 
-	cmd := exec.Command("nixpacks", "build", projectPath, "--name", imageName)
+	env, err := buildEnv(opts.BuildArgs)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"build", projectPath, "--name", imageName}
+	for _, e := range env {
+		args = append(args, "--env", e)
+	}
+	for key, value := range opts.Labels {
+		args = append(args, "--label", key+"="+value)
+	}
+
+	cmd := exec.CommandContext(ctx, "nixpacks", args...)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -94,13 +108,16 @@ func (b *Builder) BuildNixpacks(projectPath, imageName string, output io.Writer)
 	go streamOutput(stderr, output, "  ")
 
 	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		return "", fmt.Errorf("nixpacks build failed: %w", err)
 	}
 
 	fmt.Fprintln(output, "")
 	fmt.Fprintf(output, "  --> build completed successfully\n")
 
-	imageID, err := b.getImageID(imageName)
+	imageID, err := b.getImageID(ctx, imageName)
 	if err != nil {
 		return "", fmt.Errorf("failed to get image ID: %w", err)
 	}
@@ -118,20 +135,18 @@ func streamOutput(reader io.Reader, writer io.Writer, prefix string) {
 	}
 }
 
-func (b *Builder) getImageID(imageName string) (string, error) {
-	cmd := exec.Command("docker", "images", "-q", imageName)
+// the full ID docker inspect reports, short IDs from docker images wouldn't match the IDs
+// releases are resolved against
+func (b *Builder) getImageID(ctx context.Context, imageName string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", "image", "inspect", "--format", "{{.Id}}", imageName)
 	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to get image ID: %w", err)
+		return "", fmt.Errorf("no image found with name %s: %w", imageName, err)
 	}
 
 	imageID := strings.TrimSpace(string(output))
-	if imageID == "" {
-		return "", fmt.Errorf("no image found with name %s", imageName)
-	}
-
 	if !strings.HasPrefix(imageID, "sha256:") {
-		imageID = "sha256:" + imageID
+		return "", fmt.Errorf("unexpected image ID for %s: %q", imageName, imageID)
 	}
 
 	return imageID, nil
@@ -0,0 +1,140 @@
+package builder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// builds run with the pack cli, the builder image brings the lifecycle and the buildpacks
+const DefaultPaketoBuilder = "paketobuildpacks/builder-jammy-base"
+
+func IsPackInstalled() bool {
+	cmd := exec.Command("pack", "version")
+	if err := cmd.Run(); err != nil {
+		return false
+	}
+	return true
+}
+
+// the buildpacks cache outlives the builds so dependencies aren't downloaded again, one per app
+func PaketoCacheVolume(appName string) string {
+	return fmt.Sprintf("yap-%s-buildpacks-cache", appName)
+}
+
+func (b *Builder) BuildPaketo(ctx context.Context, projectPath, imageName, appName string, opts BuildOptions, output io.Writer) (string, error) {
+	builderImage := opts.Builder
+	if builderImage == "" {
+		builderImage = DefaultPaketoBuilder
+	}
+
+	cacheVolume := opts.CacheVolume
+	if cacheVolume == "" {
+		cacheVolume = PaketoCacheVolume(appName)
+	}
+	if err := models.ValidateCacheVolume(cacheVolume); err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"build", imageName,
+		"--path", projectPath,
+		"--builder", builderImage,
+		"--cache", fmt.Sprintf("type=build;format=volume;name=%s", cacheVolume),
+		"--pull-policy", "if-not-present",
+	}
+	// without buildpacks the builder detects which of its own apply
+	for _, buildpack := range opts.Buildpacks {
+		args = append(args, "--buildpack", buildpack)
+	}
+
+	env, err := buildEnv(opts.BuildArgs)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range env {
+		args = append(args, "--env", e)
+	}
+	// pack has no labels of its own, paketo's image labels buildpack adds them
+	if len(opts.Labels) > 0 {
+		args = append(args, "--env", "BP_IMAGE_LABELS="+paketoImageLabels(opts.Labels))
+	}
+
+	fmt.Fprintln(output, "")
+	fmt.Fprintf(output, "  --> running pack build (builder: %s)...\n", builderImage)
+	if len(opts.Buildpacks) > 0 {
+		fmt.Fprintf(output, "  --> buildpacks: %s\n", strings.Join(opts.Buildpacks, ", "))
+	}
+
+	cmd := exec.CommandContext(ctx, "pack", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start pack build: %w", err)
+	}
+
+	// Wait closes the pipes, the output has to be read to the end first
+	var streaming sync.WaitGroup
+	streaming.Add(2)
+	go func() {
+		defer streaming.Done()
+		streamOutput(stdout, output, "  ")
+	}()
+	go func() {
+		defer streaming.Done()
+		streamOutput(stderr, output, "  ")
+	}()
+	streaming.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("pack build failed: %w", err)
+	}
+
+	fmt.Fprintln(output, "")
+	fmt.Fprintf(output, "  --> build completed successfully\n")
+
+	imageID, err := b.getImageID(ctx, imageName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get image ID: %w", err)
+	}
+
+	return imageID, nil
+}
+
+// key=value pairs separated by spaces, values with spaces quoted
+func paketoImageLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := labels[key]
+		if strings.ContainsAny(value, " \t\"") {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, " ")
+}
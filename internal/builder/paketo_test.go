@@ -0,0 +1,316 @@
+package builder
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// puts an executable script called name in dir
+func writeTool(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a PATH with only the fake tools in it, so nothing installed on the machine is picked up
+func fakePath(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func TestPaketoImageLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{
+		{
+			name:   "empty",
+			labels: map[string]string{},
+			want:   "",
+		},
+		{
+			name:   "sorted by key",
+			labels: map[string]string{"team": "web", "env": "prod"},
+			want:   "env=prod team=web",
+		},
+		{
+			name:   "value with spaces is quoted",
+			labels: map[string]string{"description": "my web app"},
+			want:   `description="my web app"`,
+		},
+		{
+			name:   "value with a tab is quoted",
+			labels: map[string]string{"a": "x\ty"},
+			want:   `a="x\ty"`,
+		},
+		{
+			name:   "quotes are escaped",
+			labels: map[string]string{"a": `say "hi"`},
+			want:   `a="say \"hi\""`,
+		},
+		{
+			name:   "other characters are left alone",
+			labels: map[string]string{"org.opencontainers.image.source": "https://example.com/app"},
+			want:   "org.opencontainers.image.source=https://example.com/app",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paketoImageLabels(tt.labels); got != tt.want {
+				t.Errorf("paketoImageLabels() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildEnv(t *testing.T) {
+	t.Setenv("YAP_TEST_PASS", "p")
+	// registers the restore, then unsets for the test
+	t.Setenv("YAP_TEST_MISSING", "")
+	os.Unsetenv("YAP_TEST_MISSING")
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "none",
+			args: nil,
+			want: nil,
+		},
+		{
+			name: "key value",
+			args: []string{"A=1", "B=two words"},
+			want: []string{"A=1", "B=two words"},
+		},
+		{
+			name: "bare key takes the environment",
+			args: []string{"YAP_TEST_PASS"},
+			want: []string{"YAP_TEST_PASS=p"},
+		},
+		{
+			name: "bare key missing from the environment is skipped",
+			args: []string{"YAP_TEST_MISSING", "A=1"},
+			want: []string{"A=1"},
+		},
+		{
+			name: "values are expanded",
+			args: []string{"A=${YAP_TEST_PASS}x"},
+			want: []string{"A=px"},
+		},
+		{
+			name: "last value wins at the first position",
+			args: []string{"A=1", "YAP_TEST_PASS", "YAP_TEST_MISSING", "A=2"},
+			want: []string{"A=2", "YAP_TEST_PASS=p"},
+		},
+		{
+			name: "empty value is kept",
+			args: []string{"A="},
+			want: []string{"A="},
+		},
+		{
+			name:    "invalid key",
+			args:    []string{"=1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildEnv(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPaketoArgs(t *testing.T) {
+	bin := fakePath(t)
+	argsFile := filepath.Join(t.TempDir(), "args")
+	writeTool(t, bin, "pack", `for arg in "$@"; do echo "$arg"; done > "`+argsFile+`"`+"\n")
+	const imageID = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	writeTool(t, bin, "docker", `if [ "$*" = "image inspect --format {{.Id}} yap-myapp:v1" ]; then echo `+imageID+`; else exit 1; fi`+"\n")
+	t.Setenv("YAP_TEST_PASS", "p")
+
+	tests := []struct {
+		name string
+		opts BuildOptions
+		want []string
+	}{
+		{
+			name: "defaults",
+			opts: BuildOptions{},
+			want: []string{
+				"build", "yap-myapp:v1",
+				"--path", "/src",
+				"--builder", DefaultPaketoBuilder,
+				"--cache", "type=build;format=volume;name=yap-myapp-buildpacks-cache",
+				"--pull-policy", "if-not-present",
+			},
+		},
+		{
+			name: "configured",
+			opts: BuildOptions{
+				Builder:     "paketobuildpacks/builder-jammy-full",
+				Buildpacks:  []string{"paketo-buildpacks/nodejs", "paketo-buildpacks/image-labels"},
+				CacheVolume: "shared-cache",
+				BuildArgs:   []string{"NODE_ENV=production", "YAP_TEST_PASS"},
+				Labels:      map[string]string{"team": "web", "description": "my app"},
+			},
+			want: []string{
+				"build", "yap-myapp:v1",
+				"--path", "/src",
+				"--builder", "paketobuildpacks/builder-jammy-full",
+				"--cache", "type=build;format=volume;name=shared-cache",
+				"--pull-policy", "if-not-present",
+				"--buildpack", "paketo-buildpacks/nodejs",
+				"--buildpack", "paketo-buildpacks/image-labels",
+				"--env", "NODE_ENV=production",
+				"--env", "YAP_TEST_PASS=p",
+				"--env", `BP_IMAGE_LABELS=description="my app" team=web`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{}
+			got, err := b.BuildPaketo(context.Background(), "/src", "yap-myapp:v1", "myapp", tt.opts, io.Discard)
+			if err != nil {
+				t.Fatalf("BuildPaketo() error = %v", err)
+			}
+			if got != imageID {
+				t.Errorf("BuildPaketo() = %q, want %q", got, imageID)
+			}
+
+			data, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			if !reflect.DeepEqual(args, tt.want) {
+				t.Errorf("pack args:\n got %q\nwant %q", args, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPaketoRejectsInvalidCacheVolume(t *testing.T) {
+	bin := fakePath(t)
+	ran := filepath.Join(t.TempDir(), "ran")
+	writeTool(t, bin, "pack", `: > "`+ran+`"`+"\n")
+
+	b := &Builder{}
+	opts := BuildOptions{CacheVolume: "bad;name"}
+	if _, err := b.BuildPaketo(context.Background(), "/src", "yap-myapp:v1", "myapp", opts, io.Discard); err == nil {
+		t.Fatal("BuildPaketo() error = nil, want an invalid cache volume error")
+	}
+	if _, err := os.Stat(ran); err == nil {
+		t.Error("pack ran with an invalid cache volume")
+	}
+}
+
+func TestBuildPaketoFailure(t *testing.T) {
+	bin := fakePath(t)
+	writeTool(t, bin, "pack", "echo 'ERROR: no buildpack groups passed detection' >&2\nexit 1\n")
+
+	var output strings.Builder
+	b := &Builder{}
+	_, err := b.BuildPaketo(context.Background(), "/src", "yap-myapp:v1", "myapp", BuildOptions{}, &output)
+	if err == nil || !strings.Contains(err.Error(), "pack build failed") {
+		t.Fatalf("BuildPaketo() error = %v, want a pack build failure", err)
+	}
+	if !strings.Contains(output.String(), "no buildpack groups passed detection") {
+		t.Errorf("pack output not streamed, got %q", output.String())
+	}
+}
+
+func TestDetectBuildMethod(t *testing.T) {
+	const plan = `{"phases":{"setup":{"nixPkgs":["nodejs"]}},"start":{"cmd":"npm start"}}`
+
+	tests := []struct {
+		name       string
+		dockerfile bool
+		nixpacks   string
+		pack       bool
+		want       models.BuildType
+		wantErr    bool
+	}{
+		{
+			name:       "dockerfile comes first",
+			dockerfile: true,
+			nixpacks:   plan,
+			pack:       true,
+			want:       models.BuildTypeDockerfile,
+		},
+		{
+			name:     "nixpacks before pack",
+			nixpacks: plan,
+			pack:     true,
+			want:     models.BuildTypeNixpacks,
+		},
+		{
+			name:     "invalid nixpacks plan falls through to pack",
+			nixpacks: `{"phases":{},"start":{"cmd":""}}`,
+			pack:     true,
+			want:     models.BuildTypePacketo,
+		},
+		{
+			name: "pack last",
+			pack: true,
+			want: models.BuildTypePacketo,
+		},
+		{
+			name:    "nothing available",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := fakePath(t)
+			if tt.nixpacks != "" {
+				writeTool(t, bin, "nixpacks", "if [ \"$1\" = plan ]; then echo '"+tt.nixpacks+"'; fi\n")
+			}
+			if tt.pack {
+				writeTool(t, bin, "pack", "exit 0\n")
+			}
+
+			projectPath := t.TempDir()
+			if tt.dockerfile {
+				if err := os.WriteFile(filepath.Join(projectPath, "Dockerfile"), []byte("FROM scratch\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, dockerfilePath, err := DetectBuildMethod(projectPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectBuildMethod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DetectBuildMethod() = %q, want %q", got, tt.want)
+			}
+			if tt.dockerfile && dockerfilePath != filepath.Join(projectPath, "Dockerfile") {
+				t.Errorf("DetectBuildMethod() dockerfile = %q", dockerfilePath)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package constants
+
+// defaults shared by the yap.toml loader, the deploy flags and the deploy engine
+const (
+	DefaultStopTimeout         = 10
+	DefaultDrainPeriod         = 5
+	DefaultCrashLoopThreshold  = 5
+	DefaultConfirmationTimeout = 300
+
+	// seconds an instance has to pass its readiness probe during a deploy
+	DefaultHealthTimeout = 30
+
+	// autoscaling target in percent of the cpu limit, and the seconds the load has to stay over or
+	// under it before instances are added or removed
+	DefaultTargetCPU      = 70
+	DefaultScaleUpDelay   = 60
+	DefaultScaleDownDelay = 300
+)
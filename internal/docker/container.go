@@ -26,7 +26,12 @@ type PullProgress struct {
 }
 
 func (c *Client) PullImage(imageName string, progressWriter io.Writer) error {
-	ctx, cancel := context.WithTimeout(c.ctx, ImagePullTimeout)
+	return c.PullImageContext(c.ctx, imageName, progressWriter)
+}
+
+// same as PullImage, but stops when ctx is cancelled
+func (c *Client) PullImageContext(ctx context.Context, imageName string, progressWriter io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, ImagePullTimeout)
 	defer cancel()
 
 	reader, err := c.cli.ImagePull(ctx, imageName, image.PullOptions{})
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
+	"github.com/aelpxy/yap/internal/constants"
 	"github.com/aelpxy/yap/pkg/models"
 )
 
@@ -57,17 +59,66 @@ func validateAndSetDefaults(config *models.ProjectConfig) error {
 		config.Deploy.Port = 3000 // default port
 	}
 
-	if config.Deploy.HealthCheck.Path == "" {
-		config.Deploy.HealthCheck.Path = "/health"
+	if config.Deploy.StopSignal == "" {
+		config.Deploy.StopSignal = "SIGTERM"
 	}
-	if config.Deploy.HealthCheck.Interval == 0 {
-		config.Deploy.HealthCheck.Interval = 10
+	if config.Deploy.StopTimeout == 0 {
+		config.Deploy.StopTimeout = constants.DefaultStopTimeout
 	}
-	if config.Deploy.HealthCheck.Timeout == 0 {
-		config.Deploy.HealthCheck.Timeout = 5
+	if config.Deploy.DrainPeriod == nil {
+		drainPeriod := constants.DefaultDrainPeriod
+		config.Deploy.DrainPeriod = &drainPeriod
 	}
-	if config.Deploy.HealthCheck.Retries == 0 {
-		config.Deploy.HealthCheck.Retries = 3
+	if config.Deploy.CrashLoopThreshold == 0 {
+		config.Deploy.CrashLoopThreshold = constants.DefaultCrashLoopThreshold
+	}
+
+	healthCheck := &config.Deploy.HealthCheck
+	healthCheck.Configured = healthCheck.Type != "" || healthCheck.Path != "" || healthCheck.Port != 0 ||
+		healthCheck.ExpectedStatus != 0 || healthCheck.ExpectedBody != "" || len(healthCheck.Command) > 0 ||
+		healthCheck.Interval != 0 || healthCheck.Timeout != 0 || healthCheck.Retries != 0
+	if healthCheck.Type == "" {
+		healthCheck.Type = string(models.HealthProbeHTTP)
+	}
+	if healthCheck.Path == "" {
+		healthCheck.Path = "/health"
+	}
+	if healthCheck.Interval == 0 {
+		healthCheck.Interval = 10
+	}
+	if healthCheck.Timeout == 0 {
+		healthCheck.Timeout = 5
+	}
+	if healthCheck.Retries == 0 {
+		healthCheck.Retries = 3
+	}
+
+	// liveness only spells out what differs from readiness
+	if liveness := healthCheck.Liveness; liveness != nil {
+		if liveness.Type == "" {
+			liveness.Type = healthCheck.Type
+			if len(liveness.Command) == 0 {
+				liveness.Command = healthCheck.Command
+			}
+		}
+		if liveness.Path == "" {
+			liveness.Path = healthCheck.Path
+		}
+		if liveness.Port == 0 {
+			liveness.Port = healthCheck.Port
+		}
+		if liveness.Interval == 0 {
+			liveness.Interval = healthCheck.Interval
+		}
+		if liveness.Timeout == 0 {
+			liveness.Timeout = healthCheck.Timeout
+		}
+		if liveness.Retries == 0 {
+			liveness.Retries = healthCheck.Retries
+		}
+		if liveness.Liveness != nil {
+			return fmt.Errorf("health_check: liveness cannot be nested")
+		}
 	}
 
 	if config.Deployment.Strategy == "" {
@@ -80,25 +131,124 @@ func validateAndSetDefaults(config *models.ProjectConfig) error {
 		config.Deployment.RollingInterval = 5
 	}
 	if config.Deployment.HealthTimeout == 0 {
-		config.Deployment.HealthTimeout = 30
+		config.Deployment.HealthTimeout = constants.DefaultHealthTimeout
+	}
+	if config.Deployment.ConfirmationTimeout == nil {
+		confirmationTimeout := constants.DefaultConfirmationTimeout
+		config.Deployment.ConfirmationTimeout = &confirmationTimeout
+	}
+	if config.Deployment.ConfirmationPolicy == "" {
+		config.Deployment.ConfirmationPolicy = "confirm"
+	}
+	if config.Deployment.CanaryInstances == 0 {
+		config.Deployment.CanaryInstances = 1
+	}
+	if len(config.Deployment.CanarySteps) == 0 {
+		config.Deployment.CanarySteps = []int{10, 50, 100}
 	}
-	if config.Deployment.ConfirmationTimeout == 0 {
-		config.Deployment.ConfirmationTimeout = 300
+	if config.Deployment.CanaryInterval == 0 {
+		config.Deployment.CanaryInterval = 60
 	}
 
 	validStrategies := map[string]bool{
 		"recreate":   true,
 		"rolling":    true,
 		"blue-green": true,
+		"canary":     true,
 	}
 	if !validStrategies[config.Deployment.Strategy] {
-		return fmt.Errorf("invalid deployment strategy: %s (must be recreate, rolling, or blue-green)", config.Deployment.Strategy)
+		return fmt.Errorf("invalid deployment strategy: %s (must be recreate, rolling, blue-green, or canary)", config.Deployment.Strategy)
+	}
+
+	if *config.Deployment.ConfirmationTimeout < 0 {
+		return fmt.Errorf("confirmation_timeout cannot be negative, got: %d", *config.Deployment.ConfirmationTimeout)
+	}
+	if config.Deployment.ConfirmationPolicy != "confirm" && config.Deployment.ConfirmationPolicy != "revert" {
+		return fmt.Errorf("invalid confirmation policy: %s (must be confirm or revert)", config.Deployment.ConfirmationPolicy)
+	}
+
+	if err := models.ValidateCanarySteps(config.Deployment.CanarySteps); err != nil {
+		return fmt.Errorf("deployment: %w", err)
+	}
+
+	if err := models.ValidateHealthProbe(healthCheck.Probe()); err != nil {
+		return fmt.Errorf("deploy.health_check: %w", err)
+	}
+	if healthCheck.Liveness != nil {
+		if err := models.ValidateHealthProbe(healthCheck.LivenessProbe()); err != nil {
+			return fmt.Errorf("deploy.health_check.liveness: %w", err)
+		}
+	}
+
+	if err := models.ValidateStopSignal(config.Deploy.StopSignal); err != nil {
+		return fmt.Errorf("deploy: %w", err)
+	}
+	if config.Deploy.StopTimeout < 0 {
+		return fmt.Errorf("stop_timeout cannot be negative, got: %d", config.Deploy.StopTimeout)
+	}
+	if *config.Deploy.DrainPeriod < 0 {
+		return fmt.Errorf("drain_period cannot be negative, got: %d", *config.Deploy.DrainPeriod)
+	}
+	if config.Deploy.CrashLoopThreshold < 1 {
+		return fmt.Errorf("crash_loop_threshold must be at least 1, got: %d", config.Deploy.CrashLoopThreshold)
+	}
+
+	if config.Build.Dockerfile != "" {
+		if err := models.ValidateBuildPath("dockerfile", config.Build.Dockerfile); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+	}
+	if config.Build.Context != "" {
+		if err := models.ValidateBuildPath("context", config.Build.Context); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+	}
+	for _, arg := range config.Build.BuildArgs {
+		if err := models.ValidateBuildArg(arg); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+	}
+	for key := range config.Build.Labels {
+		if err := models.ValidateLabel(key); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+	}
+	if config.Build.Paketo.CacheVolume != "" {
+		if err := models.ValidateCacheVolume(config.Build.Paketo.CacheVolume); err != nil {
+			return fmt.Errorf("build.paketo: %w", err)
+		}
+	}
+	for _, buildpack := range config.Build.Paketo.Buildpacks {
+		if strings.TrimSpace(buildpack) == "" {
+			return fmt.Errorf("build.paketo: buildpacks cannot contain empty entries")
+		}
 	}
 
 	if config.Deploy.Instances < 1 {
 		return fmt.Errorf("instances must be at least 1, got: %d", config.Deploy.Instances)
 	}
 
+	for name, process := range config.Processes {
+		if err := validateProcess(name, process); err != nil {
+			return fmt.Errorf("processes.%s: %w", name, err)
+		}
+		if name != models.ProcessWeb && process.Instances == 0 {
+			process.Instances = 1
+			config.Processes[name] = process
+		}
+	}
+
+	cronNames := make(map[string]bool, len(config.Cron))
+	for i, job := range config.Cron {
+		if err := validateCronJob(job); err != nil {
+			return fmt.Errorf("cron[%d]: %w", i, err)
+		}
+		if cronNames[job.Name] {
+			return fmt.Errorf("cron: duplicate job name: %s", job.Name)
+		}
+		cronNames[job.Name] = true
+	}
+
 	if config.Deploy.AutoScaling {
 		if config.Scaling.MinInstances == 0 {
 			config.Scaling.MinInstances = 1
@@ -110,8 +260,59 @@ func validateAndSetDefaults(config *models.ProjectConfig) error {
 			return fmt.Errorf("scaling: min_instances (%d) cannot be greater than max_instances (%d)",
 				config.Scaling.MinInstances, config.Scaling.MaxInstances)
 		}
+		if config.Scaling.CPUThreshold == 0 {
+			config.Scaling.CPUThreshold = constants.DefaultTargetCPU
+		}
+		if config.Scaling.ScaleUpDelay == 0 {
+			config.Scaling.ScaleUpDelay = constants.DefaultScaleUpDelay
+		}
+		if config.Scaling.ScaleDownDelay == 0 {
+			config.Scaling.ScaleDownDelay = constants.DefaultScaleDownDelay
+		}
+		if config.Scaling.CPUThreshold < 1 || config.Scaling.CPUThreshold > 100 {
+			return fmt.Errorf("scaling: cpu_threshold must be between 1 and 100, got: %d", config.Scaling.CPUThreshold)
+		}
+		// memory is only scaled on when a threshold is set
+		if config.Scaling.MemoryThreshold < 0 || config.Scaling.MemoryThreshold > 100 {
+			return fmt.Errorf("scaling: memory_threshold must be between 0 (disabled) and 100, got: %d", config.Scaling.MemoryThreshold)
+		}
+		if config.Scaling.ScaleUpDelay < 0 || config.Scaling.ScaleDownDelay < 0 {
+			return fmt.Errorf("scaling: scale_up_delay and scale_down_delay cannot be negative")
+		}
+	}
+
+	return nil
+}
+
+func validateProcess(name string, process models.ProcessConfig) error {
+	if err := models.ValidateProcessName(name); err != nil {
+		return err
 	}
+	if name != models.ProcessWeb && strings.TrimSpace(process.Command) == "" {
+		return fmt.Errorf("command is required")
+	}
+	if process.Instances < 0 {
+		return fmt.Errorf("instances cannot be negative, got: %d", process.Instances)
+	}
+	if process.CPU < 0 {
+		return fmt.Errorf("cpu cannot be negative, got: %.2f", process.CPU)
+	}
+	return nil
+}
 
+func validateCronJob(job models.CronConfig) error {
+	if err := models.ValidateCronJobName(job.Name); err != nil {
+		return err
+	}
+	if _, err := models.ParseSchedule(job.Schedule); err != nil {
+		return err
+	}
+	if _, err := job.CommandArgs(); err != nil {
+		return err
+	}
+	if job.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative, got: %d", job.Timeout)
+	}
 	return nil
 }
 
@@ -0,0 +1,59 @@
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// reads a Procfile (`name: command` per line), nil when the project doesn't have one. every
+// process starts with one instance, scaling it is up to yap app scale
+func LoadProcfile(projectPath string) (map[string]models.ProcessConfig, error) {
+	file, err := os.Open(filepath.Join(projectPath, "Procfile"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+	defer file.Close()
+
+	processes := make(map[string]models.ProcessConfig)
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, command, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("Procfile line %d: expected name: command", lineNum)
+		}
+		name = strings.TrimSpace(name)
+
+		process := models.ProcessConfig{Command: strings.TrimSpace(command), Instances: 1}
+		if name == models.ProcessWeb {
+			process.Instances = 0
+		}
+		if err := validateProcess(name, process); err != nil {
+			return nil, fmt.Errorf("Procfile line %d: %w", lineNum, err)
+		}
+		if _, exists := processes[name]; exists {
+			return nil, fmt.Errorf("Procfile line %d: process %s defined twice", lineNum, name)
+		}
+		processes[name] = process
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Procfile: %w", err)
+	}
+
+	return processes, nil
+}
@@ -0,0 +1,132 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// below connection draining, which takes over while instances of the routed color go away
+const colorRouterPriority = drainRouterPriority - 1
+
+// the service a blue-green color's instances register. app names can't contain underscores, so
+// this never collides with another app's services
+func ColorServiceName(appName string, color models.DeploymentColor) string {
+	return fmt.Sprintf("%s_%s", appName, color)
+}
+
+func colorConfigPath(appName string) (string, error) {
+	dir, err := DynamicConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare dynamic config directory: %w", err)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s.color.yml", appName)), nil
+}
+
+// blue-green instances register a service for their color but no router, traffic only reaches them
+// through the router SetColorRouting writes, so switching colors never touches the instances
+func (t *TraefikManager) GenerateColorLabels(app *models.Application, color models.DeploymentColor) map[string]string {
+	service := ColorServiceName(app.Name, color)
+
+	labels := map[string]string{
+		"traefik.enable": "true",
+
+		fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", service): fmt.Sprintf("%d", app.Port),
+
+		// keep traefik from generating a default Host(`container-name`) router
+		fmt.Sprintf("traefik.http.routers.%s.rule", service):        fmt.Sprintf("Host(`%s.%s.yap.internal`)", app.Name, color),
+		fmt.Sprintf("traefik.http.routers.%s.entrypoints", service): "web",
+		fmt.Sprintf("traefik.http.routers.%s.service", service):     service,
+	}
+	addHealthCheckLabels(labels, service, app)
+
+	return labels
+}
+
+// whether an instance with these labels is reachable through color routing
+func HasColorLabels(labels map[string]string, appName string, color models.DeploymentColor) bool {
+	_, ok := labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", ColorServiceName(appName, color))]
+	return ok
+}
+
+// sends all of the app's traffic to the given color's service. the file is replaced atomically,
+// so requests move over at once and the other color keeps whatever it's already serving
+func (t *TraefikManager) SetColorRouting(app *models.Application, color models.DeploymentColor) error {
+	path, err := colorConfigPath(app.Name)
+	if err != nil {
+		return err
+	}
+
+	router := fmt.Sprintf("%s_bluegreen", app.Name)
+	service := ColorServiceName(app.Name, color) + "@docker"
+	rule := strings.ReplaceAll(hostRuleForApp(app), `"`, `\"`)
+
+	var config string
+	if app.Published {
+		redirect := fmt.Sprintf("%s_https", app.Name)
+		config = fmt.Sprintf(`# managed by yap, blue-green routing for %s
+http:
+  routers:
+    %s:
+      rule: "%s"
+      entryPoints:
+        - websecure
+      service: %s
+      priority: %d
+      tls:
+        certResolver: letsencrypt
+    %s_web:
+      rule: "%s"
+      entryPoints:
+        - web
+      middlewares:
+        - %s
+      service: %s
+      priority: %d
+  middlewares:
+    %s:
+      redirectScheme:
+        scheme: https
+        permanent: true
+`, app.Name,
+			router, rule, service, colorRouterPriority,
+			router, rule, redirect, service, colorRouterPriority,
+			redirect)
+	} else {
+		config = fmt.Sprintf(`# managed by yap, blue-green routing for %s
+http:
+  routers:
+    %s:
+      rule: "%s"
+      entryPoints:
+        - web
+      service: %s
+      priority: %d
+`, app.Name,
+			router, rule, service, colorRouterPriority)
+	}
+
+	if err := utils.AtomicWriteFile(path, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write blue-green routing: %w", err)
+	}
+
+	return nil
+}
+
+// hands routing back to the docker provider
+func (t *TraefikManager) ClearColorRouting(appName string) error {
+	path, err := colorConfigPath(appName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove blue-green routing: %w", err)
+	}
+
+	return nil
+}
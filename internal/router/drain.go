@@ -0,0 +1,92 @@
+package router
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// below the canary router, a canary rollout decides on its own where traffic goes
+const drainRouterPriority = weightedRouterPriority - 1
+
+func drainConfigPath(appName string) (string, error) {
+	dir, err := DynamicConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare dynamic config directory: %w", err)
+	}
+
+	// dots can't appear in app names, so this never collides with another app's routing file
+	return filepath.Join(dir, fmt.Sprintf("%s.drain.yml", appName)), nil
+}
+
+// routes the app's traffic to the given servers only (http://ip:port), taking every other instance
+// out of the load balancer while it finishes the requests it already has
+func (t *TraefikManager) SetDrainRouting(app *models.Application, servers []string) error {
+	if len(servers) == 0 {
+		return fmt.Errorf("no servers left to route to")
+	}
+
+	path, err := drainConfigPath(app.Name)
+	if err != nil {
+		return err
+	}
+
+	drainService := fmt.Sprintf("%s-drain", app.Name)
+	rule := strings.ReplaceAll(hostRuleForApp(app), `"`, `\"`)
+
+	entryPoint := "web"
+	tls := ""
+	if app.Published {
+		entryPoint = "websecure"
+		tls = `
+      tls:
+        certResolver: letsencrypt`
+	}
+
+	var serverList strings.Builder
+	for _, server := range servers {
+		fmt.Fprintf(&serverList, "          - url: %s\n", server)
+	}
+
+	config := fmt.Sprintf(`# managed by yap, connection draining for %s
+http:
+  routers:
+    %s:
+      rule: "%s"
+      entryPoints:
+        - %s
+      service: %s
+      priority: %d%s
+  services:
+    %s:
+      loadBalancer:
+        servers:
+%s`, app.Name,
+		drainService, rule, entryPoint, drainService, drainRouterPriority, tls,
+		drainService,
+		serverList.String())
+
+	if err := utils.AtomicWriteFile(path, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write drain routing: %w", err)
+	}
+
+	return nil
+}
+
+// hands routing back to the docker provider
+func (t *TraefikManager) ClearDrainRouting(appName string) error {
+	path, err := drainConfigPath(appName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove drain routing: %w", err)
+	}
+
+	return nil
+}
@@ -21,6 +21,9 @@ import (
 const (
 	traefikContainerName = "yap-traefik"
 	traefikImage         = "traefik:v3.5"
+
+	// file provider directory, used for routing the docker provider can't express (weighted services)
+	dynamicConfigTarget = "/etc/traefik/dynamic"
 )
 
 type TraefikManager struct {
@@ -84,6 +87,11 @@ func (t *TraefikManager) Start(output io.Writer) error {
 		return fmt.Errorf("failed to generate traefik config: %w", err)
 	}
 
+	dynamicDir, err := DynamicConfigDir()
+	if err != nil {
+		return fmt.Errorf("failed to prepare dynamic config directory: %w", err)
+	}
+
 	fmt.Fprintln(output, "  --> pulling traefik image...")
 	if err := t.pullImage(ctx); err != nil {
 		return fmt.Errorf("failed to pull traefik image: %w", err)
@@ -133,6 +141,11 @@ func (t *TraefikManager) Start(output io.Writer) error {
 				Source: t.letsencryptDir,
 				Target: "/letsencrypt",
 			},
+			{
+				Type:   mount.TypeBind,
+				Source: dynamicDir,
+				Target: dynamicConfigTarget,
+			},
 		},
 	}
 
@@ -245,6 +258,9 @@ providers:
     endpoint: "unix:///var/run/docker.sock"
     exposedByDefault: false
     watch: true
+  file:
+    directory: %s
+    watch: true
 
 api:
   dashboard: true
@@ -255,7 +271,7 @@ log:
 
 accessLog:
   format: common
-`, acmeEmail)
+`, acmeEmail, dynamicConfigTarget)
 
 	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
 		return "", err
@@ -290,24 +306,30 @@ func (t *TraefikManager) ConnectToVPC(vpcNetworkName string) error {
 	return nil
 }
 
+// traefik only health checks over http, apps probed any other way rely on yap's own probes
+func addHealthCheckLabels(labels map[string]string, service string, app *models.Application) {
+	if app.HealthCheckPath == "" {
+		return
+	}
+
+	labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.path", service)] = app.HealthCheckPath
+	labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.interval", service)] = fmt.Sprintf("%ds", app.HealthCheckInterval)
+	labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.timeout", service)] = fmt.Sprintf("%ds", app.HealthCheckTimeout)
+	if app.ReadinessProbe != nil && app.ReadinessProbe.Port != 0 {
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.port", service)] = fmt.Sprintf("%d", app.ReadinessProbe.Port)
+	}
+}
+
 func (t *TraefikManager) GenerateLabelsForApp(app *models.Application) map[string]string {
 	labels := map[string]string{
 		"traefik.enable": "true",
 
 		fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", app.Name): fmt.Sprintf("%d", app.Port),
-
-		fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.path", app.Name):     app.HealthCheckPath,
-		fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.interval", app.Name): fmt.Sprintf("%ds", app.HealthCheckInterval),
-		fmt.Sprintf("traefik.http.services.%s.loadbalancer.healthcheck.timeout", app.Name):  fmt.Sprintf("%ds", app.HealthCheckTimeout),
 	}
+	addHealthCheckLabels(labels, app.Name, app)
 
 	if app.Published {
-		var hostRules []string
-		hostRules = append(hostRules, fmt.Sprintf("Host(`%s`)", app.PublishedDomain))
-		for _, domain := range app.CustomDomains {
-			hostRules = append(hostRules, fmt.Sprintf("Host(`%s`)", domain))
-		}
-		hostRule := strings.Join(hostRules, " || ")
+		hostRule := hostRuleForApp(app)
 
 		labels[fmt.Sprintf("traefik.http.routers.%s-secure.rule", app.Name)] = hostRule
 		labels[fmt.Sprintf("traefik.http.routers.%s-secure.entrypoints", app.Name)] = "websecure"
@@ -321,13 +343,26 @@ func (t *TraefikManager) GenerateLabelsForApp(app *models.Application) map[strin
 		labels["traefik.http.middlewares.redirect-to-https.redirectscheme.scheme"] = "https"
 		labels["traefik.http.middlewares.redirect-to-https.redirectscheme.permanent"] = "true"
 	} else {
-		labels[fmt.Sprintf("traefik.http.routers.%s.rule", app.Name)] = fmt.Sprintf("Host(`%s.yap.local`)", app.Name)
+		labels[fmt.Sprintf("traefik.http.routers.%s.rule", app.Name)] = hostRuleForApp(app)
 		labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", app.Name)] = "web"
 	}
 
 	return labels
 }
 
+func hostRuleForApp(app *models.Application) string {
+	if !app.Published {
+		return fmt.Sprintf("Host(`%s.yap.local`)", app.Name)
+	}
+
+	var hostRules []string
+	hostRules = append(hostRules, fmt.Sprintf("Host(`%s`)", app.PublishedDomain))
+	for _, domain := range app.CustomDomains {
+		hostRules = append(hostRules, fmt.Sprintf("Host(`%s`)", domain))
+	}
+	return strings.Join(hostRules, " || ")
+}
+
 func loadGlobalConfig() (*config.ConfigManager, error) {
 	cm, err := config.NewConfigManager()
 	if err != nil {
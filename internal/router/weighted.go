@@ -0,0 +1,144 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aelpxy/yap/internal/utils"
+	"github.com/aelpxy/yap/pkg/models"
+)
+
+// above any rule-length based priority traefik assigns to the docker routers
+const weightedRouterPriority = 10000
+
+func DynamicConfigDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(homeDir, ".yap", "dynamic")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// app names can't contain underscores, so the canary service never collides with another app's
+func CanaryServiceName(appName string) string {
+	return fmt.Sprintf("%s_canary", appName)
+}
+
+// load balancers created before weighted routing existed don't mount the dynamic config directory
+func (t *TraefikManager) SupportsWeightedRouting() (bool, error) {
+	containerID, err := t.getContainerID()
+	if err != nil {
+		return false, err
+	}
+
+	inspect, err := t.dockerClient.GetClient().ContainerInspect(context.Background(), containerID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, m := range inspect.Mounts {
+		if m.Destination == dynamicConfigTarget {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// canary instances register their own service but no router, traffic only reaches
+// them through the weighted service written by SetWeightedRouting
+func (t *TraefikManager) GenerateCanaryLabels(app *models.Application) map[string]string {
+	service := CanaryServiceName(app.Name)
+
+	labels := map[string]string{
+		"traefik.enable": "true",
+
+		fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", service): fmt.Sprintf("%d", app.Port),
+
+		// keep traefik from generating a default Host(`container-name`) router
+		fmt.Sprintf("traefik.http.routers.%s.rule", service):        fmt.Sprintf("Host(`%s.canary.yap.internal`)", app.Name),
+		fmt.Sprintf("traefik.http.routers.%s.entrypoints", service): "web",
+		fmt.Sprintf("traefik.http.routers.%s.service", service):     service,
+	}
+	addHealthCheckLabels(labels, service, app)
+
+	return labels
+}
+
+// splits the app's traffic between the stable docker service and the canary service
+func (t *TraefikManager) SetWeightedRouting(app *models.Application, canaryWeight int) error {
+	if canaryWeight < 0 || canaryWeight > 100 {
+		return fmt.Errorf("invalid canary weight: %d", canaryWeight)
+	}
+
+	dir, err := DynamicConfigDir()
+	if err != nil {
+		return fmt.Errorf("failed to prepare dynamic config directory: %w", err)
+	}
+
+	weightedService := fmt.Sprintf("%s_weighted", app.Name)
+	rule := strings.ReplaceAll(hostRuleForApp(app), `"`, `\"`)
+
+	entryPoint := "web"
+	tls := ""
+	if app.Published {
+		entryPoint = "websecure"
+		tls = `
+      tls:
+        certResolver: letsencrypt`
+	}
+
+	config := fmt.Sprintf(`# managed by yap, canary routing for %s
+http:
+  routers:
+    %s:
+      rule: "%s"
+      entryPoints:
+        - %s
+      service: %s
+      priority: %d%s
+  services:
+    %s:
+      weighted:
+        services:
+          - name: %s@docker
+            weight: %d
+          - name: %s@docker
+            weight: %d
+`, app.Name,
+		weightedService, rule, entryPoint, weightedService, weightedRouterPriority, tls,
+		weightedService,
+		app.Name, 100-canaryWeight,
+		CanaryServiceName(app.Name), canaryWeight)
+
+	path := filepath.Join(dir, fmt.Sprintf("%s.yml", app.Name))
+	if err := utils.AtomicWriteFile(path, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write weighted routing: %w", err)
+	}
+
+	return nil
+}
+
+// drops the weighted router so traffic falls back to the docker provider routes
+func (t *TraefikManager) ClearWeightedRouting(appName string) error {
+	dir, err := DynamicConfigDir()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s.yml", appName))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove weighted routing: %w", err)
+	}
+
+	return nil
+}
@@ -18,6 +18,7 @@ const (
 	BuildTypeDockerfile BuildType = "dockerfile"
 	BuildTypeNixpacks   BuildType = "nixpacks"
 	BuildTypePacketo    BuildType = "packeto"
+	BuildTypeImage      BuildType = "image"
 )
 
 type DeploymentStrategy string
@@ -26,8 +27,21 @@ const (
 	DeploymentStrategyRecreate  DeploymentStrategy = "recreate"
 	DeploymentStrategyRolling   DeploymentStrategy = "rolling"
 	DeploymentStrategyBlueGreen DeploymentStrategy = "blue-green"
+	DeploymentStrategyCanary    DeploymentStrategy = "canary"
 )
 
+type HealthProbeType string
+
+const (
+	HealthProbeHTTP HealthProbeType = "http"
+	HealthProbeTCP  HealthProbeType = "tcp"
+	HealthProbeExec HealthProbeType = "exec"
+	HealthProbeNone HealthProbeType = "none"
+)
+
+// the process type that takes traffic, it's the application itself
+const ProcessWeb = "web"
+
 type DeploymentColor string
 
 const (
@@ -42,6 +56,7 @@ type Application struct {
 	VPC  string `json:"vpc"`
 
 	Status         AppStatus `json:"status"`
+	StatusReason   string    `json:"status_reason,omitempty"` // why the watchdog failed the app, or that some instances crash loop
 	Instances      int       `json:"instances"`
 	BuildType      BuildType `json:"build_type"`
 	Buildpack      string    `json:"buildpack"`
@@ -72,11 +87,32 @@ type Application struct {
 	HealthCheckInterval int    `json:"health_check_interval"`
 	HealthCheckTimeout  int    `json:"health_check_timeout"`
 
+	// apps registered before probes only have the http fields above
+	ReadinessProbe *HealthProbe `json:"readiness_probe,omitempty"` // gates new instances during deploys and scaling
+	LivenessProbe  *HealthProbe `json:"liveness_probe,omitempty"`  // watches running instances, defaults to readiness
+
+	Command   string    `json:"command,omitempty"`   // web process command, empty runs the image's own
+	Processes []Process `json:"processes,omitempty"` // process types besides web, they get no routing
+
+	CronJobs []CronJob `json:"cron_jobs,omitempty"`
+
+	// set on the per-process copies deploys and scaling work on, never stored
+	Process string `json:"-"`
+
+	StopSignal  string `json:"stop_signal,omitempty"`  // empty is the image's own stop signal
+	StopTimeout int    `json:"stop_timeout,omitempty"` // seconds between the stop signal and a kill
+	DrainPeriod int    `json:"drain_period,omitempty"` // seconds out of the load balancer before stopping, 0 skips draining
+
+	CrashLoopThreshold int               `json:"crash_loop_threshold,omitempty"` // restarts within the crash loop window before an instance is stopped
+	CrashedInstances   []CrashedInstance `json:"crashed_instances,omitempty"`
+
 	AutoScaleEnabled bool `json:"autoscale_enabled"`
 	MinInstances     int  `json:"min_instances"`
 	MaxInstances     int  `json:"max_instances"`
 	TargetCPU        int  `json:"target_cpu"`
 	TargetMemory     int  `json:"target_memory"`
+	ScaleUpDelay     int  `json:"scale_up_delay,omitempty"`   // seconds over the targets before adding instances
+	ScaleDownDelay   int  `json:"scale_down_delay,omitempty"` // seconds under them before removing one
 
 	EnvVars map[string]string `json:"env_vars"`
 
@@ -93,13 +129,72 @@ type Application struct {
 	LastDeployedAt time.Time `json:"last_deployed_at"`
 }
 
+// a named command running from the app's image next to web, like a queue worker
+type Process struct {
+	Name      string  `json:"name"`
+	Command   string  `json:"command"`
+	Instances int     `json:"instances"`
+	Memory    int     `json:"memory"` // MB per instance
+	CPU       float64 `json:"cpu"`    // cores per instance
+
+	ContainerIDs []string `json:"container_ids"`
+}
+
+// an instance the watchdog caught crash looping. it's stopped and started again once its backoff
+// runs out, each time it crash loops again the backoff doubles
+type CrashedInstance struct {
+	ContainerID string `json:"container_id"`
+	Process     string `json:"process"`
+	Restarts    int    `json:"restarts"` // restarts within the window that tripped the threshold
+	ExitCode    int    `json:"exit_code"`
+	OOMKilled   bool   `json:"oom_killed,omitempty"`
+	Reason      string `json:"reason"`
+
+	Attempts  int       `json:"attempts"` // times it was stopped for crash looping
+	Stopped   bool      `json:"stopped"`  // waiting out the backoff, false once it was started again
+	StoppedAt time.Time `json:"stopped_at"`
+	RetryAt   time.Time `json:"retry_at"`
+	RetriedAt time.Time `json:"retried_at,omitempty"`
+}
+
+// a command run on a schedule in a one-off container from the app's current image
+type CronJob struct {
+	Name     string   `json:"name"`
+	Schedule string   `json:"schedule"` // five field cron expression or a macro like @daily
+	Command  []string `json:"command"`
+	Timeout  int      `json:"timeout,omitempty"` // seconds before a run is killed, 0 lets it run
+	Source   string   `json:"source"`            // yap.toml or cli, deploys only replace what yap.toml defined
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CronRun struct {
+	ID         string    `json:"id"`
+	Job        string    `json:"job"`
+	Trigger    string    `json:"trigger"` // schedule or manual
+	Status     string    `json:"status"`  // running, succeeded, failed or skipped
+	ExitCode   int       `json:"exit_code"`
+	Image      string    `json:"image,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	LogPath    string    `json:"log_path,omitempty"`
+}
+
 type DeploymentConfig struct {
 	MaxSurge        int `json:"max_surge"`
 	RollingInterval int `json:"rolling_interval"`
 	HealthTimeout   int `json:"health_timeout"`
 
-	AutoConfirm         bool `json:"auto_confirm"`
-	ConfirmationTimeout int  `json:"confirmation_timeout"`
+	AutoConfirm         bool   `json:"auto_confirm"`
+	ConfirmationTimeout int    `json:"confirmation_timeout"`
+	ConfirmationPolicy  string `json:"confirmation_policy,omitempty"` // confirm or revert
+
+	CanaryInstances int   `json:"canary_instances,omitempty"`
+	CanarySteps     []int `json:"canary_steps,omitempty"` // percent of traffic per step, e.g. 10, 50, 100
+	CanaryInterval  int   `json:"canary_interval,omitempty"`
+
+	ObservationWindow int `json:"observation_window,omitempty"` // seconds to watch a release after deploy, 0 disables
 }
 
 type DeploymentState struct {
@@ -111,6 +206,21 @@ type DeploymentState struct {
 	Green *Environment `json:"green,omitempty"`
 }
 
+type HealthProbe struct {
+	Type HealthProbeType `json:"type"`
+
+	Path           string `json:"path,omitempty"`
+	Port           int    `json:"port,omitempty"`            // defaults to the app port
+	ExpectedStatus int    `json:"expected_status,omitempty"` // any 2xx or 3xx when unset
+	ExpectedBody   string `json:"expected_body,omitempty"`   // substring the response body has to contain
+
+	Command []string `json:"command,omitempty"` // exec probes, run inside the instance
+
+	Interval int `json:"interval"` // seconds
+	Timeout  int `json:"timeout"`  // seconds per probe
+	Retries  int `json:"retries"`  // consecutive failures before an instance counts as unhealthy
+}
+
 type Environment struct {
 	ContainerIDs []string  `json:"container_ids"`
 	ImageID      string    `json:"image_id"`
@@ -119,10 +229,33 @@ type Environment struct {
 
 type DeploymentRecord struct {
 	ID         string             `json:"id"`
-	ImageID    string             `json:"image_id"`
+	Version    int                `json:"version"`
+	ImageID    string             `json:"image_id"`  // content digest (sha256:...)
+	ImageTag   string             `json:"image_tag"` // release tag (yap/{name}:v{version})
 	Strategy   DeploymentStrategy `json:"strategy"`
 	DeployedAt time.Time          `json:"deployed_at"`
 	Status     string             `json:"status"`
+
+	Source        string `json:"source,omitempty"` // prebuilt image reference or git repository the release came from
+	GitRef        string `json:"git_ref,omitempty"`
+	GitCommit     string `json:"git_commit,omitempty"`
+	GitBranch     string `json:"git_branch,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	Decision      string `json:"decision,omitempty"` // automatic confirm/revert taken on this release
+
+	BuildMethod   BuildType      `json:"build_method,omitempty"`
+	BuildDuration time.Duration  `json:"build_duration,omitempty"`
+	Operator      string         `json:"operator,omitempty"` // user@host that ran the deploy
+	Config        *ReleaseConfig `json:"config,omitempty"`   // missing on records from before snapshots
+}
+
+// the settings a release ran with. env values are left out, they're secrets more often than not
+type ReleaseConfig struct {
+	Instances int      `json:"instances"`
+	Memory    int      `json:"memory"`
+	CPU       float64  `json:"cpu"`
+	Port      int      `json:"port"`
+	EnvKeys   []string `json:"env_keys,omitempty"`
 }
 
 type AppRegistry struct {
@@ -1,19 +1,27 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 type ProjectConfig struct {
-	App        AppConfig           `toml:"app"`
-	Build      BuildConfig         `toml:"build"`
-	Deployment YapDeploymentConfig `toml:"deployment"`
-	Deploy     DeployConfig        `toml:"deploy"`
-	Network    NetworkConfig       `toml:"network"`
-	Env        map[string]string   `toml:"env"`
-	Database   map[string]string   `toml:"database"`
-	Volumes    map[string]string   `toml:"volumes"`
-	Hooks      HooksConfig         `toml:"hooks"`
-	Monitoring MonitoringConfig    `toml:"monitoring"`
-	Scaling    ScalingConfig       `toml:"scaling"`
+	App        AppConfig                `toml:"app"`
+	Build      BuildConfig              `toml:"build"`
+	Deployment YapDeploymentConfig      `toml:"deployment"`
+	Deploy     DeployConfig             `toml:"deploy"`
+	Network    NetworkConfig            `toml:"network"`
+	Env        map[string]string        `toml:"env"`
+	Database   map[string]string        `toml:"database"`
+	Volumes    map[string]string        `toml:"volumes"`
+	Hooks      HooksConfig              `toml:"hooks"`
+	Monitoring MonitoringConfig         `toml:"monitoring"`
+	Scaling    ScalingConfig            `toml:"scaling"`
+	Processes  map[string]ProcessConfig `toml:"processes"`
+	Cron       []CronConfig             `toml:"cron"`
 }
 
 type AppConfig struct {
@@ -23,10 +31,22 @@ type AppConfig struct {
 	Env     string `toml:"env"`
 }
 
+// paths are relative to the project directory
 type BuildConfig struct {
-	Dockerfile string   `toml:"dockerfile"`
-	Buildpacks bool     `toml:"buildpacks"`
-	BuildArgs  []string `toml:"build_args"`
+	Dockerfile string            `toml:"dockerfile"` // defaults to the Dockerfile in the context
+	Context    string            `toml:"context"`    // subdirectory that is built, defaults to the project
+	Buildpacks bool              `toml:"buildpacks"` // build with paketo when the build method is auto
+	BuildArgs  []string          `toml:"build_args"` // KEY=VALUE, $VAR and ${VAR} come from the environment
+	Target     string            `toml:"target"`
+	Labels     map[string]string `toml:"labels"`
+	Exclude    []string          `toml:"exclude"` // replaces the default exclusions when there's no .dockerignore
+	Paketo     PaketoConfig      `toml:"paketo"`
+}
+
+type PaketoConfig struct {
+	Builder     string   `toml:"builder"`
+	Buildpacks  []string `toml:"buildpacks"`
+	CacheVolume string   `toml:"cache_volume"`
 }
 
 type YapDeploymentConfig struct {
@@ -35,7 +55,12 @@ type YapDeploymentConfig struct {
 	RollingInterval     int    `toml:"rolling_interval"`
 	HealthTimeout       int    `toml:"health_timeout"`
 	AutoConfirm         bool   `toml:"auto_confirm"`
-	ConfirmationTimeout int    `toml:"confirmation_timeout"`
+	ConfirmationTimeout *int   `toml:"confirmation_timeout"` // nil keeps the default, 0 waits forever
+	ConfirmationPolicy  string `toml:"confirmation_policy"`
+	CanaryInstances     int    `toml:"canary_instances"`
+	CanarySteps         []int  `toml:"canary_steps"`
+	CanaryInterval      int    `toml:"canary_interval"`
+	ObservationWindow   int    `toml:"observation_window"`
 }
 
 type DeployConfig struct {
@@ -43,16 +68,211 @@ type DeployConfig struct {
 	Memory      string            `toml:"memory"`
 	CPU         float64           `toml:"cpu"`
 	Port        int               `toml:"port"`
+	Image       string            `toml:"image"` // deploy this image instead of building the project
 	AutoScaling bool              `toml:"auto_scaling"`
 	HealthCheck HealthCheckConfig `toml:"health_check"`
 	Resources   ResourceLimits    `toml:"resources"`
+
+	StopSignal  string `toml:"stop_signal"`
+	StopTimeout int    `toml:"stop_timeout"`
+	DrainPeriod *int   `toml:"drain_period"` // nil keeps the default, 0 turns draining off
+
+	CrashLoopThreshold int `toml:"crash_loop_threshold"`
 }
 
+// the top level settings are the readiness probe, [deploy.health_check.liveness] overrides them
+// for watching instances once they're serving
 type HealthCheckConfig struct {
-	Path     string `toml:"path"`
-	Interval int    `toml:"interval"`
-	Timeout  int    `toml:"timeout"`
-	Retries  int    `toml:"retries"`
+	Type           string   `toml:"type"` // http, tcp, exec or none
+	Path           string   `toml:"path"`
+	Port           int      `toml:"port"`
+	ExpectedStatus int      `toml:"expected_status"`
+	ExpectedBody   string   `toml:"expected_body"`
+	Command        []string `toml:"command"`
+	Interval       int      `toml:"interval"`
+	Timeout        int      `toml:"timeout"`
+	Retries        int      `toml:"retries"`
+
+	Liveness *HealthCheckConfig `toml:"liveness"`
+
+	// set by the loader when the file configures readiness, before defaults are filled in
+	Configured bool `toml:"-"`
+}
+
+func (c HealthCheckConfig) Probe() HealthProbe {
+	probe := HealthProbe{
+		Type:           HealthProbeType(c.Type),
+		Port:           c.Port,
+		ExpectedStatus: c.ExpectedStatus,
+		ExpectedBody:   c.ExpectedBody,
+		Command:        append([]string(nil), c.Command...),
+		Interval:       c.Interval,
+		Timeout:        c.Timeout,
+		Retries:        c.Retries,
+	}
+	if probe.Type == HealthProbeHTTP {
+		probe.Path = c.Path
+	}
+	return probe
+}
+
+func (c HealthCheckConfig) LivenessProbe() HealthProbe {
+	if c.Liveness == nil {
+		return c.Probe()
+	}
+	return c.Liveness.Probe()
+}
+
+func ValidateHealthProbe(probe HealthProbe) error {
+	switch probe.Type {
+	case HealthProbeHTTP:
+		if probe.Path == "" || probe.Path[0] != '/' {
+			return fmt.Errorf("http health check path must start with /, got: %q", probe.Path)
+		}
+	case HealthProbeTCP, HealthProbeNone:
+	case HealthProbeExec:
+		if len(probe.Command) == 0 {
+			return fmt.Errorf("exec health check requires a command")
+		}
+	default:
+		return fmt.Errorf("invalid health check type: %s (must be http, tcp, exec, or none)", probe.Type)
+	}
+
+	if probe.Port < 0 || probe.Port > 65535 {
+		return fmt.Errorf("invalid health check port: %d", probe.Port)
+	}
+	if probe.ExpectedStatus != 0 && (probe.ExpectedStatus < 100 || probe.ExpectedStatus > 599) {
+		return fmt.Errorf("invalid health check expected_status: %d", probe.ExpectedStatus)
+	}
+
+	return nil
+}
+
+// accepts signal names like SIGTERM or SIGQUIT and plain signal numbers, the way docker does
+func ValidateStopSignal(signal string) error {
+	if signal == "" {
+		return nil
+	}
+
+	if n, err := strconv.Atoi(signal); err == nil {
+		if n < 1 || n > 64 {
+			return fmt.Errorf("invalid stop signal: %s", signal)
+		}
+		return nil
+	}
+
+	name := strings.ToUpper(signal)
+	if !strings.HasPrefix(name, "SIG") || len(name) == 3 {
+		return fmt.Errorf("invalid stop signal: %s (use a name like SIGTERM or a number)", signal)
+	}
+	for _, r := range name[3:] {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '+' && r != '-' {
+			return fmt.Errorf("invalid stop signal: %s", signal)
+		}
+	}
+
+	return nil
+}
+
+// [processes.<name>]. web takes the app's traffic, its instances and resources default to [deploy]
+type ProcessConfig struct {
+	Command   string  `toml:"command"`
+	Instances int     `toml:"instances"`
+	Memory    string  `toml:"memory"`
+	CPU       float64 `toml:"cpu"`
+}
+
+// [[cron]] entries. a command string runs through sh -c, a list runs as is
+type CronConfig struct {
+	Name     string      `toml:"name"`
+	Schedule string      `toml:"schedule"`
+	Command  interface{} `toml:"command"`
+	Timeout  int         `toml:"timeout"`
+}
+
+func (c CronConfig) CommandArgs() ([]string, error) {
+	switch command := c.Command.(type) {
+	case string:
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("command is required")
+		}
+		return []string{"sh", "-c", command}, nil
+	case []interface{}:
+		args := make([]string, 0, len(command))
+		for _, arg := range command {
+			s, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("command must be a string or a list of strings")
+			}
+			args = append(args, s)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("command is required")
+		}
+		return args, nil
+	case nil:
+		return nil, fmt.Errorf("command is required")
+	}
+	return nil, fmt.Errorf("command must be a string or a list of strings")
+}
+
+// build paths stay inside the project, a git checkout shouldn't reach the rest of the host
+func ValidateBuildPath(kind, path string) error {
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("%s must be relative to the project: %s", kind, path)
+	}
+	if clean := filepath.Clean(path); clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("%s must stay inside the project: %s", kind, path)
+	}
+	return nil
+}
+
+// KEY=VALUE, or a bare KEY that passes the variable through from the environment
+func ValidateBuildArg(arg string) error {
+	key, _, _ := strings.Cut(arg, "=")
+	if strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t") {
+		return fmt.Errorf("invalid build arg: %q (KEY=VALUE or KEY)", arg)
+	}
+	return nil
+}
+
+func ValidateLabel(key string) error {
+	if strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t=") {
+		return fmt.Errorf("invalid label: %q", key)
+	}
+	return nil
+}
+
+// docker's rules for volume names, the cache volume is handed to pack as is
+var cacheVolumePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func ValidateCacheVolume(name string) error {
+	if !cacheVolumePattern.MatchString(name) {
+		return fmt.Errorf("invalid cache volume name: %q (letters, digits, '_', '.' and '-')", name)
+	}
+	return nil
+}
+
+// process names end up in container names, so they stay short, lowercase and dot free
+func ValidateProcessName(name string) error {
+	return validateShortName("process", name)
+}
+
+// cron job names end up in container names and paths, same rules as processes
+func ValidateCronJobName(name string) error {
+	return validateShortName("cron job", name)
+}
+
+func validateShortName(kind, name string) error {
+	if name == "" || len(name) > 32 {
+		return fmt.Errorf("invalid %s name: %q (1 to 32 characters)", kind, name)
+	}
+	for i, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && (r != '-' || i == 0) {
+			return fmt.Errorf("invalid %s name: %q (use lowercase letters, digits and dashes)", kind, name)
+		}
+	}
+	return nil
 }
 
 type ResourceLimits struct {
@@ -112,3 +332,23 @@ func ParseMemory(mem string) int {
 		return value
 	}
 }
+
+func ValidateCanarySteps(steps []int) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("canary_steps cannot be empty")
+	}
+
+	previous := 0
+	for _, step := range steps {
+		if step <= previous || step > 100 {
+			return fmt.Errorf("canary_steps must be increasing percentages between 1 and 100, got: %v", steps)
+		}
+		previous = step
+	}
+
+	if previous != 100 {
+		return fmt.Errorf("canary_steps must end at 100, got: %v", steps)
+	}
+
+	return nil
+}